@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const ALIAS_RATIO = 0.9 // Score of a match on an alias relative to a match on the search term itself
+
+// Built-in aliases, each pair works both ways: searching k8s also searches kubernetes
+// and searching kubernetes also searches k8s
+var builtinAliases = [][2]string{
+	{"k8s", "kubernetes"},
+	{"js", "javascript"},
+	{"ts", "typescript"},
+	{"golang", "go"},
+	{"py", "python"},
+	{"rb", "ruby"},
+	{"tf", "terraform"},
+	{"postgres", "postgresql"},
+	{"db", "database"},
+	{"md", "markdown"},
+	{"yml", "yaml"},
+	{"gh", "github"},
+}
+
+// loadAliases returns the built-in aliases along with the ones defined in the file, if
+// provided, indexed by normalized term. The file has one alias=expansion pair per line,
+// empty lines and lines starting with # are ignored.
+func loadAliases(path string) (map[string][]string, error) {
+	aliases := make(map[string][]string)
+	for _, pair := range builtinAliases {
+		addAlias(aliases, pair[0], pair[1])
+	}
+	if path == "" {
+		return aliases, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		alias, expansion, ok := strings.Cut(text, "=")
+		alias, expansion = strings.TrimSpace(alias), strings.TrimSpace(expansion)
+		if !ok || alias == "" || expansion == "" {
+			return nil, fmt.Errorf("%s:%d: expected alias=expansion, got: %q", path, line, text)
+		}
+		if strings.IndexFunc(alias+expansion, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("%s:%d: aliases must be single words, got: %q", path, line, text)
+		}
+		addAlias(aliases, alias, expansion)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// addAlias records the pair both ways, ignoring duplicates
+func addAlias(aliases map[string][]string, alias string, expansion string) {
+	alias, expansion = normalize(alias), normalize(expansion)
+	if alias == expansion {
+		return
+	}
+	for _, pair := range [][2]string{{alias, expansion}, {expansion, alias}} {
+		known := false
+		for _, existing := range aliases[pair[0]] {
+			if existing == pair[1] {
+				known = true
+				break
+			}
+		}
+		if !known {
+			aliases[pair[0]] = append(aliases[pair[0]], pair[1])
+		}
+	}
+}
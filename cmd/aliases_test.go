@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"container/heap"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAliases(t *testing.T) {
+	t.Run("BuiltinAliasesWorkBothWays", func(t *testing.T) {
+		aliases, err := loadAliases("")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"kubernetes"}, aliases["k8s"])
+		assert.Equal(t, []string{"k8s"}, aliases["kubernetes"])
+		assert.Equal(t, []string{"golang"}, aliases["go"])
+	})
+
+	t.Run("File", func(t *testing.T) {
+		aliases, err := loadAliases("testdata/aliases.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"open-policy-agent"}, aliases["opa"])
+		assert.Equal(t, []string{"wm"}, aliases["window-manager"])
+		// File aliases are added to the built-in ones and normalized
+		assert.Equal(t, []string{"kubernetes", "kube"}, aliases["k8s"])
+		assert.Equal(t, []string{"k8s"}, aliases["kube"])
+	})
+
+	invalid := []struct {
+		name    string
+		content string
+	}{
+		{name: "MissingExpansion", content: "opa\n"},
+		{name: "EmptyExpansion", content: "opa=\n"},
+		{name: "EmptyAlias", content: "=opa\n"},
+		{name: "SeveralWords", content: "ml=machine learning\n"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "aliases")
+			assert.NoError(t, os.WriteFile(path, []byte("# comment\n"+tt.content), 0o644))
+			_, err := loadAliases(path)
+			assert.ErrorContains(t, err, path+":2:")
+		})
+	}
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := loadAliases("testdata/missing.txt")
+		assert.Error(t, err)
+	})
+}
+
+func TestSearchAliases(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	defer func() { aliasesFile = "" }()
+
+	tests := []struct {
+		name        string
+		aliasesFile string
+		find        string
+		wantRepos   []string
+		wantRanks   []int
+	}{
+		{
+			name:      "BuiltinAlias",
+			find:      "k8s",
+			wantRepos: []string{"open-policy-agent/gatekeeper"},
+			wantRanks: []int{720},
+		},
+		{
+			// gh-export-secrets has both the golang and go topics, the literal match wins
+			name:      "LiteralMatchOutranksAlias",
+			find:      "topic:golang",
+			wantRepos: []string{"katiem0/gh-export-secrets", "lithammer/fuzzysearch"},
+			wantRanks: []int{800, 720},
+		},
+		{
+			name:        "AliasesFile",
+			aliasesFile: "testdata/aliases.txt",
+			find:        "opa wm",
+			wantRepos:   []string{"open-policy-agent/gatekeeper", "ianyh/Amethyst"},
+			wantRanks:   []int{800, 720},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aliasesFile = tt.aliasesFile
+			got, err := Search(testData, tt.find)
+			assert.NoError(t, err)
+			gotRepos := []string{}
+			gotRanks := []int{}
+			for got.Len() > 0 {
+				item := heap.Pop(&got).(*pq.Item)
+				gotRepos = append(gotRepos, item.Value.(Result).Full_name)
+				gotRanks = append(gotRanks, item.Priority)
+			}
+			assert.Equal(t, tt.wantRepos, gotRepos)
+			assert.Equal(t, tt.wantRanks, gotRanks)
+		})
+	}
+
+	aliasesFile = "testdata/missing.txt"
+	_, err := Search(testData, "k8s")
+	assert.Error(t, err)
+}
@@ -0,0 +1,1365 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags of the cache subcommands
+var (
+	dryRun       bool
+	olderThan    dateFlag // See cache prune --older-than
+	refreshUsers []string // Users of cache refresh, see --user
+	usersFile    string
+)
+
+// cacheFilePattern matches the names of the files gh-stars writes in the cache directory:
+// the cache file of every --source and of the gists, named after the user, which older
+// versions didn't, and the first 6 bytes of the cache key, see cacheFileName, and the caches
+// next to them (READMEs, parents, results, validators, star lists, index, lock, corrupted
+// cache file moved aside)
+var cacheFilePattern = regexp.MustCompile(`^((stars|watching|owned)_([a-z0-9-]+_)?[0-9a-f]{12}(\.(readme|parents|results|etag|list_[A-Za-z0-9_=-]+))?\.json|(stars|watching|owned)_([a-z0-9-]+_)?[0-9a-f]{12}\.json\.gz(\.lock|\.corrupted)?|(stars|watching|owned)_([a-z0-9-]+_)?[0-9a-f]{12}\.index|gists_[0-9a-f]{12}\.json)$`)
+
+// A cacheEntry is a file of the cache directory matching cacheFilePattern
+type cacheEntry struct {
+	path string
+	size int64
+}
+
+const CACHE_DIR_ENV = "GH_STARS_CACHE_DIR" // Environment variable overriding the cache directory
+
+// cacheDir returns the directory the cache files are written in when --cache-file isn't
+// provided: $GH_STARS_CACHE_DIR, otherwise gh-stars in the user cache directory, e.g.
+// ~/.cache/gh-stars on Linux. It isn't created, see prepareCacheFile.
+func cacheDir() (string, error) {
+	if dir := os.Getenv(CACHE_DIR_ENV); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gh-stars"), nil
+}
+
+// legacyCacheDir returns the directory the cache files were written in before cacheDir, the
+// temporary directory: $TMPDIR, %TEMP% on Windows, see os.TempDir. They are still read from
+// there, see prepareCacheFile
+func legacyCacheDir() string {
+	return os.TempDir()
+}
+
+// prepareCacheFile creates the directory of a cache file in cacheDir, and makes sure it is
+// writable before anything is fetched. When the cache file doesn't exist yet, the one older
+// versions wrote under the previous name, next to it then in legacyCacheDir, is renamed
+// along with the caches next to it, unless they are already there, and compressed when it
+// was written uncompressed, so they don't need to be fetched again.
+func prepareCacheFile(path string, previous string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return cacheDirError(dir, err)
+	}
+	probe, err := os.CreateTemp(dir, ".gh-stars-*.tmp")
+	if err != nil {
+		return cacheDirError(dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	if fileExists(path) {
+		return nil
+	}
+
+	prefix, previousPrefix := trimCacheExt(filepath.Base(path))+".", trimCacheExt(previous)+"."
+	dirs := []string{dir}
+	if legacyDir := legacyCacheDir(); filepath.Clean(legacyDir) != filepath.Clean(dir) {
+		dirs = append(dirs, legacyDir)
+	}
+	for _, from := range dirs {
+		if from == dir && previousPrefix == prefix {
+			continue
+		}
+		files, err := findCacheFiles(from, []string{previousPrefix})
+		if err != nil {
+			files = nil
+		}
+		for _, file := range files {
+			target := filepath.Join(dir, prefix+strings.TrimPrefix(filepath.Base(file.path), previousPrefix))
+			if fileExists(target) || fileExists(target+".gz") {
+				continue
+			}
+			InfoLogger.Println("Moving the cache file", file.path, "to", target)
+			if err := moveFile(file.path, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	uncompressed := strings.TrimSuffix(path, ".gz")
+	stat, err := os.Stat(uncompressed)
+	if uncompressed == path || err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(uncompressed)
+	if err != nil {
+		return err
+	}
+	InfoLogger.Println("Compressing the cache file", uncompressed, "to", path)
+	if err := writeCacheFile(path, data); err != nil {
+		return err
+	}
+	// The cache file keeps its age, see --cache-ttl
+	if err := os.Chtimes(path, stat.ModTime(), stat.ModTime()); err != nil {
+		return err
+	}
+	return os.Remove(uncompressed)
+}
+
+// createCacheFileDir creates the directory of the --cache-file along with its parents when
+// they are missing, like mkdir -p, so scripts can point it at a directory that doesn't
+// exist yet
+func createCacheFileDir(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("not able to create the directory %s of the cache file %s, permission denied: %w", dir, path, err)
+		}
+		return fmt.Errorf("not able to create the directory %s of the cache file %s: %w", dir, path, err)
+	}
+	return nil
+}
+
+// expandHome replaces the leading ~ of a path with the home directory of the user, which the
+// shell doesn't do in --cache-file=~/stars.json nor cobra in any flag
+//
+// Example: ~/.cache/stars.json becomes /home/link-/.cache/stars.json
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// cacheDirError explains why the cache directory can't be written in, pointing at
+// $GH_STARS_CACHE_DIR when it's the one
+func cacheDirError(dir string, err error) error {
+	if os.Getenv(CACHE_DIR_ENV) != "" {
+		return fmt.Errorf("the cache directory %s set by %s is not writable: %w", dir, CACHE_DIR_ENV, err)
+	}
+	return fmt.Errorf("the cache directory %s is not writable: %w", dir, err)
+}
+
+// moveFile moves a file, copying it when it can't be renamed, like across file systems
+func moveFile(from, to string) error {
+	if err := renameFile(from, to); err == nil {
+		return nil
+	}
+	stat, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(to, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Chtimes(to, stat.ModTime(), stat.ModTime()); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// errCacheCorrupted is returned by readCacheFile when a compressed cache file can't be
+// decompressed, like when the disk filled up while it was written
+var errCacheCorrupted = errors.New("the cache file is corrupted")
+
+// readCacheFile returns the content of a cache file, decompressed when it's gzip-compressed.
+// The cache files written uncompressed, by older versions or with --cache-file, are read as
+// is. A compressed cache file can be empty once decompressed, whatever its size.
+func readCacheFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return data, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCacheCorrupted, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCacheCorrupted, err)
+	}
+	return content, nil
+}
+
+const CACHE_VERSION = 4 // Version of the envelope of the cache files, bumped when fields are added, see migrateCache
+
+// A cacheEnvelope is what a cache file holds: the repos along with whose repos they are,
+// when and by which version of gh stars they were fetched. The cache files written by
+// older versions hold the bare list of repos, they are read as the repos of an envelope of
+// version 1. The envelopes of older versions are migrated when read, see migrateCache.
+type cacheEnvelope struct {
+	Version      int             `json:"version"`
+	User         string          `json:"user,omitempty"`
+	Host         string          `json:"host,omitempty"`
+	Source       string          `json:"source,omitempty"`
+	Tool_version string          `json:"tool_version,omitempty"`
+	Fetched_at   string          `json:"fetched_at,omitempty"`
+	Star_dates   bool            `json:"star_dates"` // Whether the repos were fetched with their star date, see unwrapStars
+	Count        int             `json:"count"`      // Number of repos, since version 3
+	Checksum     string          `json:"checksum"`   // SHA-256 of the repos, since version 4, see reposChecksum
+	Repos        json.RawMessage `json:"repos"`
+}
+
+// newCacheEnvelope returns the envelope of the repos of the --source of the user, fetched
+// now, an error when the repos can't be decoded
+func newCacheEnvelope(user string, repos []byte) (cacheEnvelope, error) {
+	decoded, err := DecodeRepos(*bytes.NewBuffer(repos))
+	if err != nil {
+		return cacheEnvelope{}, err
+	}
+	return cacheEnvelope{
+		Version:      CACHE_VERSION,
+		User:         user,
+		Host:         host,
+		Source:       source,
+		Tool_version: VERSION,
+		Fetched_at:   time.Now().UTC().Format(time.RFC3339),
+		Star_dates:   sources[source].accept == STAR_MEDIA_TYPE,
+		Count:        len(decoded),
+		Checksum:     reposChecksum(repos),
+		Repos:        repos,
+	}, nil
+}
+
+// reposChecksum returns the hex SHA-256 of the repos of a cache file. The repos are
+// compacted first, the way they are written in the envelope, see json.Marshal.
+func reposChecksum(repos []byte) string {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, repos); err != nil {
+		return fmt.Sprintf("%x", sha256.Sum256(repos))
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(compacted.Bytes()))
+}
+
+// verifyChecksum returns errCacheCorrupted when the repos of the envelope don't match its
+// checksum, like when a bit flipped on the disk or the cache file was partially copied. The
+// digests are logged with --debug. The envelopes older than version 4 have no checksum.
+func (c cacheEnvelope) verifyChecksum() error {
+	if c.Version < 4 {
+		return nil
+	}
+	if checksum := reposChecksum(c.Repos); checksum != c.Checksum {
+		InfoLogger.Printf("The checksum of the repos is %s, the envelope expects %s", checksum, c.Checksum)
+		return fmt.Errorf("%w: the checksum of the repos doesn't match", errCacheCorrupted)
+	}
+	return nil
+}
+
+// decodeCache returns the envelope of the content of a cache file, see readCacheFile. An
+// empty cache file has no repos, and a bare list of repos is the legacy format. The repos
+// are decoded the way they are searched, a cache file they can't be decoded from, like one
+// truncated when the disk filled up, is corrupted. So is one holding fewer or more repos
+// than its envelope counts, or repos that don't match its checksum, see verifyChecksum.
+func decodeCache(data []byte) (cacheEnvelope, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return cacheEnvelope{}, nil
+	}
+	cache := cacheEnvelope{Version: 1, Repos: data}
+	if trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &cache); err != nil {
+			return cacheEnvelope{}, fmt.Errorf("%w: %v", errCacheCorrupted, err)
+		}
+		if len(cache.Repos) == 0 || string(cache.Repos) == "null" {
+			return cacheEnvelope{}, fmt.Errorf("%w: the envelope of version %d has no repos", errCacheCorrupted, cache.Version)
+		}
+		if err := cache.verifyChecksum(); err != nil {
+			return cacheEnvelope{}, err
+		}
+	}
+	repos, err := DecodeRepos(*bytes.NewBuffer(cache.Repos))
+	if err != nil {
+		return cacheEnvelope{}, fmt.Errorf("%w: %v", errCacheCorrupted, err)
+	}
+	if cache.Version >= 3 && cache.Count != len(repos) {
+		return cacheEnvelope{}, fmt.Errorf("%w: it holds %d repos out of %d", errCacheCorrupted, len(repos), cache.Count)
+	}
+	return cache, nil
+}
+
+// errCacheOutdated is returned by migrateCache when the repos of a cache file of an older
+// version lack what this version needs, they are fetched again then
+var errCacheOutdated = errors.New("the cache file is outdated")
+
+// migrateCache upgrades the envelope of the repos of the --source of the user, written
+// with an older version, to CACHE_VERSION one version at a time:
+//   - version 1, the bare list of repos, gets an envelope telling whose repos they are,
+//     fetched when the cache file was written. The starred repos fetched without their
+//     star date can't be updated, see updateCache: they are outdated.
+//   - version 2 gets the number of repos
+//   - version 3 gets the checksum of the repos
+//
+// The envelopes of an unknown version are outdated.
+func migrateCache(cache cacheEnvelope, user string, written time.Time) (cacheEnvelope, error) {
+	repos, err := DecodeRepos(*bytes.NewBuffer(cache.Repos))
+	if err != nil {
+		return cacheEnvelope{}, fmt.Errorf("%w: %v", errCacheCorrupted, err)
+	}
+	for cache.Version < CACHE_VERSION {
+		switch cache.Version {
+		case 1:
+			starDates := sources[source].accept == STAR_MEDIA_TYPE
+			if starDates && !hasStarDates(repos) {
+				return cacheEnvelope{}, fmt.Errorf("%w: the repos of version 1 were fetched without their star date", errCacheOutdated)
+			}
+			cache = cacheEnvelope{
+				Version:    2,
+				User:       user,
+				Host:       host,
+				Source:     source,
+				Fetched_at: written.UTC().Format(time.RFC3339),
+				Star_dates: starDates,
+				Repos:      cache.Repos,
+			}
+		case 2:
+			cache.Version = 3
+			cache.Count = len(repos)
+		case 3:
+			cache.Version = 4
+			cache.Checksum = reposChecksum(cache.Repos)
+		default:
+			return cacheEnvelope{}, fmt.Errorf("%w: version %d is unknown", errCacheOutdated, cache.Version)
+		}
+	}
+	cache.Tool_version = VERSION
+	return cache, nil
+}
+
+// upgradeCache migrates the cache file of an older version, see migrateCache, and rewrites
+// it. Another process may have rewritten it while this one waited for its lock, its
+// envelope is returned then. A cache file that can't be migrated has no repos: they are
+// fetched again.
+func upgradeCache(path string, user string, cache cacheEnvelope) cacheEnvelope {
+	unlock, err := lockCacheFile(path)
+	if err != nil {
+		WarnLogger.Printf("Not able to lock the cache file to migrate it: %v", err)
+		return cacheEnvelope{}
+	}
+	defer unlock()
+	if current, err := readCache(path); err == nil && current.Version >= CACHE_VERSION && current.belongsTo(user) {
+		return current
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		WarnLogger.Printf("Not able to migrate the cache file %s: %v", path, err)
+		return cacheEnvelope{}
+	}
+	migrated, err := migrateCache(cache, user, stat.ModTime())
+	if err != nil {
+		InfoLogger.Printf("Not able to migrate the cache file %s from version %d to version %d, fetching the repos again: %v", path, cache.Version, CACHE_VERSION, err)
+		return cacheEnvelope{}
+	}
+	InfoLogger.Printf("Migrating the cache file %s from version %d to version %d", path, cache.Version, CACHE_VERSION)
+	data, err := json.Marshal(migrated)
+	if err == nil {
+		err = writeCacheFile(path, data)
+	}
+	if err != nil {
+		WarnLogger.Printf("Not able to rewrite the migrated cache file, it is migrated again on the next search: %v", err)
+	}
+	return migrated
+}
+
+// readCache returns the envelope of a cache file, see decodeCache
+func readCache(path string) (cacheEnvelope, error) {
+	data, err := readCacheFile(path)
+	if err != nil {
+		return cacheEnvelope{}, err
+	}
+	return decodeCache(data)
+}
+
+// belongsTo reports whether the repos are those of the --source of the user on the host,
+// which a --cache-file shared by several users, sources or hosts may not hold. The legacy
+// cache files don't tell, they are assumed to.
+func (c cacheEnvelope) belongsTo(user string) bool {
+	return (c.User == "" || strings.EqualFold(c.User, user)) && (c.Source == "" || c.Source == source) && (c.Host == "" || strings.EqualFold(c.Host, host))
+}
+
+// age returns how long ago the repos were fetched, the age of the cache file for the
+// legacy ones, or checked with GitHub since, which touches their validators, see
+// revalidateCache
+func (c cacheEnvelope) age(path string) (time.Duration, error) {
+	age, err := fileAge(path)
+	if fetchedAt, parseErr := time.Parse(time.RFC3339, c.Fetched_at); parseErr == nil {
+		age, err = time.Since(fetchedAt), nil
+	}
+	if checked, checkErr := fileAge(validatorPath(path)); err == nil && checkErr == nil && checked < age {
+		return checked, nil
+	}
+	return age, err
+}
+
+// skipCacheWrite reports whether writing the cache at path is skipped because the cache is
+// read-only, see --cache-read-only, logging it
+func skipCacheWrite(path string) bool {
+	if cacheReadOnly {
+		InfoLogger.Println("The cache is read-only, not writing:", path)
+	}
+	return cacheReadOnly
+}
+
+// saveCache writes one of the caches of gh stars, unless the cache is read-only, see
+// skipCacheWrite. The cache directory is trimmed to its size budget then, see trimCacheDir.
+func saveCache(path string, data []byte) error {
+	if skipCacheWrite(path) {
+		return nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	if err := trimCacheDir(path); err != nil {
+		WarnLogger.Printf("Not able to trim the cache directory: %v", err)
+	}
+	return nil
+}
+
+// writeCacheFile writes the content of a cache file, gzip-compressed when its name ends
+// with .gz like the cache files generated, see CachePath. The content is written to a
+// temporary file renamed over the cache file, so the cache file is never read half written.
+// Nothing is written when the cache is read-only, see skipCacheWrite.
+func writeCacheFile(path string, data []byte) error {
+	if skipCacheWrite(path) {
+		return nil
+	}
+	if strings.HasSuffix(path, ".gz") {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		data = compressed.Bytes()
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return renameFile(file.Name(), path)
+}
+
+// sidelineCache moves a corrupted cache file aside, to the same path with a .corrupted
+// extension, replacing the one moved there before. It can be looked into then while the
+// repos are fetched again. Its validators are removed along with it. The cache file is kept
+// when another process wrote the repos to it while this one waited for its lock.
+func sidelineCache(path string) error {
+	if skipCacheWrite(path) {
+		return nil
+	}
+	unlock, err := lockCacheFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := readCache(path); !errors.Is(err, errCacheCorrupted) {
+		return nil
+	}
+	if err := writeCacheValidator(path, cacheValidator{}); err != nil {
+		return err
+	}
+	return renameFile(path, path+".corrupted")
+}
+
+// lockCacheFile blocks until the process holds the lock of the cache file, so a single
+// process fetches the repos and writes them when several search at once. The lock is an
+// advisory lock on a file next to the cache file, see lockFile. The returned function
+// releases it. A read-only cache is never written, there is nothing to lock then.
+func lockCacheFile(path string) (func(), error) {
+	if cacheReadOnly {
+		return func() {}, nil
+	}
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	InfoLogger.Println("Waiting for the lock of the cache file:", path)
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// trimCacheExt returns the path of a cache file without its extension, .json or .json.gz,
+// the caches next to it are named after it
+func trimCacheExt(path string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".json")
+}
+
+// findCacheFiles lists the cache files in dir, in alphabetical order. Only the regular
+// files matching cacheFilePattern are listed, never a directory or a symlink. With
+// prefixes, only the files whose name starts with one of them are listed.
+func findCacheFiles(dir string, prefixes []string) ([]cacheEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []cacheEntry
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !cacheFilePattern.MatchString(entry.Name()) {
+			continue
+		}
+		if len(prefixes) > 0 && !hasAnyPrefix(entry.Name(), prefixes) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, cacheEntry{path: filepath.Join(dir, entry.Name()), size: info.Size()})
+	}
+	return files, nil
+}
+
+// evictStaleCaches deletes the cache files of the --source of the user that weren't written
+// for longer than --cache-max-age, along with the caches next to them, but the cache file
+// just written. The cache key changes along with the repos, see GenerateCacheKey: the cache
+// files of the previous keys are never read again. Only the cache files telling whose repos
+// they hold are deleted, see cacheEnvelope, and none with --cache-file.
+func evictStaleCaches(path string, user string, now time.Time) error {
+	if cacheFile != "" || cacheMaxAge == 0 {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	files, err := findCacheFiles(dir, []string{source + "_"})
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.path == path || !strings.HasSuffix(file.path, ".json.gz") {
+			continue
+		}
+		stat, err := os.Stat(file.path)
+		if err != nil || now.Sub(stat.ModTime()) <= cacheMaxAge {
+			continue
+		}
+		if cache, err := readCache(file.path); err != nil || cache.User == "" || !cache.belongsTo(user) {
+			continue
+		}
+		stale, err := findCacheFiles(dir, []string{filepath.Base(trimCacheExt(file.path)) + "."})
+		if err != nil {
+			return err
+		}
+		for _, staleFile := range stale {
+			if err := os.Remove(staleFile.path); err != nil {
+				return err
+			}
+			InfoLogger.Printf("Deleted the stale cache file %s, written %s ago", staleFile.path, now.Sub(stat.ModTime()).Round(time.Hour))
+		}
+	}
+	return nil
+}
+
+const CACHE_MAX_SIZE_ENV = "GH_STARS_CACHE_MAX_SIZE" // Environment variable setting the size budget of the cache directory, see --cache-max-size
+
+const DEFAULT_CACHE_MAX_SIZE = 200 << 20 // Size budget of the cache directory, see --cache-max-size
+
+// cacheSizeBudget returns the number of bytes the files of the cache directory may take:
+// --cache-max-size, otherwise $GH_STARS_CACHE_MAX_SIZE, otherwise DEFAULT_CACHE_MAX_SIZE.
+// 0 is no budget.
+func cacheSizeBudget() (int64, error) {
+	if cacheMaxSize.set {
+		return cacheMaxSize.value, nil
+	}
+	if value := os.Getenv(CACHE_MAX_SIZE_ENV); value != "" {
+		size, err := parseSize(value)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", CACHE_MAX_SIZE_ENV, err)
+		}
+		return size, nil
+	}
+	return DEFAULT_CACHE_MAX_SIZE, nil
+}
+
+// trimCacheDir deletes the least recently used cache files of the cache directory, along
+// with the caches next to them, until the files gh stars writes there take no more than
+// the size budget, see cacheSizeBudget. A cache file was last used when it, or one of the
+// caches next to it, was last written or checked with GitHub, see GetStarredRepos. The
+// cache file at path is kept, as are the locks. Only the files matching cacheFilePattern
+// are counted and deleted, and none with --cache-file.
+func trimCacheDir(path string) error {
+	budget, err := cacheSizeBudget()
+	if err != nil || cacheFile != "" || budget == 0 {
+		return err
+	}
+	files, err := findCacheFiles(filepath.Dir(path), nil)
+	if err != nil {
+		return err
+	}
+
+	// The cache files are named after their cache key, the caches next to them too, see
+	// validatorPath
+	type cacheGroup struct {
+		name  string
+		files []cacheEntry
+		size  int64
+		used  time.Time
+	}
+	var total int64
+	var groups []*cacheGroup
+	byName := map[string]*cacheGroup{}
+	for _, file := range files {
+		total += file.size
+		if strings.HasSuffix(file.path, ".lock") {
+			continue
+		}
+		name, _, _ := strings.Cut(filepath.Base(file.path), ".")
+		group, ok := byName[name]
+		if !ok {
+			group = &cacheGroup{name: name}
+			byName[name] = group
+			groups = append(groups, group)
+		}
+		stat, err := os.Stat(file.path)
+		if err != nil {
+			return err
+		}
+		if stat.ModTime().After(group.used) {
+			group.used = stat.ModTime()
+		}
+		group.files = append(group.files, file)
+		group.size += file.size
+	}
+	if total <= budget {
+		return nil
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].used.Before(groups[j].used) })
+	kept, _, _ := strings.Cut(filepath.Base(path), ".")
+	for _, group := range groups {
+		if total <= budget {
+			break
+		}
+		if group.name == kept {
+			continue
+		}
+		for _, file := range group.files {
+			if err := os.Remove(file.path); err != nil {
+				return err
+			}
+		}
+		total -= group.size
+		InfoLogger.Printf("Deleted the cache files of %s, %s last used %s, the cache directory is above --cache-max-size %s", group.name, formatSize(group.size), group.used.Format(time.RFC3339), formatSize(budget))
+	}
+	return nil
+}
+
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheDirs returns the directories cache clear deletes the cache files of: cacheDir and
+// legacyCacheDir, where some may be left
+func cacheDirs() []string {
+	legacyDir := legacyCacheDir()
+	dir, err := cacheDir()
+	if err != nil || filepath.Clean(dir) == filepath.Clean(legacyDir) {
+		return []string{legacyDir}
+	}
+	return []string{dir, legacyDir}
+}
+
+// userCachePrefixes returns the prefix of the names of the cache files of user for every
+// source, see cacheFileName. The cache files older versions named without the user are
+// matched by their current cache key, see GenerateCacheKey: those written before the user
+// starred or unstarred a repo have another key and aren't.
+func userCachePrefixes(user string) ([]string, error) {
+	defer func(current string) { source = current }(source)
+
+	var prefixes []string
+	for _, name := range sourceNames() {
+		source = name
+		cacheKey, err := GenerateCacheKey(user)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, fmt.Sprintf("%s_%s_", name, cacheUserName(user)), fmt.Sprintf("%s_%x.", name, cacheKey[:6]))
+	}
+	return prefixes, nil
+}
+
+// clearCache deletes the cache files in the directories, see findCacheFiles, and prints
+// every file deleted followed by the total size reclaimed. With dryRun, the files are only
+// printed. A directory that doesn't exist has no cache file. The locks are kept, a search
+// may hold one, see lockCacheFile.
+func clearCache(out io.Writer, dirs []string, prefixes []string, dryRun bool) error {
+	var files []cacheEntry
+	for _, dir := range dirs {
+		found, err := findCacheFiles(dir, prefixes)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		for _, file := range found {
+			if !strings.HasSuffix(file.path, ".lock") {
+				files = append(files, file)
+			}
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(out, "No cache file found in %s\n", strings.Join(dirs, " nor "))
+		return nil
+	}
+
+	return removeCacheFiles(out, files, dryRun)
+}
+
+// removeCacheFiles deletes the cache files and prints every file deleted followed by the
+// total size reclaimed. With dryRun, the files are only printed.
+func removeCacheFiles(out io.Writer, files []cacheEntry, dryRun bool) error {
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	var total int64
+	for _, file := range files {
+		if !dryRun {
+			if err := os.Remove(file.path); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, "%s %s (%s)\n", verb, file.path, formatSize(file.size))
+		total += file.size
+	}
+	fmt.Fprintf(out, "%s %d cache file(s), %s reclaimed\n", verb, len(files), formatSize(total))
+	return nil
+}
+
+// pruneCache deletes the cache files in the directories, see findCacheFiles, of every user
+// that weren't used since before, along with the caches next to them, and prints them the
+// way clearCache does. A cache file was last used when its repos were fetched, when it or
+// one of the caches next to it was last written or checked with GitHub, whichever is the
+// latest, see trimCacheDir. The locks are kept, as clearCache does. With dryRun, the files
+// are only printed.
+func pruneCache(out io.Writer, dirs []string, before time.Time, dryRun bool) error {
+	var files []cacheEntry
+	for _, dir := range dirs {
+		found, err := findCacheFiles(dir, nil)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		// The caches next to a cache file are named after it, see validatorPath
+		used := map[string]time.Time{}
+		var unlocked []cacheEntry
+		for _, file := range found {
+			if strings.HasSuffix(file.path, ".lock") {
+				continue
+			}
+			unlocked = append(unlocked, file)
+			stat, err := os.Stat(file.path)
+			if err != nil {
+				return err
+			}
+			last := stat.ModTime()
+			if strings.HasSuffix(file.path, ".json.gz") {
+				if cache, err := readCache(file.path); err == nil {
+					if fetchedAt, err := time.Parse(time.RFC3339, cache.Fetched_at); err == nil && fetchedAt.After(last) {
+						last = fetchedAt
+					}
+				}
+			}
+			name, _, _ := strings.Cut(filepath.Base(file.path), ".")
+			if last.After(used[name]) {
+				used[name] = last
+			}
+		}
+		for _, file := range unlocked {
+			name, _, _ := strings.Cut(filepath.Base(file.path), ".")
+			if used[name].Before(before) {
+				files = append(files, file)
+			}
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(out, "No cache file unused since %s found in %s\n", before.Format(time.RFC3339), strings.Join(dirs, " nor "))
+		return nil
+	}
+	return removeCacheFiles(out, files, dryRun)
+}
+
+// printCacheInfo prints where the cache file of the repos of the user with the cache key
+// is, see CachePath, and what it holds: its size, the number of repos and when they were
+// fetched. A cache file that doesn't exist or is empty is reported as such.
+func printCacheInfo(out io.Writer, user string, cacheKey [32]byte, now time.Time) error {
+	path, err := CachePath(cacheKey)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "User:       %s\n", user)
+	fmt.Fprintf(out, "Source:     %s\n", source)
+	fmt.Fprintf(out, "Cache key:  %x\n", cacheKey)
+	// The cache file left uncompressed, without the user or in the legacy directory is read
+	// until it's moved, see prepareCacheFile
+	fmt.Fprintf(out, "Cache file: %s\n", path)
+	if cacheFile == "" && !fileExists(path) {
+		dir, uncompressed, previous := filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), ".gz"), cacheFileName("", cacheKey)
+		for _, legacyPath := range []string{filepath.Join(dir, uncompressed), filepath.Join(dir, previous), filepath.Join(dir, strings.TrimSuffix(previous, ".gz")), filepath.Join(legacyCacheDir(), strings.TrimSuffix(previous, ".gz"))} {
+			if fileExists(legacyPath) {
+				fmt.Fprintf(out, "Legacy:     %s, moved to the cache file on the next search\n", legacyPath)
+				path = legacyPath
+				break
+			}
+		}
+	}
+
+	stat, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintln(out, "Exists:     no, the repos are fetched on the next search")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Exists:     yes")
+
+	data, err := readCacheFile(path)
+	if errors.Is(err, errCacheCorrupted) {
+		fmt.Fprintf(out, "Size:       %s\n", formatSize(stat.Size()))
+		fmt.Fprintf(out, "Repos:      none, %v and the repos are fetched on the next search\n", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != stat.Size() {
+		fmt.Fprintf(out, "Size:       %s, %s uncompressed\n", formatSize(stat.Size()), formatSize(int64(len(data))))
+	} else {
+		fmt.Fprintf(out, "Size:       %s\n", formatSize(stat.Size()))
+	}
+	if len(data) == 0 {
+		fmt.Fprintln(out, "Repos:      none, the cache file is empty and the repos are fetched on the next search")
+		return nil
+	}
+
+	cache, err := decodeCache(data)
+	if err != nil {
+		fmt.Fprintf(out, "Repos:      none, %v and the repos are fetched on the next search\n", err)
+		return nil
+	}
+	if cache.Version == 1 {
+		fmt.Fprintln(out, "Format:     bare list of repos of older versions, migrated the next time the repos are searched")
+	} else if cache.Version < CACHE_VERSION {
+		fmt.Fprintf(out, "Format:     version %d, written by gh stars v%s, migrated the next time the repos are searched\n", cache.Version, cache.Tool_version)
+	} else {
+		fmt.Fprintf(out, "Format:     version %d, written by gh stars v%s\n", cache.Version, cache.Tool_version)
+	}
+	if !cache.belongsTo(user) {
+		fmt.Fprintf(out, "Owner:      the %s of %s on %s, the repos are fetched on the next search\n", cache.Source, cache.User, cache.Host)
+	}
+	repos, _ := DecodeRepos(*bytes.NewBuffer(cache.Repos)) // Already decoded by decodeCache
+	fmt.Fprintf(out, "Repos:      %d\n", len(repos))
+	fetched := stat.ModTime()
+	if fetchedAt, err := time.Parse(time.RFC3339, cache.Fetched_at); err == nil {
+		fetched = fetchedAt.Local()
+	}
+	fmt.Fprintf(out, "Fetched:    %s, %s ago\n", fetched.Format(time.RFC3339), now.Sub(fetched).Round(time.Second))
+	return nil
+}
+
+// A cacheKeyInfo is the cache key of the repos of a user, as printed by cache key
+type cacheKeyInfo struct {
+	Key   string `json:"key"`
+	Path  string `json:"path"`
+	Count int    `json:"count"` // Number of repos, see generateCacheKey
+}
+
+// printCacheKey prints the cache key of the repos of the --source of the user, the path of
+// their cache file and their number, as JSON with --json. Only the newest repo is fetched,
+// the cache file isn't read nor created, see CachePath.
+func printCacheKey(out io.Writer, user string) error {
+	cacheKey, count, err := generateCacheKey(user)
+	if err != nil {
+		return err
+	}
+	path, err := CachePath(cacheKey)
+	if err != nil {
+		return err
+	}
+	info := cacheKeyInfo{Key: fmt.Sprintf("%x", cacheKey), Path: path, Count: count}
+	if jsonOutput {
+		data, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", data)
+		return nil
+	}
+	fmt.Fprintf(out, "Cache key:  %s\n", info.Key)
+	fmt.Fprintf(out, "Cache file: %s\n", info.Path)
+	fmt.Fprintf(out, "Repos:      %d\n", info.Count)
+	return nil
+}
+
+// readUsers returns the users listed one per line by the reader, skipping the blank lines
+// and the comments starting with #
+func readUsers(reader io.Reader) ([]string, error) {
+	var users []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			users = append(users, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// refreshCaches brings the cache file of the repos of the --source of every user up to
+// date, the way a search does, see GetStarredRepos, without searching them. The progress
+// and the time every user took are printed. Every user is refreshed even when one fails, the
+// error lists those that did.
+func refreshCaches(out io.Writer, users []string) error {
+	// The cache files are named after the user, see CachePath
+	defer func(current string) { user = current }(user)
+
+	var failed []string
+	for i, login := range users {
+		user = login
+		start := time.Now()
+		fmt.Fprintf(out, "[%d/%d] Refreshing the cache of the %s of %s\n", i+1, len(users), source, login)
+		count, err := refreshCache(login)
+		if err != nil {
+			fmt.Fprintf(out, "[%d/%d] Not able to refresh the cache of %s after %s: %v\n", i+1, len(users), login, time.Since(start).Round(time.Millisecond), err)
+			failed = append(failed, login)
+			continue
+		}
+		fmt.Fprintf(out, "[%d/%d] Cached the %d repos of %s in %s\n", i+1, len(users), count, login, time.Since(start).Round(time.Millisecond))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("not able to refresh the cache of %d of the %d users: %s", len(failed), len(users), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// refreshCache brings the cache file of the user up to date, see refreshCaches, and
+// returns the number of repos it holds. The cache file is checked with GitHub whatever its
+// age, it expires at once, see --cache-ttl.
+func refreshCache(login string) (int, error) {
+	defer func(current time.Duration) { cacheTTL = current }(cacheTTL)
+	cacheTTL = time.Nanosecond
+
+	cacheKey, err := GenerateCacheKey(login)
+	if err != nil {
+		return 0, err
+	}
+	starred, err := GetStarredRepos(login, cacheKey)
+	if err != nil {
+		return 0, err
+	}
+	repos, err := DecodeRepos(starred)
+	if err != nil {
+		return 0, err
+	}
+	return len(repos), nil
+}
+
+// formatSize returns a number of bytes in the largest unit it has at least one of
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, units := float64(size)/unit, "KMGT"
+	for value >= unit && len(units) > 1 {
+		value, units = value/unit, units[1:]
+	}
+	return fmt.Sprintf("%.1f %cB", value, units[0])
+}
+
+// sizeUnits maps the units of parseSize to their number of bytes
+var sizeUnits = map[string]int64{"": 1, "b": 1, "k": 1 << 10, "kb": 1 << 10, "m": 1 << 20, "mb": 1 << 20, "g": 1 << 30, "gb": 1 << 30}
+
+// parseSize returns the number of bytes of a size written like formatSize does, a number
+// followed by one of the units B, KB, MB or GB, case insensitive. A number without unit is
+// a number of bytes.
+//
+// Example: 200MB, 1.5 GB, 512k or 4096
+func parseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	digits := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if digits == -1 {
+		digits = len(value)
+	}
+	number, err := strconv.ParseFloat(value[:digits], 64)
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(value[digits:]))]
+	if err != nil || !ok {
+		return 0, fmt.Errorf("%q is not a size, use a number of bytes followed by B, KB, MB or GB like 200MB", value)
+	}
+	return int64(number * float64(unit)), nil
+}
+
+// A sizeFlag is the value of a flag taking a size, see parseSize, only set once the flag is
+// provided. Invalid sizes are rejected when the flags are parsed.
+type sizeFlag struct {
+	text  string
+	value int64
+	set   bool
+}
+
+func (s *sizeFlag) String() string {
+	return s.text
+}
+
+func (s *sizeFlag) Set(value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	s.text, s.value, s.set = value, size, true
+	return nil
+}
+
+func (s *sizeFlag) Type() string {
+	return "size"
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache files of gh stars",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the cache files of gh stars",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var prefixes []string
+		if user != "" {
+			var err error
+			if prefixes, err = userCachePrefixes(user); err != nil {
+				ErrorLogger.Fatal("Not able to get the cache keys of the user ", err)
+			}
+		}
+		if err := clearCache(os.Stdout, cacheDirs(), prefixes, dryRun); err != nil {
+			ErrorLogger.Fatal("Not able to clear the cache", err)
+		}
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the cache files of gh stars unused for a while",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !olderThan.set() {
+			ErrorLogger.Fatal("The --older-than flag is required. See gh stars cache --help for more information")
+		}
+		if err := pruneCache(os.Stdout, cacheDirs(), olderThan.time, dryRun); err != nil {
+			ErrorLogger.Fatal("Not able to prune the cache ", err)
+		}
+	},
+}
+
+var cacheRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Bring the cache files of users up to date without searching",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		users := refreshUsers
+		if usersFile != "" {
+			reader := os.Stdin
+			if usersFile != "-" {
+				file, err := os.Open(usersFile)
+				if err != nil {
+					ErrorLogger.Fatal("Not able to read the users ", err)
+				}
+				defer file.Close()
+				reader = file
+			}
+			listed, err := readUsers(reader)
+			if err != nil {
+				ErrorLogger.Fatal("Not able to read the users ", err)
+			}
+			users = append(users, listed...)
+		}
+		if len(users) == 0 {
+			login, err := AuthenticatedUser()
+			if err != nil {
+				InfoLogger.Println("Not able to get the authenticated user", err)
+				ErrorLogger.Fatal("The --user, -u or --users-file flag is required when gh is not logged in. See gh stars cache --help for more information")
+			}
+			users = []string{login}
+		}
+		if _, ok := sources[source]; !ok {
+			ErrorLogger.Fatal(fmt.Sprintf("--source must be one of %s, got: %q", strings.Join(sourceNames(), ", "), source))
+		}
+		if err := refreshCaches(os.Stderr, users); err != nil {
+			ErrorLogger.Fatal(err)
+		}
+	},
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Print where the cache file of a user is and what it holds",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if user == "" {
+			login, err := AuthenticatedUser()
+			if err != nil {
+				InfoLogger.Println("Not able to get the authenticated user", err)
+				ErrorLogger.Fatal("The --user, -u flag is required when gh is not logged in. See gh stars cache --help for more information")
+			}
+			user = login
+		}
+		if _, ok := sources[source]; !ok {
+			ErrorLogger.Fatal(fmt.Sprintf("--source must be one of %s, got: %q", strings.Join(sourceNames(), ", "), source))
+		}
+		cacheKey, err := GenerateCacheKey(user)
+		if err != nil {
+			ErrorLogger.Fatal("Not able to generate the cache key ", err)
+		}
+		if err := printCacheInfo(os.Stdout, user, cacheKey, time.Now()); err != nil {
+			ErrorLogger.Fatal("Not able to read the cache file ", err)
+		}
+	},
+}
+
+var cacheKeyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Print the cache key of a user and the path of its cache file without fetching the repos",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if user == "" {
+			login, err := AuthenticatedUser()
+			if err != nil {
+				InfoLogger.Println("Not able to get the authenticated user", err)
+				ErrorLogger.Fatal("The --user, -u flag is required when gh is not logged in. See gh stars cache --help for more information")
+			}
+			user = login
+		}
+		if _, ok := sources[source]; !ok {
+			ErrorLogger.Fatal(fmt.Sprintf("--source must be one of %s, got: %q", strings.Join(sourceNames(), ", "), source))
+		}
+		if err := printCacheKey(os.Stdout, user); err != nil {
+			ErrorLogger.Fatal("Not able to generate the cache key ", err)
+		}
+	},
+}
+
+func init() {
+	//  Commands:
+	//   cache clear
+	//     Delete the cache files in the default cache directory
+	//   -u, --user <handle>
+	//     Only delete the cache files of this user
+	//   --dry-run
+	//     List the cache files without deleting them
+	//   cache info
+	//     Print the cache file of a user, whether it exists, its size, the number of repos, when
+	//     they were fetched and the cache key
+	//   -u, --user <handle>
+	//     Any GitHub handle. Default is the user gh is logged in as
+	//   -c, --cache-file <file path>
+	//     The cache file provided to the searches, if any
+	//   --source <source>
+	//     The repositories cached: stars, watching or owned. Default is stars
+	//   cache key
+	//     Print the cache key of a user, the path of its cache file and the number of repos, only
+	//     fetching the newest one
+	//   -u, --user <handle>
+	//     Any GitHub handle. Default is the user gh is logged in as
+	//   -c, --cache-file <file path>
+	//     The cache file provided to the searches, if any
+	//   --source <source>
+	//     The repositories cached: stars, watching or owned. Default is stars
+	//   -j, --json
+	//     Prints the key, the path and the number of repos in JSON format
+	//   cache prune
+	//     Delete the cache files of every user unused for a while, along with the caches next to
+	//     them, printing every file deleted and the total size reclaimed
+	//   --older-than <date>
+	//     Only the cache files neither fetched nor written since, e.g. 30d, 2w, 6mo, 1y or 2023-01-01
+	//   --dry-run
+	//     List the cache files without deleting them
+	//   cache refresh
+	//     Bring the cache files of users up to date without searching, printing the progress to
+	//     stderr, and exit with an error when one of them couldn't be
+	//   -u, --user <handle>
+	//     A GitHub handle, repeat it for every user. Default is the user gh is logged in as
+	//   --users-file <file path>
+	//     A file listing a GitHub handle per line, - reads them from stdin
+	//   --source <source>
+	//     The repositories cached: stars, watching or owned. Default is stars
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd, cacheInfoCmd, cacheKeyCmd, cachePruneCmd, cacheRefreshCmd)
+	cacheClearCmd.PreRun = rootCmd.PreRun
+	cacheInfoCmd.PreRun = rootCmd.PreRun
+	cacheKeyCmd.PreRun = rootCmd.PreRun
+	cachePruneCmd.PreRun = rootCmd.PreRun
+	cacheRefreshCmd.PreRun = rootCmd.PreRun
+	cacheClearCmd.Flags().StringVarP(&user, "user", "u", "", "Only delete the cache files of this GitHub handle, default: every cache file")
+	cacheClearCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the cache files without deleting them, default: false")
+	cacheClearCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enables debug mode, default: false")
+	cacheInfoCmd.Flags().StringVarP(&user, "user", "u", "", "Any GitHub handle, default: the user gh is logged in as")
+	cacheInfoCmd.Flags().StringVarP(&cacheFile, "cache-file", "c", "", "The cache file provided to the searches, default: the one generated in the cache directory")
+	cacheInfoCmd.Flags().StringVar(&source, "source", "stars", "The repositories cached: stars, watching or owned, default: stars")
+	cacheInfoCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enables debug mode, default: false")
+	cacheKeyCmd.Flags().StringVarP(&user, "user", "u", "", "Any GitHub handle, default: the user gh is logged in as")
+	cacheKeyCmd.Flags().StringVarP(&cacheFile, "cache-file", "c", "", "The cache file provided to the searches, default: the one generated in the cache directory")
+	cacheKeyCmd.Flags().StringVar(&source, "source", "stars", "The repositories cached: stars, watching or owned, default: stars")
+	cacheKeyCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "Prints the key, the path and the number of repos in JSON format, default: false")
+	cacheKeyCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enables debug mode, default: false")
+	cachePruneCmd.Flags().Var(&olderThan, "older-than", "Only delete the cache files neither fetched nor written since, e.g. 30d, 2w, 6mo, 1y or 2023-01-01")
+	cachePruneCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the cache files without deleting them, default: false")
+	cachePruneCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enables debug mode, default: false")
+	cacheRefreshCmd.Flags().StringArrayVarP(&refreshUsers, "user", "u", nil, "A GitHub handle, repeat it for every user, default: the user gh is logged in as")
+	cacheRefreshCmd.Flags().StringVar(&usersFile, "users-file", "", "A file listing a GitHub handle per line, - reads them from stdin")
+	cacheRefreshCmd.Flags().StringVar(&source, "source", "stars", "The repositories cached: stars, watching or owned, default: stars")
+	cacheRefreshCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enables debug mode, default: false")
+	cacheCmd.SetHelpTemplate(getCacheHelp())
+}
+
+func getCacheHelp() string {
+
+	return `
+Manage the cache files gh stars writes when --cache-file isn't provided, in $GH_STARS_CACHE_DIR
+or the gh-stars directory of the user cache directory, e.g. ~/.cache/gh-stars on Linux.
+
+Synoposis:
+	gh stars cache clear [-u <handle>] [--dry-run]
+	gh stars cache info [-u <handle>] [-c <file path>] [--source <source>]
+	gh stars cache key [-u <handle>] [-c <file path>] [--source <source>] [--json]
+	gh stars cache prune --older-than <date> [--dry-run]
+	gh stars cache refresh [-u <handle>]... [--users-file <file path>] [--source <source>]
+
+Commands:
+
+	clear                        Delete the cache files, printing every file deleted and the total size reclaimed
+	                             Only the files named like the cache files of gh stars are deleted
+	info                         Print the cache file of a user, whether it exists, its size, the number of
+	                             repositories it holds, when they were fetched and the cache key
+	key                          Print the cache key of a user, the path of its cache file and the number of
+	                             repositories, only fetching the newest one. The cache file isn't read nor created
+	prune                        Delete the cache files of every user neither fetched nor written since --older-than,
+	                             along with the caches next to them, printing every file deleted and the total size reclaimed
+	refresh                      Bring the cache files of users up to date without searching, printing the progress
+	                             to stderr. Exits with an error when the cache of one of them couldn't be
+
+Flags of clear:
+
+	Optional:
+	-u, --user <handle>          Only delete the cache files of this GitHub handle
+	--dry-run                    List the cache files without deleting them
+	-d, --debug                  Enables debug mode
+	-h, --help                   Show this message and exit
+
+Flags of info:
+
+	Optional:
+	-u, --user <handle>          Any GitHub handle, e.g. Link-. Defaults to the user gh is logged in as
+	-c, --cache-file <file path> The cache file provided to the searches, if any
+	--source <source>            The repositories cached: stars, watching or owned, default: stars
+	-d, --debug                  Enables debug mode
+	-h, --help                   Show this message and exit
+
+Flags of key:
+
+	Optional:
+	-u, --user <handle>          Any GitHub handle, e.g. Link-. Defaults to the user gh is logged in as
+	-c, --cache-file <file path> The cache file provided to the searches, if any
+	--source <source>            The repositories cached: stars, watching or owned, default: stars
+	-j, --json                   Prints the key, the path and the number of repositories in JSON format
+	-d, --debug                  Enables debug mode
+	-h, --help                   Show this message and exit
+
+Flags of prune:
+
+	Required:
+	--older-than <date>          Only delete the cache files unused since, e.g. 30d, 2w, 6mo, 1y or 2023-01-01
+
+	Optional:
+	--dry-run                    List the cache files without deleting them
+	-d, --debug                  Enables debug mode
+	-h, --help                   Show this message and exit
+
+Flags of refresh:
+
+	Optional:
+	-u, --user <handle>          A GitHub handle, repeat it for every user. Defaults to the user gh is logged in as
+	--users-file <file path>     A file listing a GitHub handle per line, - reads them from stdin
+	--source <source>            The repositories cached: stars, watching or owned, default: stars
+	-d, --debug                  Enables debug mode
+	-h, --help                   Show this message and exit
+
+Examples:
+
+	# List the cache files of every user without deleting them
+	gh stars cache clear --dry-run
+
+	# Delete the cache files of Link-
+	gh stars cache clear -u Link-
+
+	# Check when the stars of Link- were fetched, when a search misses a repository starred recently
+	gh stars cache info -u Link-
+
+	# Key a CI cache of the stars of Link- by the same value as gh stars
+	gh stars cache key -u Link- --json | jq -r .key
+
+	# Delete the cache files unused for a month, e.g. from a weekly cron job
+	gh stars cache prune --older-than 30d
+
+	# Keep the caches of Link- and knbr13 warm, e.g. from a cron job
+	gh stars cache refresh -u Link- -u knbr13
+`
+}
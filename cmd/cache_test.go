@@ -0,0 +1,1343 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCacheDir fills a temporary cache directory with the files of two users and a few
+// files gh-stars never writes, and returns it
+func writeCacheDir(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"stars_2d06a89b2687.json":                "[{}]",
+		"owned_2d06a89b2687.json.gz":             "gz",
+		"stars_2d06a89b2687.readme.json":         "{}",
+		"stars_2d06a89b2687.index":               "index",
+		"stars_2d06a89b2687.list_UL_kwDOAB.json": "[]",
+		"watching_d856442b086a.json":             "[]",
+		"gists_0123456789ab.json":                "[]",
+		// Not written by gh-stars
+		"stars_2d06a89b2687.json.bak": "[]",
+		"stars_nothex.json":           "[]",
+		"notes.txt":                   "keep me",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	// A directory and a symlink named like cache files are never deleted
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "owned_aaaaaaaaaaaa.json"), 0755))
+	outside := filepath.Join(t.TempDir(), "outside.json")
+	assert.NoError(t, os.WriteFile(outside, []byte("[]"), 0644))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(dir, "owned_bbbbbbbbbbbb.json")))
+	return dir
+}
+
+func cacheFileNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestFindCacheFiles(t *testing.T) {
+	dir := writeCacheDir(t)
+
+	files, err := findCacheFiles(dir, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []cacheEntry{
+		{path: filepath.Join(dir, "gists_0123456789ab.json"), size: 2},
+		{path: filepath.Join(dir, "owned_2d06a89b2687.json.gz"), size: 2},
+		{path: filepath.Join(dir, "stars_2d06a89b2687.index"), size: 5},
+		{path: filepath.Join(dir, "stars_2d06a89b2687.json"), size: 4},
+		{path: filepath.Join(dir, "stars_2d06a89b2687.list_UL_kwDOAB.json"), size: 2},
+		{path: filepath.Join(dir, "stars_2d06a89b2687.readme.json"), size: 2},
+		{path: filepath.Join(dir, "watching_d856442b086a.json"), size: 2},
+	}, files)
+
+	// Only the files of the given cache keys
+	files, err = findCacheFiles(dir, []string{"watching_d856442b086a.", "owned_bbbbbbbbbbbb."})
+	assert.NoError(t, err)
+	assert.Equal(t, []cacheEntry{{path: filepath.Join(dir, "watching_d856442b086a.json"), size: 2}}, files)
+
+	_, err = findCacheFiles(filepath.Join(dir, "missing"), nil)
+	assert.Error(t, err)
+}
+
+func TestClearCache(t *testing.T) {
+	t.Run("DryRun", func(t *testing.T) {
+		dir := writeCacheDir(t)
+		before := cacheFileNames(t, dir)
+		var out bytes.Buffer
+		assert.NoError(t, clearCache(&out, []string{dir}, nil, true))
+		assert.Equal(t, before, cacheFileNames(t, dir))
+		assert.Contains(t, out.String(), "Would delete "+filepath.Join(dir, "stars_2d06a89b2687.json")+" (4 B)\n")
+		assert.Contains(t, out.String(), "Would delete 7 cache file(s), 19 B reclaimed\n")
+	})
+
+	t.Run("Every", func(t *testing.T) {
+		dir := writeCacheDir(t)
+		// The lock may be held by a search, see lockCacheFile
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "owned_2d06a89b2687.json.gz.lock"), nil, 0644))
+		var out bytes.Buffer
+		assert.NoError(t, clearCache(&out, []string{dir}, nil, false))
+		assert.ElementsMatch(t, []string{"stars_2d06a89b2687.json.bak", "stars_nothex.json", "notes.txt", "owned_aaaaaaaaaaaa.json", "owned_bbbbbbbbbbbb.json", "owned_2d06a89b2687.json.gz.lock"}, cacheFileNames(t, dir))
+		assert.Contains(t, out.String(), "Deleted "+filepath.Join(dir, "gists_0123456789ab.json")+" (2 B)\n")
+		assert.Contains(t, out.String(), "Deleted 7 cache file(s), 19 B reclaimed\n")
+	})
+
+	t.Run("User", func(t *testing.T) {
+		dir := writeCacheDir(t)
+		var out bytes.Buffer
+		assert.NoError(t, clearCache(&out, []string{dir}, []string{"stars_2d06a89b2687."}, false))
+		assert.ElementsMatch(t, []string{"watching_d856442b086a.json", "gists_0123456789ab.json", "owned_2d06a89b2687.json.gz", "stars_2d06a89b2687.json.bak", "stars_nothex.json", "notes.txt", "owned_aaaaaaaaaaaa.json", "owned_bbbbbbbbbbbb.json"}, cacheFileNames(t, dir))
+		assert.Contains(t, out.String(), "Deleted 4 cache file(s), 13 B reclaimed\n")
+	})
+
+	t.Run("NamedAfterTheUser", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"stars_link-_2d06a89b2687.json.gz", "stars_link-_aaaaaaaaaaaa.readme.json", "stars_link-x_2d06a89b2687.json.gz", "stars_2d06a89b2687.json.gz", "watching_link-_bbbbbbbbbbbb.index"} {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("[]"), 0644))
+		}
+		var out bytes.Buffer
+		assert.NoError(t, clearCache(&out, []string{dir}, []string{"stars_link-_", "stars_2d06a89b2687."}, false))
+		assert.ElementsMatch(t, []string{"stars_link-x_2d06a89b2687.json.gz", "watching_link-_bbbbbbbbbbbb.index"}, cacheFileNames(t, dir))
+		assert.Contains(t, out.String(), "Deleted 3 cache file(s), 6 B reclaimed\n")
+	})
+
+	t.Run("LegacyDirectory", func(t *testing.T) {
+		dir, legacyDir := writeCacheDir(t), writeCacheDir(t)
+		var out bytes.Buffer
+		assert.NoError(t, clearCache(&out, []string{dir, legacyDir}, []string{"gists_0123456789ab."}, false))
+		assert.False(t, fileExists(filepath.Join(dir, "gists_0123456789ab.json")))
+		assert.False(t, fileExists(filepath.Join(legacyDir, "gists_0123456789ab.json")))
+		assert.Contains(t, out.String(), "Deleted 2 cache file(s), 4 B reclaimed\n")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		dir, missing := t.TempDir(), filepath.Join(t.TempDir(), "missing")
+		var out bytes.Buffer
+		assert.NoError(t, clearCache(&out, []string{dir, missing}, nil, false))
+		assert.Equal(t, "No cache file found in "+dir+" nor "+missing+"\n", out.String())
+	})
+}
+
+func TestPruneCache(t *testing.T) {
+	now := time.Now()
+	old, recent := now.AddDate(0, 0, -40), now.AddDate(0, 0, -2)
+	write := func(t *testing.T, dir string, name string, data []byte, written time.Time) {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, data, 0644))
+		assert.NoError(t, os.Chtimes(path, written, written))
+	}
+	writeDir := func(t *testing.T) string {
+		dir := t.TempDir()
+		// Unused for 40 days, along with the caches next to it
+		write(t, dir, "stars_link-_aaaaaaaaaaaa.json.gz", []byte("[]"), old)
+		write(t, dir, "stars_link-_aaaaaaaaaaaa.etag.json", []byte("{}"), old)
+		write(t, dir, "stars_link-_aaaaaaaaaaaa.index", []byte("index"), old)
+		write(t, dir, "stars_link-_aaaaaaaaaaaa.json.gz.lock", nil, old)
+		// Checked with GitHub 2 days ago, through its validator
+		write(t, dir, "stars_knbr13_bbbbbbbbbbbb.json.gz", []byte("[]"), old)
+		write(t, dir, "stars_knbr13_bbbbbbbbbbbb.etag.json", []byte("{}"), recent)
+		write(t, dir, "gists_0123456789ab.json", []byte("[]"), old)
+		write(t, dir, "watching_d856442b086a.json", []byte("[]"), recent)
+		write(t, dir, "notes.txt", []byte("notes"), old)
+		return dir
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		dir := writeDir(t)
+		before := cacheFileNames(t, dir)
+		var out bytes.Buffer
+		assert.NoError(t, pruneCache(&out, []string{dir}, now.AddDate(0, 0, -30), true))
+		assert.Equal(t, before, cacheFileNames(t, dir))
+		assert.Contains(t, out.String(), "Would delete "+filepath.Join(dir, "stars_link-_aaaaaaaaaaaa.index")+" (5 B)\n")
+		assert.Contains(t, out.String(), "Would delete 4 cache file(s), 11 B reclaimed\n")
+	})
+
+	t.Run("OlderThan", func(t *testing.T) {
+		dir := writeDir(t)
+		var out bytes.Buffer
+		assert.NoError(t, pruneCache(&out, []string{dir}, now.AddDate(0, 0, -30), false))
+		// The lock may be held by a search, it is kept
+		assert.ElementsMatch(t, []string{"stars_link-_aaaaaaaaaaaa.json.gz.lock", "stars_knbr13_bbbbbbbbbbbb.json.gz", "stars_knbr13_bbbbbbbbbbbb.etag.json", "watching_d856442b086a.json", "notes.txt"}, cacheFileNames(t, dir))
+		assert.Contains(t, out.String(), "Deleted 4 cache file(s), 11 B reclaimed\n")
+	})
+
+	t.Run("FetchedRecently", func(t *testing.T) {
+		dir := t.TempDir()
+		setup([]string{})
+		defer func(current string) { source = current }(source)
+		source = "stars"
+		cache, err := newCacheEnvelope("Link-", []byte(`[{"full_name": "spf13/cobra"}]`))
+		assert.NoError(t, err)
+		data, err := json.Marshal(cache)
+		assert.NoError(t, err)
+		path := filepath.Join(dir, "stars_link-_aaaaaaaaaaaa.json.gz")
+		assert.NoError(t, writeCacheFile(path, data))
+		// The modification time was lost, e.g. restoring a CI cache
+		assert.NoError(t, os.Chtimes(path, old, old))
+
+		var out bytes.Buffer
+		assert.NoError(t, pruneCache(&out, []string{dir}, now.AddDate(0, 0, -30), false))
+		assert.True(t, fileExists(path))
+		assert.Equal(t, "No cache file unused since "+now.AddDate(0, 0, -30).Format(time.RFC3339)+" found in "+dir+"\n", out.String())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		dir, missing := writeDir(t), filepath.Join(t.TempDir(), "missing")
+		var out bytes.Buffer
+		assert.NoError(t, pruneCache(&out, []string{dir, missing}, now.AddDate(0, 0, -60), false))
+		assert.Len(t, cacheFileNames(t, dir), 9)
+		assert.Contains(t, out.String(), " found in "+dir+" nor "+missing+"\n")
+	})
+}
+
+func TestCacheDir(t *testing.T) {
+	home := t.TempDir()
+	setHomeDir(t, home)
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv(CACHE_DIR_ENV, "")
+	userCacheDir, err := os.UserCacheDir()
+	assert.NoError(t, err)
+
+	dir, err := cacheDir()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(userCacheDir, "gh-stars"), dir)
+	assert.True(t, strings.HasPrefix(dir, home))
+
+	t.Setenv(CACHE_DIR_ENV, filepath.Join(home, "elsewhere"))
+	dir, err = cacheDir()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "elsewhere"), dir)
+}
+
+func TestCachePathPrecedence(t *testing.T) {
+	setup([]string{})
+	home := t.TempDir()
+	setHomeDir(t, home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "xdg"))
+	t.Setenv(CACHE_DIR_ENV, "")
+	cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+	userCacheDir, err := os.UserCacheDir()
+	assert.NoError(t, err)
+
+	// The user cache directory, e.g. $XDG_CACHE_HOME on Linux
+	path, err := GetCachePath(cacheKey)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(userCacheDir, "gh-stars", "stars_2d06a89b2687.json.gz"), path)
+
+	// $GH_STARS_CACHE_DIR, created when missing
+	envDir := filepath.Join(home, "ci", "cache")
+	t.Setenv(CACHE_DIR_ENV, envDir)
+	path, err = GetCachePath(cacheKey)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(envDir, "stars_2d06a89b2687.json.gz"), path)
+	assert.DirExists(t, envDir)
+	assert.Empty(t, cacheFileNames(t, envDir), "nothing is left behind")
+
+	// --cache-file
+	cacheFile = filepath.Join(home, "stars.json")
+	defer func() { cacheFile = "" }()
+	path, err = GetCachePath(cacheKey)
+	assert.NoError(t, err)
+	assert.Equal(t, cacheFile, path)
+}
+
+func TestCacheFileDir(t *testing.T) {
+	setup([]string{})
+	home := t.TempDir()
+	setHomeDir(t, home)
+	defer func() { cacheFile, cacheReadOnly = "", false }()
+
+	// The missing directories of --cache-file are created, not the cache file
+	cacheFile = filepath.Join(home, "ci", "cache", "stars.json")
+	path, err := GetCachePath([32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, cacheFile, path)
+	assert.DirExists(t, filepath.Join(home, "ci", "cache"))
+	assert.NoFileExists(t, path)
+
+	// The leading ~ is the home directory
+	cacheFile = "~/.cache/gh-stars/stars.json"
+	path, err = GetCachePath([32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".cache", "gh-stars", "stars.json"), path)
+	assert.DirExists(t, filepath.Join(home, ".cache", "gh-stars"))
+	gistsPath, err := GetGistsPath([32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".cache", "gh-stars", "stars.gists.json"), gistsPath)
+
+	// The error tells which directory can't be created
+	assert.NoError(t, os.WriteFile(filepath.Join(home, "file"), nil, 0644))
+	cacheFile = filepath.Join(home, "file", "cache", "stars.json")
+	_, err = GetCachePath([32]byte{})
+	assert.ErrorContains(t, err, "not able to create the directory "+filepath.Join(home, "file", "cache")+" of the cache file "+cacheFile)
+
+	// Nothing is created in a read-only cache
+	cacheReadOnly = true
+	cacheFile = filepath.Join(home, "read-only", "stars.json")
+	_, err = GetCachePath([32]byte{})
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(home, "read-only"))
+}
+
+func TestExpandHome(t *testing.T) {
+	home := t.TempDir()
+	setHomeDir(t, home)
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "~", want: home},
+		{path: "~/stars.json", want: filepath.Join(home, "stars.json")},
+		{path: "~link-/stars.json", want: "~link-/stars.json"},
+		{path: "cache/~/stars.json", want: "cache/~/stars.json"},
+		{path: "/tmp/stars.json", want: "/tmp/stars.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := expandHome(tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, path)
+		})
+	}
+}
+
+func TestPrepareCacheFileNotWritable(t *testing.T) {
+	setup([]string{})
+	notADir := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(notADir, nil, 0644))
+
+	t.Setenv(CACHE_DIR_ENV, filepath.Join(notADir, "cache"))
+	_, err := GetCachePath([32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87})
+	assert.ErrorContains(t, err, "the cache directory "+filepath.Join(notADir, "cache")+" set by GH_STARS_CACHE_DIR is not writable: ")
+
+	t.Setenv(CACHE_DIR_ENV, "")
+	assert.ErrorContains(t, prepareCacheFile(filepath.Join(notADir, "cache", "stars.json.gz"), "stars.json.gz"), "the cache directory "+filepath.Join(notADir, "cache")+" is not writable: ")
+}
+
+func TestPrepareCacheFile(t *testing.T) {
+	setup([]string{})
+	setHomeDir(t, t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv(CACHE_DIR_ENV, "")
+	setTempDir(t, t.TempDir())
+	cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+	dir, err := cacheDir()
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "stars_2d06a89b2687.json.gz")
+
+	t.Run("CreatesTheDirectory", func(t *testing.T) {
+		got, err := GetCachePath(cacheKey)
+		assert.NoError(t, err)
+		assert.Equal(t, path, got)
+		assert.DirExists(t, dir)
+		assert.False(t, fileExists(path), "the cache file is created once the repos are written")
+		assert.NoError(t, os.RemoveAll(dir))
+	})
+
+	t.Run("MovesTheLegacyFiles", func(t *testing.T) {
+		legacy := map[string]string{
+			"stars_2d06a89b2687.json":        `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`,
+			"stars_2d06a89b2687.readme.json": "{}",
+			"watching_2d06a89b2687.json":     "[]",
+		}
+		for name, content := range legacy {
+			assert.NoError(t, os.WriteFile(filepath.Join(os.TempDir(), name), []byte(content), 0644))
+		}
+		written := time.Now().Add(-time.Hour).Truncate(time.Second)
+		assert.NoError(t, os.Chtimes(filepath.Join(os.TempDir(), "stars_2d06a89b2687.json"), written, written))
+
+		got, err := GetStarredRepos("Link-", cacheKey)
+		assert.NoError(t, err)
+		assert.Equal(t, `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`, got.String())
+		// The cache file is compressed and keeps its age, the caches next to it are moved as is
+		assert.ElementsMatch(t, []string{"stars_2d06a89b2687.json.gz", "stars_2d06a89b2687.json.gz.lock", "stars_2d06a89b2687.readme.json"}, cacheFileNames(t, dir))
+		cache, err := readCache(path)
+		assert.NoError(t, err)
+		assert.Equal(t, written.UTC().Format(time.RFC3339), cache.Fetched_at)
+		readme, err := os.ReadFile(filepath.Join(dir, "stars_2d06a89b2687.readme.json"))
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", string(readme))
+		// Only the files of the cache key are moved
+		assert.Equal(t, []string{"watching_2d06a89b2687.json"}, cacheFileNames(t, os.TempDir()))
+	})
+
+	t.Run("KeepsTheNewFile", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(filepath.Join(os.TempDir(), "stars_2d06a89b2687.json"), []byte("[]"), 0644))
+		got, err := GetStarredRepos("Link-", cacheKey)
+		assert.NoError(t, err)
+		assert.Equal(t, `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`, got.String())
+		assert.True(t, fileExists(filepath.Join(os.TempDir(), "stars_2d06a89b2687.json")))
+	})
+
+	t.Run("CompressesTheUncompressedFile", func(t *testing.T) {
+		assert.NoError(t, os.Remove(path))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "stars_2d06a89b2687.json"), []byte(`[{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`), 0644))
+		got, err := GetStarredRepos("Link-", cacheKey)
+		assert.NoError(t, err)
+		assert.Equal(t, `[{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`, got.String())
+		assert.False(t, fileExists(filepath.Join(dir, "stars_2d06a89b2687.json")))
+		// The legacy file is left behind, the one in the cache directory is newer
+		assert.True(t, fileExists(filepath.Join(os.TempDir(), "stars_2d06a89b2687.json")))
+	})
+
+	t.Run("RenamesTheFilesWithoutTheUser", func(t *testing.T) {
+		assert.NoError(t, os.RemoveAll(dir))
+		assert.NoError(t, os.MkdirAll(dir, 0755))
+		unnamed := map[string]string{
+			"stars_2d06a89b2687.json.gz.lock": "",
+			"stars_2d06a89b2687.readme.json":  "{}",
+			"stars_2d06a89b2687.etag.json":    `{"etag":"\"abc\""}`,
+		}
+		for name, content := range unnamed {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		}
+		assert.NoError(t, writeCacheFile(filepath.Join(dir, "stars_2d06a89b2687.json.gz"), []byte(`[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`)))
+		user = "Link-"
+		defer func() { user = "" }()
+
+		got, err := GetCachePath(cacheKey)
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "stars_link-_2d06a89b2687.json.gz"), got)
+		assert.ElementsMatch(t, []string{"stars_link-_2d06a89b2687.json.gz", "stars_link-_2d06a89b2687.json.gz.lock", "stars_link-_2d06a89b2687.readme.json", "stars_link-_2d06a89b2687.etag.json"}, cacheFileNames(t, dir))
+		cache, err := readCache(got)
+		assert.NoError(t, err)
+		assert.Equal(t, `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`, string(cache.Repos))
+		validator, err := readCacheValidator(got)
+		assert.NoError(t, err)
+		assert.Equal(t, `"abc"`, validator.Etag)
+
+		// The cache file of the user is kept when one without the user is left
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "stars_2d06a89b2687.readme.json"), []byte("[]"), 0644))
+		_, err = GetCachePath(cacheKey)
+		assert.NoError(t, err)
+		readme, err := os.ReadFile(filepath.Join(dir, "stars_link-_2d06a89b2687.readme.json"))
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", string(readme))
+	})
+
+	t.Run("CacheFileProvided", func(t *testing.T) {
+		cacheFile = filepath.Join(t.TempDir(), "stars.json")
+		defer func() { cacheFile = "" }()
+		assert.NoError(t, os.RemoveAll(dir))
+		_, err := GetCachePath(cacheKey)
+		assert.NoError(t, err)
+		assert.False(t, fileExists(dir))
+	})
+}
+
+func TestReadCacheFile(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte(`[{"name":"cobra"}]`)
+
+	// Compressed when the name ends with .gz
+	compressed := filepath.Join(dir, "stars.json.gz")
+	assert.NoError(t, writeCacheFile(compressed, content))
+	data, err := os.ReadFile(compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x1f, 0x8b}, data[:2])
+	got, err := readCacheFile(compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	// Uncompressed otherwise, and read as is
+	uncompressed := filepath.Join(dir, "stars.json")
+	assert.NoError(t, writeCacheFile(uncompressed, content))
+	data, err = os.ReadFile(uncompressed)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	got, err = readCacheFile(uncompressed)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	// A compressed file isn't empty, what it holds is
+	empty := filepath.Join(dir, "empty.json.gz")
+	assert.NoError(t, writeCacheFile(empty, nil))
+	data, err = os.ReadFile(empty)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, data)
+	got, err = readCacheFile(empty)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	// A truncated file is corrupted
+	truncated := filepath.Join(dir, "truncated.json.gz")
+	compressedData, err := os.ReadFile(compressed)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(truncated, compressedData[:len(compressedData)-6], 0644))
+	_, err = readCacheFile(truncated)
+	assert.ErrorIs(t, err, errCacheCorrupted)
+
+	_, err = readCacheFile(filepath.Join(dir, "missing.json.gz"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Equal(t, filepath.Join(dir, "stars"), trimCacheExt(compressed))
+	assert.Equal(t, filepath.Join(dir, "stars"), trimCacheExt(uncompressed))
+}
+
+func TestDecodeCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantVersion int
+		wantRepos   string
+		wantErr     bool
+	}{
+		{name: "Empty", data: " \n"},
+		{name: "Legacy", data: `[{"name":"cobra"}]`, wantVersion: 1, wantRepos: `[{"name":"cobra"}]`},
+		{name: "Envelope", data: `{"version":2,"user":"Link-","repos":[{"name":"cobra"}]}`, wantVersion: 2, wantRepos: `[{"name":"cobra"}]`},
+		{name: "NoRepo", data: `{"version":2,"user":"Link-","repos":[]}`, wantVersion: 2, wantRepos: `[]`},
+		{name: "Truncated", data: `{"version":2,"user":"Link-","repos":[{"na`, wantErr: true},
+		{name: "MissingRepos", data: `{"version":2,"user":"Link-"}`, wantErr: true},
+		{name: "TruncatedLegacy", data: `[{"name":"cobra"},{"na`, wantErr: true},
+		{name: "Garbage", data: "\x00\x00\x00\x00", wantErr: true},
+		{name: "NotRepos", data: `{"version":2,"user":"Link-","repos":{"name":"cobra"}}`, wantErr: true},
+		{name: "MistypedRepo", data: `[{"name":"cobra","stargazers_count":"many"}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := decodeCache([]byte(tt.data))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errCacheCorrupted)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, cache.Version)
+			assert.Equal(t, tt.wantRepos, string(cache.Repos))
+		})
+	}
+}
+
+func TestCacheEnvelope(t *testing.T) {
+	setup([]string{})
+	api := useStarsAPI(t)
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+
+	// A legacy cache file is migrated, see TestMigrateCache, and written in an envelope of
+	// this version the next time the repos are fetched
+	assert.NoError(t, os.WriteFile(cacheFile, []byte(`[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`), 0644))
+	starred, err := GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`, starred.String())
+	assert.Equal(t, 0, api.fetched)
+	starred, err = RefetchStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	cache, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	fetchedAt, err := time.Parse(time.RFC3339, cache.Fetched_at)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), fetchedAt, time.Minute)
+	cache.Fetched_at = ""
+	assert.Equal(t, cacheEnvelope{Version: CACHE_VERSION, User: "Link-", Host: host, Source: "stars", Tool_version: VERSION, Star_dates: true, Count: 2, Checksum: reposChecksum(starred.Bytes()), Repos: starred.Bytes()}, cache)
+
+	// The repos of another user, or of another source, are fetched again
+	assert.NoError(t, os.Remove(validatorPath(cacheFile)))
+	_, err = GetStarredRepos("link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, api.fetched, "the logins are case insensitive")
+	_, err = GetStarredRepos("someone", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, api.fetched)
+	cache, err = readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "someone", cache.User)
+	defer func(previous string) { host = previous }(host)
+	host = "github.example.com"
+	_, err = GetStarredRepos("someone", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, api.fetched)
+	cache, err = readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "github.example.com", cache.Host)
+	source = "watching"
+	defer func() { source = "stars" }()
+	_, err = GetStarredRepos("someone", [32]byte{})
+	assert.NoError(t, err)
+	cache, err = readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "watching", cache.Source)
+	assert.False(t, cache.Star_dates)
+}
+
+func TestMigrateCache(t *testing.T) {
+	setup([]string{})
+	defer func(previous string) { host = previous }(host)
+	host = "github.com"
+	written := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	v1 := loadTestData(t, "testdata/cache_v1.json")
+	v2 := loadTestData(t, "testdata/cache_v2.json")
+
+	tests := []struct {
+		name          string
+		data          []byte
+		wantFetchedAt string
+		wantErr       error
+	}{
+		{name: "Version1", data: v1.Bytes(), wantFetchedAt: "2024-03-01T10:00:00Z"},
+		{name: "Version2", data: v2.Bytes(), wantFetchedAt: "2024-02-01T10:00:00Z"},
+		{name: "Version3", data: []byte(`{"version":3,"user":"Link-","host":"github.com","source":"stars","fetched_at":"2024-02-01T10:00:00Z","star_dates":true,"count":2,"repos":[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}, {"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]}`), wantFetchedAt: "2024-02-01T10:00:00Z"},
+		{name: "Version1WithoutStarDates", data: []byte(`[{"name":"cobra"},{"name":"clap"}]`), wantErr: errCacheOutdated},
+		{name: "UnknownVersion", data: []byte(`{"repos":[{"name":"cobra"}]}`), wantErr: errCacheOutdated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := decodeCache(tt.data)
+			assert.NoError(t, err)
+			migrated, err := migrateCache(cache, "Link-", written)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, CACHE_VERSION, migrated.Version)
+			assert.Equal(t, "Link-", migrated.User)
+			assert.Equal(t, "github.com", migrated.Host)
+			assert.Equal(t, "stars", migrated.Source)
+			assert.Equal(t, VERSION, migrated.Tool_version)
+			assert.Equal(t, tt.wantFetchedAt, migrated.Fetched_at)
+			assert.True(t, migrated.Star_dates)
+			assert.Equal(t, 2, migrated.Count)
+			assert.Equal(t, string(cache.Repos), string(migrated.Repos))
+			assert.NoError(t, migrated.verifyChecksum())
+		})
+	}
+
+	// The repos of the other sources never had star dates
+	source = "watching"
+	defer func() { source = "stars" }()
+	cache, err := decodeCache([]byte(`[{"name":"cobra"}]`))
+	assert.NoError(t, err)
+	migrated, err := migrateCache(cache, "Link-", written)
+	assert.NoError(t, err)
+	assert.False(t, migrated.Star_dates)
+	assert.Equal(t, 1, migrated.Count)
+}
+
+func TestGetStarredReposMigratesCache(t *testing.T) {
+	setup([]string{})
+	defer func(previous string) { host = previous }(host)
+	host = "github.com"
+	api := useStarsAPI(t)
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	// The repos of the fixtures never expire, see TestCacheTTL
+	cacheTTL = 0
+	defer func() { cacheFile, cacheTTL = "", DEFAULT_CACHE_TTL }()
+	written := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFixture := func(data []byte) {
+		assert.NoError(t, os.WriteFile(cacheFile, data, 0644))
+		assert.NoError(t, os.Chtimes(cacheFile, written, written))
+	}
+
+	// The cache files of version 1 and 2 are migrated and rewritten, without fetching the repos
+	for _, fixture := range []string{"testdata/cache_v1.json", "testdata/cache_v2.json"} {
+		data := loadTestData(t, fixture)
+		writeFixture(data.Bytes())
+		old, err := decodeCache(data.Bytes())
+		assert.NoError(t, err)
+		starred, err := GetStarredRepos("Link-", [32]byte{})
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(old.Repos), starred.String())
+		assert.Equal(t, 0, api.fetched)
+		cache, err := readCache(cacheFile)
+		assert.NoError(t, err)
+		assert.Equal(t, CACHE_VERSION, cache.Version, fixture)
+		assert.Equal(t, 2, cache.Count)
+	}
+	cache, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-02-01T10:00:00Z", cache.Fetched_at, "the repos were fetched when version 2 tells")
+	v1 := loadTestData(t, "testdata/cache_v1.json")
+	writeFixture(v1.Bytes())
+	_, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	cache, err = readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, written.UTC().Format(time.RFC3339), cache.Fetched_at, "the repos of version 1 were fetched when the cache file was written")
+
+	// The starred repos of version 1 without their star date are fetched again
+	outdated := `[{"name":"cobra"},{"name":"clap"}]`
+	writeFixture([]byte(outdated))
+	starred, err := GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Contains(t, starred.String(), "starred_at")
+	assert.Equal(t, 1, api.fetched)
+	cache, err = readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, CACHE_VERSION, cache.Version)
+
+	// They are still read when the repos can't be fetched
+	writeFixture([]byte(outdated))
+	api.status = http.StatusBadGateway
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, outdated, starred.String())
+	data, err := os.ReadFile(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, outdated, string(data))
+}
+
+func TestEvictStaleCaches(t *testing.T) {
+	setup([]string{})
+	dir := t.TempDir()
+	t.Setenv(CACHE_DIR_ENV, dir)
+	now := time.Now()
+	writeOld := func(name string, data string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, writeCacheFile(path, []byte(data)))
+		written := now.Add(-age)
+		assert.NoError(t, os.Chtimes(path, written, written))
+	}
+	fillCacheDir := func() {
+		stale := 40 * 24 * time.Hour
+		writeOld("stars_aaaaaaaaaaaa.json.gz", `{"version":2,"user":"link-","source":"stars","repos":[]}`, stale)
+		writeOld("stars_aaaaaaaaaaaa.readme.json", "{}", 0)
+		writeOld("stars_aaaaaaaaaaaa.etag.json", "{}", 0)
+		writeOld("stars_aaaaaaaaaaaa.json.gz.lock", "", 0)
+		writeOld("stars_aaaaaaaaaaaa.json.gz.bak", "", stale)
+		writeOld("stars_bbbbbbbbbbbb.json.gz", `{"version":2,"user":"Link-","source":"stars","repos":[]}`, 24*time.Hour)
+		writeOld("stars_cccccccccccc.json.gz", `{"version":2,"user":"someone","source":"stars","repos":[]}`, stale)
+		writeOld("stars_dddddddddddd.json.gz", `[]`, stale)
+		writeOld("watching_eeeeeeeeeeee.json.gz", `{"version":2,"user":"Link-","source":"watching","repos":[]}`, stale)
+	}
+	kept := []string{"stars_aaaaaaaaaaaa.json.gz.bak", "stars_bbbbbbbbbbbb.json.gz", "stars_cccccccccccc.json.gz", "stars_dddddddddddd.json.gz", "watching_eeeeeeeeeeee.json.gz", "stars_ffffffffffff.json.gz"}
+	path := filepath.Join(dir, "stars_ffffffffffff.json.gz")
+
+	// The stale cache files of the user and the source are deleted along with the caches next
+	// to them, once the cache file is written
+	fillCacheDir()
+	assert.NoError(t, writeCache(path, "Link-", *bytes.NewBufferString("[]"), cacheValidator{}))
+	assert.ElementsMatch(t, kept, cacheFileNames(t, dir))
+
+	// Never with --cache-max-age 0
+	fillCacheDir()
+	cacheMaxAge = 0
+	defer func() { cacheMaxAge = DEFAULT_CACHE_MAX_AGE }()
+	assert.NoError(t, evictStaleCaches(path, "Link-", now))
+	assert.Len(t, cacheFileNames(t, dir), 10)
+
+	// Nor with --cache-file
+	cacheMaxAge = DEFAULT_CACHE_MAX_AGE
+	cacheFile = path
+	assert.NoError(t, evictStaleCaches(path, "Link-", now))
+	assert.Len(t, cacheFileNames(t, dir), 10)
+	cacheFile = ""
+
+	// A cache file younger than --cache-max-age is kept
+	cacheMaxAge = 60 * 24 * time.Hour
+	assert.NoError(t, evictStaleCaches(path, "Link-", now))
+	assert.Len(t, cacheFileNames(t, dir), 10)
+	assert.NoError(t, evictStaleCaches(path, "Link-", now.Add(30*24*time.Hour)))
+	assert.ElementsMatch(t, kept, cacheFileNames(t, dir))
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantSize int64
+		wantErr  bool
+	}{
+		{name: "Bytes", value: "4096", wantSize: 4096},
+		{name: "Zero", value: "0", wantSize: 0},
+		{name: "Kilobytes", value: "512KB", wantSize: 512 << 10},
+		{name: "Megabytes", value: "200MB", wantSize: 200 << 20},
+		{name: "ShortUnitIgnoreCase", value: "2g", wantSize: 2 << 30},
+		{name: "Decimal", value: "1.5 GB", wantSize: 3 << 29},
+		{name: "UnknownUnit", value: "10TB", wantErr: true},
+		{name: "NoNumber", value: "MB", wantErr: true},
+		{name: "Negative", value: "-1MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := parseSize(tt.value)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "is not a size")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSize, size)
+		})
+	}
+}
+
+func TestCacheSizeBudget(t *testing.T) {
+	defer func() { cacheMaxSize = sizeFlag{} }()
+
+	budget, err := cacheSizeBudget()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(DEFAULT_CACHE_MAX_SIZE), budget)
+
+	t.Setenv(CACHE_MAX_SIZE_ENV, "50MB")
+	budget, err = cacheSizeBudget()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50<<20), budget)
+
+	// The flag takes precedence over the environment variable
+	assert.NoError(t, cacheMaxSize.Set("1KB"))
+	budget, err = cacheSizeBudget()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), budget)
+
+	cacheMaxSize = sizeFlag{}
+	t.Setenv(CACHE_MAX_SIZE_ENV, "lots")
+	_, err = cacheSizeBudget()
+	assert.ErrorContains(t, err, CACHE_MAX_SIZE_ENV+`: "lots" is not a size`)
+}
+
+func TestTrimCacheDir(t *testing.T) {
+	setup([]string{})
+	dir := t.TempDir()
+	t.Setenv(CACHE_DIR_ENV, dir)
+	defer func() { cacheMaxSize = sizeFlag{} }()
+	now := time.Now()
+	writeOld := func(name string, size int, age time.Duration) {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0644))
+		written := now.Add(-age)
+		assert.NoError(t, os.Chtimes(path, written, written))
+	}
+	fillCacheDir := func() {
+		writeOld("stars_link-_aaaaaaaaaaaa.json.gz", 400, 72*time.Hour)
+		writeOld("stars_link-_aaaaaaaaaaaa.readme.json", 200, time.Hour)
+		writeOld("stars_link-_aaaaaaaaaaaa.json.gz.lock", 0, 96*time.Hour)
+		writeOld("stars_someone_bbbbbbbbbbbb.json.gz", 300, 48*time.Hour)
+		writeOld("watching_link-_cccccccccccc.json.gz", 300, 24*time.Hour)
+		writeOld("gists_dddddddddddd.json", 100, 96*time.Hour)
+		writeOld("notes.txt", 5000, 96*time.Hour)
+		writeOld("stars_link-_ffffffffffff.json.gz", 100, 120*time.Hour)
+	}
+	path := filepath.Join(dir, "stars_link-_ffffffffffff.json.gz")
+
+	// Below the budget, nothing is deleted
+	fillCacheDir()
+	assert.NoError(t, cacheMaxSize.Set("2KB"))
+	assert.NoError(t, trimCacheDir(path))
+	assert.Len(t, cacheFileNames(t, dir), 8)
+
+	// Above it, the least recently used cache files are deleted along with the caches next
+	// to them, but the cache file just written, the locks and the files of other tools. The
+	// readme was used after the cache file next to it.
+	assert.NoError(t, cacheMaxSize.Set("1000"))
+	assert.NoError(t, trimCacheDir(path))
+	assert.ElementsMatch(t, []string{"stars_link-_aaaaaaaaaaaa.json.gz", "stars_link-_aaaaaaaaaaaa.readme.json", "stars_link-_aaaaaaaaaaaa.json.gz.lock", "watching_link-_cccccccccccc.json.gz", "notes.txt", "stars_link-_ffffffffffff.json.gz"}, cacheFileNames(t, dir))
+
+	assert.NoError(t, cacheMaxSize.Set("100B"))
+	assert.NoError(t, trimCacheDir(path))
+	assert.ElementsMatch(t, []string{"stars_link-_aaaaaaaaaaaa.json.gz.lock", "notes.txt", "stars_link-_ffffffffffff.json.gz"}, cacheFileNames(t, dir))
+
+	// Never with --cache-max-size 0
+	fillCacheDir()
+	assert.NoError(t, cacheMaxSize.Set("0"))
+	assert.NoError(t, trimCacheDir(path))
+	assert.Len(t, cacheFileNames(t, dir), 8)
+
+	// Nor with --cache-file
+	assert.NoError(t, cacheMaxSize.Set("100B"))
+	cacheFile = path
+	assert.NoError(t, trimCacheDir(path))
+	assert.Len(t, cacheFileNames(t, dir), 8)
+	cacheFile = ""
+
+	// The cache directory is trimmed once the cache file is written
+	assert.NoError(t, writeCache(path, "Link-", *bytes.NewBufferString("[]"), cacheValidator{}))
+	assert.ElementsMatch(t, []string{"stars_link-_aaaaaaaaaaaa.json.gz.lock", "notes.txt", "stars_link-_ffffffffffff.json.gz"}, cacheFileNames(t, dir))
+}
+
+func TestGetStarredReposCompressed(t *testing.T) {
+	setup([]string{})
+	t.Setenv(CACHE_DIR_ENV, t.TempDir())
+	cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+	api := useStarsAPI(t)
+	path, err := GetCachePath(cacheKey)
+	assert.NoError(t, err)
+	want := `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`
+
+	// Compressed to nothing, the cache file is empty
+	assert.NoError(t, writeCacheFile(path, nil))
+	got, err := GetStarredRepos("Link-", cacheKey)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got.String())
+	assert.Equal(t, 1, api.fetched)
+
+	// Read from the cache
+	got, err = GetStarredRepos("Link-", cacheKey)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got.String())
+	assert.Equal(t, 1, api.fetched)
+
+	// A corrupted cache file is fetched again
+	assert.NoError(t, os.WriteFile(path, []byte{0x1f, 0x8b, 0x08}, 0644))
+	got, err = GetStarredRepos("Link-", cacheKey)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got.String())
+	assert.Equal(t, 2, api.fetched)
+	cached, err := readCache(path)
+	assert.NoError(t, err)
+	assert.Equal(t, want, string(cached.Repos))
+}
+
+func TestFetchFailureLeavesNoCacheFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		cacheFile string
+	}{
+		{name: "Generated"},
+		{name: "CacheFileProvided", cacheFile: "stars.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup([]string{})
+			dir := t.TempDir()
+			t.Setenv(CACHE_DIR_ENV, dir)
+			if tt.cacheFile != "" {
+				cacheFile = filepath.Join(dir, tt.cacheFile)
+				defer func() { cacheFile = "" }()
+			}
+			api := useStarsAPI(t)
+			cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+			path, err := CachePath(cacheKey)
+			assert.NoError(t, err)
+
+			api.status = http.StatusBadGateway
+			_, err = GetStarredRepos("Link-", cacheKey)
+			assert.Error(t, err)
+			_, err = RefetchStarredRepos("Link-", cacheKey)
+			assert.Error(t, err)
+			assert.NoFileExists(t, path)
+			assert.NoFileExists(t, validatorPath(path))
+			tmp, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+			assert.NoError(t, err)
+			assert.Empty(t, tmp)
+
+			// The cache file is written once the repos are fetched
+			api.status = 0
+			starred, err := GetStarredRepos("Link-", cacheKey)
+			assert.NoError(t, err)
+			cache, err := readCache(path)
+			assert.NoError(t, err)
+			assert.Equal(t, starred.String(), string(cache.Repos))
+		})
+	}
+}
+
+func TestCorruptedCache(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Garbage", data: []byte("garbage")},
+		{name: "TruncatedLegacy", data: []byte(`[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"cl`)},
+		{name: "TruncatedEnvelope", data: []byte(`{"version":2,"user":"Link-","repos":[{"name":"cobra"`)},
+		{name: "TruncatedGzip", data: []byte{0x1f, 0x8b, 0x08}},
+		{name: "Zeros", data: make([]byte, 64)},
+		{name: "ChecksumMismatch", data: []byte(`{"version":4,"user":"Link-","source":"stars","star_dates":true,"count":1,"checksum":"` + reposChecksum([]byte(`[{"name":"cobra"}]`)) + `","repos":[{"name":"c0bra"}]}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup([]string{})
+			dir := t.TempDir()
+			t.Setenv(CACHE_DIR_ENV, dir)
+			api := useStarsAPI(t)
+			cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+			path, err := GetCachePath(cacheKey)
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(path, tt.data, 0644))
+			assert.NoError(t, writeCacheValidator(path, cacheValidator{Etag: `"stale"`}))
+
+			// The repos are fetched again and searched
+			starred, err := GetStarredRepos("Link-", cacheKey)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, api.fetched)
+			found, err := Search(starred, "cobra")
+			assert.NoError(t, err)
+			if assert.Equal(t, 1, found.Len()) {
+				assert.Equal(t, "cobra", found[0].Value.(Result).Name)
+			}
+
+			// The corrupted cache file is moved aside, the repos written instead
+			sidelined, err := os.ReadFile(path + ".corrupted")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.data, sidelined)
+			cache, err := readCache(path)
+			assert.NoError(t, err)
+			assert.Equal(t, starred.String(), string(cache.Repos))
+			assert.ElementsMatch(t, []string{"stars_2d06a89b2687.json.gz", "stars_2d06a89b2687.json.gz.corrupted", "stars_2d06a89b2687.json.gz.lock", "stars_2d06a89b2687.etag.json"}, cacheFileNames(t, dir))
+		})
+	}
+}
+
+func TestSidelineCache(t *testing.T) {
+	setup([]string{})
+	path := filepath.Join(t.TempDir(), "stars.json.gz")
+
+	// A cache file another process wrote the repos to meanwhile is kept
+	assert.NoError(t, writeCacheFile(path, []byte(`[{"name":"cobra"}]`)))
+	assert.NoError(t, sidelineCache(path))
+	assert.FileExists(t, path)
+	assert.NoFileExists(t, path+".corrupted")
+
+	// A corrupted one replaces the one moved aside before
+	assert.NoError(t, os.WriteFile(path+".corrupted", []byte("older"), 0644))
+	assert.NoError(t, os.WriteFile(path, []byte("garbage"), 0644))
+	assert.NoError(t, writeCacheValidator(path, cacheValidator{Etag: `"stale"`}))
+	assert.NoError(t, sidelineCache(path))
+	assert.NoFileExists(t, path)
+	assert.NoFileExists(t, validatorPath(path))
+	data, err := os.ReadFile(path + ".corrupted")
+	assert.NoError(t, err)
+	assert.Equal(t, "garbage", string(data))
+}
+
+func TestCacheReadOnly(t *testing.T) {
+	setup([]string{})
+	dir := filepath.Join(t.TempDir(), "cache")
+	t.Setenv(CACHE_DIR_ENV, dir)
+	api := useStarsAPI(t)
+	cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+	defer func() { cacheReadOnly = false }()
+	want := `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`
+
+	// Without a cache file, the repos are fetched without creating anything
+	cacheReadOnly = true
+	starred, err := GetStarredRepos("Link-", cacheKey)
+	assert.NoError(t, err)
+	assert.Equal(t, want, starred.String())
+	assert.Equal(t, 1, api.fetched)
+	assert.NoDirExists(t, dir)
+
+	// The files of the cache are read, never written
+	cacheReadOnly = false
+	path, err := GetCachePath(cacheKey)
+	assert.NoError(t, err)
+	snapshot := func() map[string]string {
+		files := map[string]string{}
+		for _, name := range cacheFileNames(t, dir) {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			assert.NoError(t, err)
+			stat, err := os.Stat(filepath.Join(dir, name))
+			assert.NoError(t, err)
+			files[name] = stat.ModTime().String() + " " + string(data)
+		}
+		return files
+	}
+	for _, tt := range []struct {
+		name string
+		data string
+	}{
+		{name: "Expired", data: `{"version":3,"user":"Link-","source":"stars","fetched_at":"2020-01-01T00:00:00Z","star_dates":true,"count":1,"repos":[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]}`},
+		{name: "OlderVersion", data: `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`},
+		{name: "Corrupted", data: `garbage`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheReadOnly = false
+			assert.NoError(t, writeCacheFile(path, []byte(tt.data)))
+			assert.NoError(t, writeCacheValidator(path, cacheValidator{Etag: `"stale"`}))
+			before := snapshot()
+
+			cacheReadOnly = true
+			starred, err := GetStarredRepos("Link-", cacheKey)
+			assert.NoError(t, err)
+			assert.Contains(t, starred.String(), "cobra")
+			assert.Equal(t, before, snapshot())
+		})
+	}
+
+	// Nor are the caches next to the cache file
+	cacheReadOnly = true
+	before := snapshot()
+	readmePath, err := GetReadmePath(cacheKey)
+	assert.NoError(t, err)
+	assert.NoError(t, saveCache(readmePath, []byte("{}")))
+	indexPath, err := GetIndexPath(cacheKey)
+	assert.NoError(t, err)
+	_, err = LoadIndex(indexPath, []byte(want), loadRepos(t, "testdata/starred_repos.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, before, snapshot())
+}
+
+func TestLockCacheFile(t *testing.T) {
+	setup([]string{})
+	t.Setenv(CACHE_DIR_ENV, t.TempDir())
+	// Slowly enough for every search to find the cache empty at once
+	api := useStarsAPI(t)
+	api.delay = 50 * time.Millisecond
+	cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+	want := `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`
+
+	// Only the first search fetches the repos, the others wait and read the cache
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	errs := make([]error, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got, err := GetStarredRepos("Link-", cacheKey)
+			results[i], errs[i] = got.String(), err
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, want, results[i])
+	}
+	assert.Equal(t, 1, api.fetched)
+	path, err := CachePath(cacheKey)
+	assert.NoError(t, err)
+	cached, err := readCache(path)
+	assert.NoError(t, err)
+	assert.Equal(t, want, string(cached.Repos))
+
+	// The lock is released
+	unlock, err := lockCacheFile(path)
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestUserCachePrefixes(t *testing.T) {
+	setup([]string{})
+	var urls []string
+	client = NewTestClient(func(req *http.Request) *http.Response {
+		urls = append(urls, req.URL.String())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(`[]`)), Header: make(http.Header)}
+	})
+
+	// The key of a user without any repo, for every source
+	prefixes, err := userCachePrefixes("Link-")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owned_link-_", "owned_01ba4719c80b.", "stars_link-_", "stars_01ba4719c80b.", "watching_link-_", "watching_01ba4719c80b."}, prefixes)
+	assert.Len(t, urls, 3)
+	assert.Equal(t, "stars", source)
+
+	client = NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString(`Not Found`)), Header: make(http.Header)}
+	})
+	_, err = userCachePrefixes("Link-")
+	assert.ErrorContains(t, err, "user not found")
+}
+
+func TestReadUsers(t *testing.T) {
+	users, err := readUsers(strings.NewReader("link-\n\n  knbr13  \n# retired\nsomeone\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"link-", "knbr13", "someone"}, users)
+}
+
+func TestRefreshCaches(t *testing.T) {
+	setup([]string{})
+	dir := t.TempDir()
+	t.Setenv(CACHE_DIR_ENV, dir)
+	api := useStarsAPI(t)
+	client = NewTestClient(func(req *http.Request) *http.Response {
+		if strings.Contains(req.URL.Path, "/users/ghost/") {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{"message":"Not Found"}`)), Header: make(http.Header)}
+		}
+		return api.RoundTrip(req)
+	})
+
+	// Every user is refreshed, even after one failed
+	var out bytes.Buffer
+	err := refreshCaches(&out, []string{"link-", "ghost", "knbr13"})
+	assert.EqualError(t, err, "not able to refresh the cache of 1 of the 3 users: ghost")
+	assert.Regexp(t, `^\[1/3\] Refreshing the cache of the stars of link-
+\[1/3\] Cached the 2 repos of link- in .+
+\[2/3\] Refreshing the cache of the stars of ghost
+\[2/3\] Not able to refresh the cache of ghost after .+: .*user not found.*
+\[3/3\] Refreshing the cache of the stars of knbr13
+\[3/3\] Cached the 2 repos of knbr13 in .+
+$`, out.String())
+	assert.Equal(t, 2, api.fetched)
+	assert.Equal(t, "", user)
+
+	// The cache files are named after the users, and only written
+	names := cacheFileNames(t, dir)
+	assert.Len(t, names, 6)
+	for _, prefix := range []string{"stars_link-_", "stars_knbr13_"} {
+		cached := 0
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".json.gz") {
+				cached++
+			}
+		}
+		assert.Equal(t, 1, cached, prefix)
+	}
+
+	// The caches that are up to date are only revalidated
+	out.Reset()
+	assert.NoError(t, refreshCaches(&out, []string{"link-"}))
+	assert.Equal(t, 2, api.fetched)
+	assert.Equal(t, 1, api.revalidated)
+}
+
+func TestPrintCacheKey(t *testing.T) {
+	setup([]string{})
+	dir := filepath.Join(t.TempDir(), "gh-stars")
+	t.Setenv(CACHE_DIR_ENV, dir)
+	api := useStarsAPI(t)
+	user = "Link-"
+	defer func() { user = "" }()
+	cacheKey, err := GenerateCacheKey("Link-")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, fmt.Sprintf("stars_link-_%x.json.gz", cacheKey[:6]))
+
+	// Only the newest repo is fetched, the number of repos is read from the Link header
+	var out bytes.Buffer
+	assert.NoError(t, printCacheKey(&out, "Link-"))
+	assert.Equal(t, fmt.Sprintf("Cache key:  %x\nCache file: %s\nRepos:      2\n", cacheKey, path), out.String())
+	assert.Equal(t, 0, api.fetched)
+	assert.NoDirExists(t, dir)
+
+	jsonOutput = true
+	defer func() { jsonOutput = false }()
+	out.Reset()
+	assert.NoError(t, printCacheKey(&out, "Link-"))
+	assert.JSONEq(t, fmt.Sprintf(`{"key": "%x", "path": %q, "count": 2}`, cacheKey, path), out.String())
+
+	// A single page lists every repo
+	api.stars = api.stars[:1]
+	out.Reset()
+	assert.NoError(t, printCacheKey(&out, "Link-"))
+	assert.Contains(t, out.String(), `"count": 1`)
+}
+
+func TestPrintCacheInfo(t *testing.T) {
+	setup([]string{})
+	t.Setenv(CACHE_DIR_ENV, t.TempDir())
+	setTempDir(t, t.TempDir())
+	cacheKey := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+	path := filepath.Join(os.Getenv(CACHE_DIR_ENV), "stars_2d06a89b2687.json.gz")
+	header := "User:       Link-\nSource:     stars\nCache key:  2d06a89b26870000000000000000000000000000000000000000000000000000\nCache file: " + path + "\n"
+
+	t.Run("Missing", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Equal(t, header+"Exists:     no, the repos are fetched on the next search\n", out.String())
+		assert.False(t, fileExists(path))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Equal(t, header+"Exists:     yes\nSize:       0 B\nRepos:      none, the cache file is empty and the repos are fetched on the next search\n", out.String())
+	})
+
+	t.Run("Repos", func(t *testing.T) {
+		data, err := os.ReadFile("testdata/5_repos.json")
+		assert.NoError(t, err)
+		assert.NoError(t, writeCacheFile(path, data))
+		stat, err := os.Stat(path)
+		assert.NoError(t, err)
+		fetched := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+		assert.NoError(t, os.Chtimes(path, fetched, fetched))
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, fetched.Add(3*time.Hour)))
+		assert.Equal(t, header+"Exists:     yes\nSize:       "+formatSize(stat.Size())+", 32.4 KB uncompressed\nFormat:     bare list of repos of older versions, migrated the next time the repos are searched\nRepos:      5\nFetched:    "+fetched.Local().Format(time.RFC3339)+", 3h0m0s ago\n", out.String())
+	})
+
+	t.Run("Envelope", func(t *testing.T) {
+		// The repos were fetched when the envelope tells, whatever the age of the cache file
+		assert.NoError(t, writeCache(path, "Link-", *bytes.NewBufferString(`[{"name":"cobra"}]`), cacheValidator{}))
+		written := time.Now().Add(-48 * time.Hour)
+		assert.NoError(t, os.Chtimes(path, written, written))
+		cache, err := readCache(path)
+		assert.NoError(t, err)
+		fetched, err := time.Parse(time.RFC3339, cache.Fetched_at)
+		assert.NoError(t, err)
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, fetched.Add(time.Minute)))
+		assert.Contains(t, out.String(), "\nFormat:     version 4, written by gh stars v"+VERSION+"\nRepos:      1\nFetched:    "+fetched.Local().Format(time.RFC3339)+", 1m0s ago\n")
+
+		// The cache file of another user is fetched again
+		out.Reset()
+		assert.NoError(t, printCacheInfo(&out, "someone", cacheKey, fetched))
+		assert.Contains(t, out.String(), "\nOwner:      the stars of Link- on "+host+", the repos are fetched on the next search\n")
+	})
+
+	t.Run("Undecodable", func(t *testing.T) {
+		assert.NoError(t, writeCacheFile(path, []byte("[1]")))
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Contains(t, out.String(), "Repos:      none, the cache file is corrupted: json: cannot unmarshal number")
+
+		assert.NoError(t, writeCacheFile(path, []byte("{")))
+		out.Reset()
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Contains(t, out.String(), "Repos:      none, the cache file is corrupted: ")
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(path, []byte{0x1f, 0x8b, 0x08}, 0644))
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Contains(t, out.String(), "Size:       3 B\nRepos:      none, the cache file is corrupted: ")
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		assert.NoError(t, os.Remove(path))
+		legacyPath := filepath.Join(os.TempDir(), "stars_2d06a89b2687.json")
+		assert.NoError(t, os.WriteFile(legacyPath, []byte("[]"), 0644))
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Contains(t, out.String(), header+"Legacy:     "+legacyPath+", moved to the cache file on the next search\nExists:     yes\nSize:       2 B\nFormat:     bare list of repos of older versions, migrated the next time the repos are searched\nRepos:      0\n")
+	})
+
+	t.Run("WithoutTheUser", func(t *testing.T) {
+		assert.NoError(t, writeCache(path, "Link-", *bytes.NewBufferString(`[{"name":"cobra"}]`), cacheValidator{}))
+		user = "Link-"
+		defer func() { user = "" }()
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Contains(t, out.String(), "Cache file: "+filepath.Join(filepath.Dir(path), "stars_link-_2d06a89b2687.json.gz")+"\nLegacy:     "+path+", moved to the cache file on the next search\nExists:     yes\n")
+		assert.Contains(t, out.String(), "\nRepos:      1\n")
+	})
+
+	t.Run("CacheFileProvided", func(t *testing.T) {
+		cacheFile = filepath.Join(t.TempDir(), "stars.json")
+		defer func() { cacheFile = "" }()
+		var out bytes.Buffer
+		assert.NoError(t, printCacheInfo(&out, "Link-", cacheKey, time.Now()))
+		assert.Contains(t, out.String(), "Cache file: "+cacheFile+"\nExists:     no")
+	})
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{size: 0, want: "0 B"},
+		{size: 1023, want: "1023 B"},
+		{size: 1536, want: "1.5 KB"},
+		{size: 8 * 1024 * 1024, want: "8.0 MB"},
+		{size: 3 << 40, want: "3.0 TB"},
+		{size: 5 << 50, want: "5120.0 TB"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatSize(tt.size))
+	}
+}
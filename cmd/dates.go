@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Units of the relative dates, see parseDate
+var dateUnits = map[string]func(t time.Time, n int) time.Time{
+	"d":  func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -n) },
+	"w":  func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -7*n) },
+	"mo": func(t time.Time, n int) time.Time { return t.AddDate(0, -n, 0) },
+	"y":  func(t time.Time, n int) time.Time { return t.AddDate(-n, 0, 0) },
+}
+
+// parseDate parses a date, either absolute like 2023-01-01 or 2023-01-01T12:00:00Z, or
+// relative to now like 30d, 2w, 6mo or 1y for 30 days, 2 weeks, 6 months or 1 year ago
+func parseDate(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+
+	digits := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+	if digits > 0 {
+		count, err := strconv.Atoi(value[:digits])
+		if before, ok := dateUnits[strings.ToLower(value[digits:])]; ok && err == nil {
+			return before(now, count), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, use a day like 2023-01-01 or a duration like 30d, 2w, 6mo or 1y", value)
+}
+
+// A dateFlag is the value of a flag taking a date, see parseDate. Relative dates are
+// relative to the time the flags are parsed, invalid dates are rejected then.
+type dateFlag struct {
+	text string
+	time time.Time
+}
+
+func (d *dateFlag) String() string {
+	return d.text
+}
+
+func (d *dateFlag) Set(value string) error {
+	date, err := parseDate(value, time.Now())
+	if err != nil {
+		return err
+	}
+	d.text, d.time = value, date
+	return nil
+}
+
+func (d *dateFlag) Type() string {
+	return "date"
+}
+
+// set reports whether the flag was provided
+func (d dateFlag) set() bool {
+	return !d.time.IsZero()
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2023-01-01", want: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{value: " 2023-01-01T08:30:00Z ", want: time.Date(2023, 1, 1, 8, 30, 0, 0, time.UTC)},
+		{value: "30d", want: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "2w", want: time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{value: "6MO", want: time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "1y", want: time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC)},
+		{value: "6months", wantErr: true},
+		{value: "2023-13-01", wantErr: true},
+		{value: "mo", wantErr: true},
+		{value: "-6mo", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDate(tt.value, now)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "is not a date")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
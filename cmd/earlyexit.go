@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/Link-/gh-stars/lib/pq"
+)
+
+// Number of results kept by exact and prefix searches, 0 keeps every result. Set in Run,
+// see earlyExitLimit.
+var searchLimit int
+
+// earlyExitLimit returns the number of results a search needs to keep for the output to
+// stay the same, or 0 when every result is needed. Only exact and prefix searches, see
+// indexable, stop early: --limit then bounds the results. The filters, --boost-recent,
+// --per-owner and --random work on every result, and so does grouping the forks under
+// their upstream when some of the repos are forks.
+func earlyExitLimit(repos []Repo) int {
+	if limit <= 0 || !indexable() || boostRecent || perOwner > 0 || randomPick || len(activeFilters()) > 0 {
+		return 0
+	}
+	for _, repo := range repos {
+		if repo.Fork {
+			return 0
+		}
+	}
+	return limit
+}
+
+// maxNeedleScore returns the highest score a needle can get in the fields, see scoreNeedle
+func maxNeedleScore(fields map[string]bool) int {
+	best := 0
+	for field := range fields {
+		score := fieldScores[field]
+		switch field {
+		case "description":
+			score = int(math.Round(float64(score) * PHRASE_RATIO))
+		case "topics":
+			if !topicsWeighted() {
+				score = EXACT_TOPIC_SCORE
+			}
+		}
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// A boundedResults keeps the best limit results pushed to it. They are held in a min-heap
+// whose root is the worst of them, a result that can't beat it is dropped right away.
+type boundedResults struct {
+	limit int
+	items minQueue
+}
+
+// A minQueue is a PriorityQueue popping the lowest priority first
+type minQueue struct{ pq.PriorityQueue }
+
+func (q minQueue) Less(i, j int) bool { return q.PriorityQueue.Less(j, i) }
+
+func newBoundedResults(limit int) *boundedResults {
+	return &boundedResults{limit: limit, items: minQueue{make(pq.PriorityQueue, 0, limit)}}
+}
+
+// worst returns the lowest priority a result needs to be kept, and false while fewer than
+// limit results are kept and every result is
+func (b *boundedResults) worst() (int, bool) {
+	if b.items.Len() < b.limit {
+		return 0, false
+	}
+	return b.items.PriorityQueue[0].Priority, true
+}
+
+// push keeps the result if it is among the best limit results pushed so far
+func (b *boundedResults) push(item *pq.Item) {
+	if b.items.Len() < b.limit {
+		heap.Push(&b.items, item)
+		return
+	}
+	// The result replaces the worst one when it comes before it, see PriorityQueue.Less
+	if !(pq.PriorityQueue{item, b.items.PriorityQueue[0]}).Less(0, 1) {
+		return
+	}
+	b.items.PriorityQueue[0] = item
+	heap.Fix(&b.items, 0)
+}
+
+// queue returns the results kept, in a priority queue popping the best first
+func (b *boundedResults) queue() pq.PriorityQueue {
+	found := b.items.PriorityQueue
+	heap.Init(&found)
+	return found
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedResults(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, limit := range []int{1, 3, 10, 50} {
+		t.Run(fmt.Sprint(limit), func(t *testing.T) {
+			var all = make(pq.PriorityQueue, 0)
+			bounded := newBoundedResults(limit)
+			for i := 0; i < 40; i++ {
+				// Few distinct priorities, so the ties are broken by the repos
+				item := pq.Item{
+					Value:    Result{Repo: Repo{Full_name: fmt.Sprintf("owner/repo%d", i), Stars: random.Intn(3)}},
+					Priority: random.Intn(5) * 100,
+				}
+				heap.Push(&all, &pq.Item{Value: item.Value, Priority: item.Priority})
+				bounded.push(&item)
+			}
+			want := rankedRepos(all)
+			if len(want) > limit {
+				want = want[:limit]
+			}
+			assert.Equal(t, want, rankedRepos(bounded.queue()))
+		})
+	}
+}
+
+func TestEarlyExitLimit(t *testing.T) {
+	setup([]string{})
+	repos := loadRepos(t, "testdata/5_repos.json")
+	defer func() {
+		limit = 0
+		prefixMatch = false
+		boostRecent = false
+		onlyOwners = nil
+	}()
+
+	limit = 10
+	assert.Zero(t, earlyExitLimit(repos), "fuzzy searches score every repo")
+	prefixMatch = true
+	assert.Equal(t, 10, earlyExitLimit(repos))
+	assert.Zero(t, earlyExitLimit(append(repos, Repo{Full_name: "someone/fork", Fork: true})), "forks are grouped with every result")
+	boostRecent = true
+	assert.Zero(t, earlyExitLimit(repos), "--boost-recent ranks every result again")
+	boostRecent = false
+	onlyOwners = []string{"karpathy"}
+	assert.Zero(t, earlyExitLimit(repos), "the filters drop some of the results")
+}
+
+// The early exit returns the first results of the full search, for random queries against
+// generated repos
+func TestEarlyExitSameResults(t *testing.T) {
+	setup([]string{})
+	repos := generateRepos(2000)
+	words := []string{"cli", "kubernetes", "operator", "static", "site", "generator", "http", "server", "shell", "game", "term", "net"}
+	fieldPrefixes := []string{"", "", "", "name:", "topic:", "description:", "-"}
+	defer func() {
+		searchLimit = 0
+		prefixMatch = false
+		absoluteDist = false
+		fuzzyDistance = DEFAULT_FUZZY_DISTANCE
+		matchAll = false
+	}()
+
+	random := rand.New(rand.NewSource(42))
+	for i := 0; i < 60; i++ {
+		terms := make([]string, 1+random.Intn(3))
+		for j := range terms {
+			terms[j] = fieldPrefixes[random.Intn(len(fieldPrefixes))] + words[random.Intn(len(words))]
+		}
+		text := strings.Join(terms, " ")
+		prefix := i%2 == 0
+		all := i%3 == 0
+		top := []int{1, 5, 10, 25}[random.Intn(4)]
+
+		t.Run(fmt.Sprintf("%s/prefix=%v/all=%v/limit=%d", text, prefix, all, top), func(t *testing.T) {
+			prefixMatch, absoluteDist, fuzzyDistance, matchAll = prefix, !prefix, 0, all
+			query, err := NewQuery(text)
+			assert.NoError(t, err)
+
+			searchLimit = 0
+			slow, err := SearchRepos(repos, query)
+			assert.NoError(t, err)
+			want := rankedRepos(slow)
+			if len(want) > top {
+				want = want[:top]
+			}
+
+			searchLimit = top
+			fast, err := SearchRepos(repos, query)
+			assert.NoError(t, err)
+			assert.Equal(t, want, rankedRepos(fast))
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package cmd
+
+import "testing"
+
+// setTempDir makes dir the temporary directory of the test, see os.TempDir
+func setTempDir(t *testing.T, dir string) {
+	t.Setenv("TMPDIR", dir)
+}
+
+// setHomeDir makes dir the home directory of the test, see os.UserHomeDir, along with the
+// user cache directory under it, see os.UserCacheDir
+func setHomeDir(t *testing.T, dir string) {
+	t.Setenv("HOME", dir)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// setTempDir makes dir the temporary directory of the test, see os.TempDir
+func setTempDir(t *testing.T, dir string) {
+	t.Setenv("TMP", dir)
+	t.Setenv("TEMP", dir)
+}
+
+// setHomeDir makes dir the home directory of the test, see os.UserHomeDir, along with the
+// user cache directory under it, see os.UserCacheDir
+func setHomeDir(t *testing.T, dir string) {
+	t.Setenv("USERPROFILE", dir)
+	t.Setenv("LocalAppData", filepath.Join(dir, "AppData", "Local"))
+}
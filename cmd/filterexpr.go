@@ -0,0 +1,465 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The kinds of values of a filter expression, see ParseFilter
+type valueKind int
+
+const (
+	kindNumber valueKind = iota
+	kindString
+	kindBool
+	kindDate
+	kindList
+)
+
+func (k valueKind) String() string {
+	return [...]string{"number", "string", "boolean", "date", "list"}[k]
+}
+
+// A filterValue is the value of an operand of a filter expression, only the field of its
+// kind is set
+type filterValue struct {
+	number int
+	text   string
+	flag   bool
+	date   time.Time // Zero when the repo has no date
+	list   []string
+}
+
+// A filterField is a field of Repo that can be used in a filter expression
+type filterField struct {
+	kind  valueKind
+	value func(repo Repo) filterValue
+}
+
+// Identifiers of a filter expression and the field of the repo they stand for
+var filterFields = map[string]filterField{
+	"name":        {kindString, func(r Repo) filterValue { return filterValue{text: r.Name} }},
+	"full_name":   {kindString, func(r Repo) filterValue { return filterValue{text: r.Full_name} }},
+	"owner":       {kindString, func(r Repo) filterValue { return filterValue{text: r.Owner.Login} }},
+	"description": {kindString, func(r Repo) filterValue { return filterValue{text: r.Description} }},
+	"homepage":    {kindString, func(r Repo) filterValue { return filterValue{text: r.Homepage} }},
+	"language":    {kindString, func(r Repo) filterValue { return filterValue{text: r.Language} }},
+	"license":     {kindString, func(r Repo) filterValue { return filterValue{text: r.SpdxId()} }},
+	"topics":      {kindList, func(r Repo) filterValue { return filterValue{list: r.Topics} }},
+	"stars":       {kindNumber, func(r Repo) filterValue { return filterValue{number: r.Stars} }},
+	"open_issues": {kindNumber, func(r Repo) filterValue { return filterValue{number: r.Open_issues} }},
+	"fork":        {kindBool, func(r Repo) filterValue { return filterValue{flag: r.Fork} }},
+	"archived":    {kindBool, func(r Repo) filterValue { return filterValue{flag: r.Archived} }},
+	"private":     {kindBool, func(r Repo) filterValue { return filterValue{flag: r.Private} }},
+	"template":    {kindBool, func(r Repo) filterValue { return filterValue{flag: r.Is_template} }},
+	"mirror":      {kindBool, func(r Repo) filterValue { return filterValue{flag: r.Mirror_url != ""} }},
+	"pushed_at":   {kindDate, func(r Repo) filterValue { return filterValue{date: r.PushedAt()} }},
+	"starred_at":  {kindDate, func(r Repo) filterValue { return filterValue{date: r.StarredAt()} }},
+}
+
+// filterFieldNames returns the identifiers of a filter expression in alphabetical order
+func filterFieldNames() []string {
+	names := make([]string, 0, len(filterFields))
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A FilterError is returned when a filter expression can't be parsed. Like a QueryError,
+// it points at the position of the problem with a caret.
+type FilterError struct {
+	Filter   string
+	Position int // 1-based position of the problem, in characters
+	Message  string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%s at position %d in the filter\n\t%s\n\t%s^", e.Message, e.Position, e.Filter, strings.Repeat(" ", e.Position-1))
+}
+
+type filterTokenKind int
+
+const (
+	filterIdent filterTokenKind = iota
+	filterNumber
+	filterString
+	filterOperator
+	filterOpen
+	filterClose
+)
+
+type filterToken struct {
+	kind     filterTokenKind
+	text     string // The identifier, the operator, the digits or the unquoted string
+	position int    // 1-based position of the token in the filter
+}
+
+// Operators of a filter expression, the longest first so <= isn't read as <
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!"}
+
+// ParseFilter parses a filter expression like stars > 1000 && language == "Go" && !fork and
+// returns the Filter keeping the repos it is true for.
+//
+// The identifiers are the fields of the repos, see filterFields: numbers (stars,
+// open_issues), strings (name, full_name, owner, description, homepage, language, license),
+// booleans (fork, archived, private, template, mirror), dates (pushed_at, starred_at) and the
+// list of topics. The values are numbers, double quoted strings, true and false, and dates
+// written as strings in the format of --pushed-after, e.g. "2023-01-01" or "6mo".
+//
+// Numbers and dates are compared with == != < <= > >=, strings and booleans with == and !=.
+// Strings are compared ignoring the case, and contains finds a string in another one or a
+// topic in the topics. A boolean is a condition on its own, conditions are combined with
+// ! && || and parentheses, ! binding tighter than &&, which binds tighter than ||. A date
+// missing from a repo makes every comparison with it false.
+func ParseFilter(text string) (Filter, error) {
+	tokens, err := lexFilter(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{text: text, tokens: tokens, now: time.Now()}
+	if len(tokens) == 0 {
+		return nil, &FilterError{Filter: text, Position: 1, Message: "empty filter"}
+	}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %s", p.describe(p.tokens[p.pos]))
+	}
+	return filter, nil
+}
+
+func lexFilter(text string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(text)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: filterOpen, text: "(", position: i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: filterClose, text: ")", position: i + 1})
+			i++
+		case r == '"':
+			var value strings.Builder
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				value.WriteRune(runes[end])
+				end++
+			}
+			if end >= len(runes) {
+				return nil, &FilterError{Filter: text, Position: i + 1, Message: "unterminated string"}
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: value.String(), position: i + 1})
+			i = end + 1
+		case unicode.IsDigit(r):
+			end := i
+			for end < len(runes) && unicode.IsDigit(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, filterToken{kind: filterNumber, text: string(runes[i:end]), position: i + 1})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, filterToken{kind: filterIdent, text: string(runes[i:end]), position: i + 1})
+			i = end
+		default:
+			operator := ""
+			for _, candidate := range filterOperators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, &FilterError{Filter: text, Position: i + 1, Message: fmt.Sprintf("unexpected character %q", r)}
+			}
+			tokens = append(tokens, filterToken{kind: filterOperator, text: operator, position: i + 1})
+			i += len(operator)
+		}
+	}
+	return tokens, nil
+}
+
+// An operand of a comparison, either a field of the repo or a constant
+type filterOperand struct {
+	kind     valueKind
+	value    func(repo Repo) filterValue
+	constant *filterValue // Set for the values written in the filter
+	text     string       // The string as written, parsed as a date when compared to one
+	position int
+}
+
+type filterParser struct {
+	text   string
+	tokens []filterToken
+	pos    int
+	now    time.Time // Relative dates are relative to the time the filter is parsed
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// peekOperator reports whether the next token is the operator
+func (p *filterParser) peekOperator(operator string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == filterOperator && t.text == operator
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	position := len([]rune(p.text)) + 1
+	if p.pos < len(p.tokens) {
+		position = p.tokens[p.pos].position
+	}
+	return &FilterError{Filter: p.text, Position: position, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *filterParser) describe(t filterToken) string {
+	switch t.kind {
+	case filterString:
+		return fmt.Sprintf("string %q", t.text)
+	case filterNumber:
+		return fmt.Sprintf("number %s", t.text)
+	case filterIdent:
+		return fmt.Sprintf("identifier %s", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// parseOr: and (|| and)*
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		either := left
+		left = func(repo Repo) bool { return either(repo) || right(repo) }
+	}
+	return left, nil
+}
+
+// parseAnd: not (&& not)*
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("&&") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		both := left
+		left = func(repo Repo) bool { return both(repo) && right(repo) }
+	}
+	return left, nil
+}
+
+// parseNot: ! not | ( or ) | comparison
+func (p *filterParser) parseNot() (Filter, error) {
+	if p.peekOperator("!") {
+		p.pos++
+		filter, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(repo Repo) bool { return !filter(repo) }, nil
+	}
+	if t, ok := p.peek(); ok && t.kind == filterOpen {
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, ok := p.peek()
+		if !ok {
+			return nil, &FilterError{Filter: p.text, Position: t.position, Message: `unclosed "("`}
+		}
+		if closing.kind != filterClose {
+			return nil, p.errorf(`expected ")" but found %s`, p.describe(closing))
+		}
+		p.pos++
+		return filter, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison: operand (operator operand)?, a lone operand must be a boolean
+func (p *filterParser) parseComparison() (Filter, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := p.peek()
+	isComparison := ok && ((t.kind == filterOperator && strings.ContainsAny(t.text, "=<>")) || (t.kind == filterIdent && t.text == "contains"))
+	if !isComparison {
+		if left.kind != kindBool {
+			return nil, &FilterError{Filter: p.text, Position: left.position, Message: fmt.Sprintf("a %s is not a condition, compare it to a value", left.kind)}
+		}
+		return func(repo Repo) bool { return left.value(repo).flag }, nil
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return p.compare(t, left, right)
+}
+
+// parseOperand: identifier | number | string | true | false
+func (p *filterParser) parseOperand() (filterOperand, error) {
+	t, ok := p.peek()
+	if !ok {
+		return filterOperand{}, p.errorf("unexpected end of filter, expected a value")
+	}
+	operand := filterOperand{position: t.position}
+	switch t.kind {
+	case filterIdent:
+		switch t.text {
+		case "true", "false":
+			operand.kind, operand.constant = kindBool, &filterValue{flag: t.text == "true"}
+		default:
+			field, ok := filterFields[t.text]
+			if !ok {
+				return filterOperand{}, p.errorf("unknown identifier %q (valid identifiers are: %s)", t.text, strings.Join(filterFieldNames(), ", "))
+			}
+			operand.kind, operand.value = field.kind, field.value
+		}
+	case filterNumber:
+		number, err := strconv.Atoi(t.text)
+		if err != nil {
+			return filterOperand{}, p.errorf("number %s is too large", t.text)
+		}
+		operand.kind, operand.constant = kindNumber, &filterValue{number: number}
+	case filterString:
+		operand.kind, operand.constant, operand.text = kindString, &filterValue{text: t.text}, t.text
+	default:
+		return filterOperand{}, p.errorf("unexpected %s, expected a value", p.describe(t))
+	}
+	p.pos++
+	if operand.constant != nil {
+		constant := *operand.constant
+		operand.value = func(Repo) filterValue { return constant }
+	}
+	return operand, nil
+}
+
+// compare returns the filter comparing the operands, after checking their kinds work with
+// the operator. A string constant compared to a date is parsed as a date.
+func (p *filterParser) compare(operator filterToken, left filterOperand, right filterOperand) (Filter, error) {
+	for _, pair := range [][2]*filterOperand{{&left, &right}, {&right, &left}} {
+		date, text := pair[0], pair[1]
+		if date.kind == kindDate && text.kind == kindString && text.constant != nil {
+			parsed, err := parseDate(text.text, p.now)
+			if err != nil {
+				return nil, &FilterError{Filter: p.text, Position: text.position, Message: err.Error()}
+			}
+			text.kind = kindDate
+			text.value = func(Repo) filterValue { return filterValue{date: parsed} }
+		}
+	}
+
+	mismatch := &FilterError{Filter: p.text, Position: operator.position, Message: fmt.Sprintf("can't compare a %s with a %s using %s", left.kind, right.kind, operator.text)}
+	if operator.text == "contains" {
+		switch {
+		case left.kind == kindString && right.kind == kindString:
+			return func(repo Repo) bool {
+				return strings.Contains(strings.ToLower(left.value(repo).text), strings.ToLower(right.value(repo).text))
+			}, nil
+		case left.kind == kindList && right.kind == kindString:
+			return func(repo Repo) bool {
+				needle := right.value(repo).text
+				for _, item := range left.value(repo).list {
+					if strings.EqualFold(item, needle) {
+						return true
+					}
+				}
+				return false
+			}, nil
+		}
+		return nil, mismatch
+	}
+	if left.kind != right.kind {
+		return nil, mismatch
+	}
+
+	ordered := operator.text != "==" && operator.text != "!="
+	switch left.kind {
+	case kindNumber:
+		return func(repo Repo) bool {
+			l, r := left.value(repo).number, right.value(repo).number
+			return compareOrdered(operator.text, l < r, l > r)
+		}, nil
+	case kindDate:
+		return func(repo Repo) bool {
+			l, r := left.value(repo).date, right.value(repo).date
+			if l.IsZero() || r.IsZero() {
+				return false
+			}
+			return compareOrdered(operator.text, l.Before(r), l.After(r))
+		}, nil
+	case kindString:
+		if ordered {
+			return nil, mismatch
+		}
+		return func(repo Repo) bool {
+			return strings.EqualFold(left.value(repo).text, right.value(repo).text) == (operator.text == "==")
+		}, nil
+	case kindBool:
+		if ordered {
+			return nil, mismatch
+		}
+		return func(repo Repo) bool {
+			return (left.value(repo).flag == right.value(repo).flag) == (operator.text == "==")
+		}, nil
+	}
+	return nil, mismatch
+}
+
+// compareOrdered applies the operator to two values, given whether the first one is less
+// or greater than the second one
+func compareOrdered(operator string, less bool, greater bool) bool {
+	switch operator {
+	case "==":
+		return !less && !greater
+	case "!=":
+		return less || greater
+	case "<":
+		return less
+	case "<=":
+		return !greater
+	case ">":
+		return greater
+	default:
+		return !less
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Repos the filter expressions are evaluated against, see TestParseFilter
+func filterRepos() []Repo {
+	cobra := Repo{Name: "cobra", Full_name: "spf13/cobra", Description: "A Commander for modern Go CLI interactions", Stars: 35000, Open_issues: 280, Topics: []string{"cli", "go"}, Language: "Go", License: &License{Spdx_id: "Apache-2.0"}, Pushed_at: "2024-05-01T10:00:00Z", Starred_at: "2023-02-01T10:00:00Z"}
+	cobra.Owner.Login = "spf13"
+	fork := Repo{Name: "cobra", Full_name: "someone/cobra", Description: "A Commander for modern Go CLI interactions", Stars: 3, Fork: true, Language: "Go", Pushed_at: "2021-01-01T10:00:00Z"}
+	fork.Owner.Login = "someone"
+	clap := Repo{Name: "clap", Full_name: "clap-rs/clap", Description: "A full featured, fast Command Line Argument Parser for Rust", Stars: 12000, Open_issues: 0, Topics: []string{"cli", "rust"}, Language: "Rust", License: &License{Spdx_id: "MIT"}, Archived: true, Is_template: true, Starred_at: "2024-03-01T10:00:00Z"}
+	clap.Owner.Login = "clap-rs"
+	return []Repo{cobra, fork, clap}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    string
+		wantRepos []string
+	}{
+		{name: "Numbers", filter: "stars > 1000", wantRepos: []string{"spf13/cobra", "clap-rs/clap"}},
+		{name: "NumbersBothBounds", filter: "stars >= 12000 && 35000 > stars", wantRepos: []string{"clap-rs/clap"}},
+		{name: "NumberEquality", filter: "open_issues == 0 && stars != 3", wantRepos: []string{"clap-rs/clap"}},
+		{name: "StringsIgnoreCase", filter: `language == "go"`, wantRepos: []string{"spf13/cobra", "someone/cobra"}},
+		{name: "StringInequality", filter: `owner != "spf13" && name != "clap"`, wantRepos: []string{"someone/cobra"}},
+		{name: "EscapedQuote", filter: `description != "a \"quoted\" description"`, wantRepos: []string{"spf13/cobra", "someone/cobra", "clap-rs/clap"}},
+		{name: "StringContains", filter: `description contains "parser"`, wantRepos: []string{"clap-rs/clap"}},
+		{name: "TopicsContain", filter: `topics contains "CLI" && topics contains "go"`, wantRepos: []string{"spf13/cobra"}},
+		{name: "EmptyLicense", filter: `license == ""`, wantRepos: []string{"someone/cobra"}},
+		{name: "Booleans", filter: "!fork && !archived", wantRepos: []string{"spf13/cobra"}},
+		{name: "BooleanComparison", filter: "template == true || fork != false", wantRepos: []string{"someone/cobra", "clap-rs/clap"}},
+		{name: "Dates", filter: `pushed_at >= "2024-01-01"`, wantRepos: []string{"spf13/cobra"}},
+		{name: "MissingDate", filter: `starred_at < "2024-01-01" || starred_at >= "2024-01-01"`, wantRepos: []string{"spf13/cobra", "clap-rs/clap"}},
+		{name: "RelativeDate", filter: `pushed_at < "1y"`, wantRepos: []string{"spf13/cobra", "someone/cobra"}},
+		{name: "Precedence", filter: `fork || archived && stars > 20000`, wantRepos: []string{"someone/cobra"}},
+		{name: "Parentheses", filter: `(fork || archived) && stars > 5`, wantRepos: []string{"clap-rs/clap"}},
+		{name: "Example", filter: `stars > 1000 && language == "Go" && !fork`, wantRepos: []string{"spf13/cobra"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			assert.NoError(t, err)
+			got := []string{}
+			for _, repo := range filterRepos() {
+				if filter(repo) {
+					got = append(got, repo.Full_name)
+				}
+			}
+			assert.Equal(t, tt.wantRepos, got)
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       string
+		wantMessage  string
+		wantPosition int
+	}{
+		{name: "Empty", filter: "  ", wantMessage: "empty filter", wantPosition: 1},
+		{name: "UnknownIdentifier", filter: "stars > 10 && stargazers > 1", wantMessage: `unknown identifier "stargazers"`, wantPosition: 15},
+		{name: "UnterminatedString", filter: `language == "Go`, wantMessage: "unterminated string", wantPosition: 13},
+		{name: "UnexpectedCharacter", filter: "stars = 10", wantMessage: `unexpected character '='`, wantPosition: 7},
+		{name: "MissingValue", filter: "stars >", wantMessage: "unexpected end of filter, expected a value", wantPosition: 8},
+		{name: "KindMismatch", filter: `stars > "many"`, wantMessage: "can't compare a number with a string using >", wantPosition: 7},
+		{name: "OrderedStrings", filter: `name < "b"`, wantMessage: "can't compare a string with a string using <", wantPosition: 6},
+		{name: "NotACondition", filter: "stars && !fork", wantMessage: "a number is not a condition", wantPosition: 1},
+		{name: "InvalidDate", filter: `pushed_at > "yesterday"`, wantMessage: `"yesterday" is not a date`, wantPosition: 13},
+		{name: "UnclosedParenthesis", filter: "(fork || archived", wantMessage: `unclosed "("`, wantPosition: 1},
+		{name: "TrailingToken", filter: "fork archived", wantMessage: "unexpected identifier archived", wantPosition: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFilter(tt.filter)
+			var filterErr *FilterError
+			if assert.ErrorAs(t, err, &filterErr) {
+				assert.Contains(t, filterErr.Message, tt.wantMessage)
+				assert.Equal(t, tt.wantPosition, filterErr.Position)
+			}
+		})
+	}
+
+	// The caret points at the problem
+	_, err := ParseFilter(`stars > "many"`)
+	assert.EqualError(t, err, "can't compare a number with a string using > at position 7 in the filter\n\tstars > \"many\"\n\t      ^")
+}
+
+func TestFilterFlag(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/language_repos.json")
+	defer func() { filterText = "" }()
+
+	filterText = `stars > 1000 && (language == "Go" || language == "Rust")`
+	assert.NoError(t, validateSearchOptions())
+	found, err := Search(testData, "cli")
+	assert.NoError(t, err)
+	kept, _ := ApplyFilters(found, activeFilters())
+	assert.ElementsMatch(t, []string{"spf13/cobra", "clap-rs/clap"}, uniqueRepos(kept))
+
+	filterText = "stars >"
+	assert.ErrorContains(t, validateSearchOptions(), "--filter: unexpected end of filter")
+}
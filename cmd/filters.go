@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"container/heap"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Link-/gh-stars/lib/pq"
+)
+
+// A Filter reports whether a repo is kept, regardless of the query. Filters are set with
+// flags and narrow down the results of a search or, without --find, the list of every
+// starred repo. They are applied before --min-rank and --limit.
+type Filter func(repo Repo) bool
+
+// activeFilters returns the filters set with flags, every repo is kept without them
+func activeFilters() []Filter {
+	var filters []Filter
+	if len(ignorePatterns) > 0 {
+		filters = append(filters, notIgnored(ignorePatterns))
+	}
+	if len(excludes) > 0 {
+		filters = append(filters, notExcluded(excludes))
+	}
+	if starList != nil {
+		filters = append(filters, inStarList(starList))
+	}
+	// The allowlist goes first, the blocklist then drops some of the owners it kept
+	if allowed := append(append([]string{}, onlyOwners...), owners...); len(allowed) > 0 {
+		filters = append(filters, ownedBy(allowed, true))
+	}
+	if len(excludeOwners) > 0 {
+		filters = append(filters, ownedBy(excludeOwners, false))
+	}
+	if len(languages) > 0 {
+		filters = append(filters, writtenIn(languages))
+	}
+	if minStars.set || maxStars.set {
+		filters = append(filters, starredBetween(minStars, maxStars))
+	}
+	if minOpenIssues.set || maxOpenIssues.set {
+		filters = append(filters, openIssuesBetween(minOpenIssues, maxOpenIssues))
+	}
+	if noArchived {
+		filters = append(filters, notArchived)
+	}
+	if archivedOnly {
+		filters = append(filters, isArchived)
+	}
+	if noTemplates {
+		filters = append(filters, notTemplate)
+	}
+	if noMirrors {
+		filters = append(filters, notMirror)
+	}
+	if len(allTopics) > 0 {
+		filters = append(filters, withTopics(allTopics, true))
+	}
+	if len(anyTopics) > 0 {
+		filters = append(filters, withTopics(anyTopics, false))
+	}
+	if len(licenses) > 0 {
+		filters = append(filters, licensedUnder(licenses))
+	}
+	if noForks {
+		filters = append(filters, notFork)
+	}
+	if forksOnly {
+		filters = append(filters, isFork)
+	}
+	if hasTopics {
+		filters = append(filters, withAnyTopic)
+	}
+	if hasDesc {
+		filters = append(filters, withDescription)
+	}
+	if keep, ok := visibilities[strings.ToLower(visibility)]; ok && keep != nil {
+		filters = append(filters, keep)
+	}
+	if pushedAfter.set() || pushedBefore.set() {
+		filters = append(filters, pushedBetween(pushedAfter, pushedBefore))
+	}
+	if starredAfter.set() || starredBefore.set() {
+		filters = append(filters, starredDuring(starredAfter, starredBefore))
+	}
+	// The expression was checked by validateSearchOptions
+	if filterText != "" {
+		if filter, err := ParseFilter(filterText); err == nil {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// withTopics returns the filter keeping the repos with every one of the topics when all is
+// set, and with at least one of them otherwise. Topics are compared as a whole, ignoring the
+// case, unlike the fuzzy search of the topics.
+func withTopics(topics []string, all bool) Filter {
+	return func(repo Repo) bool {
+		for _, topic := range topics {
+			found := false
+			for _, repoTopic := range repo.Topics {
+				if strings.EqualFold(strings.TrimSpace(topic), repoTopic) {
+					found = true
+					break
+				}
+			}
+			if found != all {
+				return found
+			}
+		}
+		return all
+	}
+}
+
+// licensedUnder returns the filter keeping the repos under one of the licenses, compared
+// to the SPDX identifier ignoring the case. The repos without a license are only kept by
+// the license none, and those with a license GitHub doesn't know by NOASSERTION.
+func licensedUnder(licenses []string) Filter {
+	return func(repo Repo) bool {
+		id := repo.SpdxId()
+		for _, license := range licenses {
+			license = strings.TrimSpace(license)
+			if id == "" && strings.EqualFold(license, "none") {
+				return true
+			}
+			if id != "" && strings.EqualFold(license, id) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// pushedBetween returns the filter keeping the repos last pushed to at or after the date
+// of after, and before the date of before. A date that isn't set keeps every repo, but the
+// repos without a push date are dropped as soon as one is.
+func pushedBetween(after dateFlag, before dateFlag) Filter {
+	return func(repo Repo) bool {
+		pushedAt := repo.PushedAt()
+		if pushedAt.IsZero() {
+			return false
+		}
+		return (!after.set() || !pushedAt.Before(after.time)) && (!before.set() || pushedAt.Before(before.time))
+	}
+}
+
+// starredDuring returns the filter keeping the repos starred at or after the date of after,
+// and before the date of before. Like pushedBetween, the repos without a star date are
+// dropped as soon as one of the dates is set.
+func starredDuring(after dateFlag, before dateFlag) Filter {
+	return func(repo Repo) bool {
+		starredAt := repo.StarredAt()
+		if starredAt.IsZero() {
+			return false
+		}
+		return (!after.set() || !starredAt.Before(after.time)) && (!before.set() || starredAt.Before(before.time))
+	}
+}
+
+// notArchived is the filter dropping the archived repos, see --no-archived
+func notArchived(repo Repo) bool {
+	return !repo.Archived
+}
+
+// isArchived is the filter keeping only the archived repos, see --archived-only
+func isArchived(repo Repo) bool {
+	return repo.Archived
+}
+
+// notFork is the filter dropping the forks, see --no-forks
+func notFork(repo Repo) bool {
+	return !repo.Fork
+}
+
+// isFork is the filter keeping only the forks, see --forks-only
+func isFork(repo Repo) bool {
+	return repo.Fork
+}
+
+// withAnyTopic is the filter keeping the repos with at least one topic, see --has-topics
+func withAnyTopic(repo Repo) bool {
+	return len(repo.Topics) > 0
+}
+
+// withDescription is the filter dropping the repos without a description, see --has-description
+func withDescription(repo Repo) bool {
+	return strings.TrimSpace(repo.Description) != ""
+}
+
+// Filters of the values of --visibility, all keeps every repo
+var visibilities = map[string]Filter{
+	"all":     nil,
+	"public":  func(repo Repo) bool { return !repo.Private },
+	"private": func(repo Repo) bool { return repo.Private },
+}
+
+// notTemplate is the filter dropping the template repos, see --no-templates
+func notTemplate(repo Repo) bool {
+	return !repo.Is_template
+}
+
+// notMirror is the filter dropping the mirrors, see --no-mirrors
+func notMirror(repo Repo) bool {
+	return repo.Mirror_url == ""
+}
+
+// A countFlag is the value of a flag like --min-stars or --max-open-issues, a number that
+// is only set once the flag is provided. Negative numbers are rejected when the flags are
+// parsed.
+type countFlag struct {
+	value int
+	set   bool
+}
+
+func (c *countFlag) String() string {
+	if !c.set {
+		return ""
+	}
+	return strconv.Itoa(c.value)
+}
+
+func (c *countFlag) Set(value string) error {
+	count, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("not a number")
+	}
+	if count < 0 {
+		return fmt.Errorf("the number must be positive")
+	}
+	c.value, c.set = count, true
+	return nil
+}
+
+func (c *countFlag) Type() string {
+	return "number"
+}
+
+// starredBetween returns the filter keeping the repos with at least min and at most max
+// stars, both included. A bound that isn't set keeps every repo.
+func starredBetween(min countFlag, max countFlag) Filter {
+	return func(repo Repo) bool {
+		return (!min.set || repo.Stars >= min.value) && (!max.set || repo.Stars <= max.value)
+	}
+}
+
+// openIssuesBetween returns the filter keeping the repos with at least min and at most max
+// open issues, both included. A bound that isn't set keeps every repo.
+func openIssuesBetween(min countFlag, max countFlag) Filter {
+	return func(repo Repo) bool {
+		return (!min.set || repo.Open_issues >= min.value) && (!max.set || repo.Open_issues <= max.value)
+	}
+}
+
+// writtenIn returns the filter keeping the repos written in one of the languages, compared
+// ignoring the case. GitHub detects no language in the repos without code, they are only
+// kept by the language none.
+func writtenIn(languages []string) Filter {
+	return func(repo Repo) bool {
+		for _, language := range languages {
+			language = strings.TrimSpace(language)
+			if repo.Language == "" && strings.EqualFold(language, "none") {
+				return true
+			}
+			if repo.Language != "" && strings.EqualFold(language, repo.Language) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ownedBy returns the filter keeping the repos of the owners when keep is set, and the
+// repos of every other owner otherwise. Logins are compared ignoring the case.
+func ownedBy(owners []string, keep bool) Filter {
+	return func(repo Repo) bool {
+		for _, owner := range owners {
+			if strings.EqualFold(strings.TrimSpace(owner), repo.Owner.Login) {
+				return keep
+			}
+		}
+		return !keep
+	}
+}
+
+// ApplyFilters returns the results kept by every filter and the number of results dropped
+func ApplyFilters(results pq.PriorityQueue, filters []Filter) (pq.PriorityQueue, int) {
+	if len(filters) == 0 {
+		return results, 0
+	}
+	var kept = make(pq.PriorityQueue, 0, results.Len())
+	for _, item := range results {
+		if keep(item.Value.(Result).Repo, filters) {
+			kept = append(kept, &pq.Item{Value: item.Value, Priority: item.Priority})
+		}
+	}
+	heap.Init(&kept)
+	return kept, results.Len() - kept.Len()
+}
+
+// keep reports whether every filter keeps the repo
+func keep(repo Repo, filters []Filter) bool {
+	for _, filter := range filters {
+		if !filter(repo) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,452 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+
+	popular := func(repo Repo) bool { return repo.Stars >= 10000 }
+	none := func(repo Repo) bool { return false }
+
+	tests := []struct {
+		name        string
+		filters     []Filter
+		wantKept    int
+		wantDropped int
+	}{
+		{name: "NoFilters", filters: nil, wantKept: 5, wantDropped: 0},
+		{name: "KeepsMatchingRepos", filters: []Filter{popular}, wantKept: 2, wantDropped: 3},
+		{name: "EveryFilterMustKeep", filters: []Filter{popular, none}, wantKept: 0, wantDropped: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Filters apply to the list of every starred repo, without a search term
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, dropped := ApplyFilters(found, tt.filters)
+			assert.Equal(t, tt.wantKept, kept.Len())
+			assert.Equal(t, tt.wantDropped, dropped)
+			for _, item := range kept {
+				for _, filter := range tt.filters {
+					assert.True(t, filter(item.Value.(Result).Repo))
+				}
+			}
+		})
+	}
+}
+
+func TestOwnerFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+
+	tests := []struct {
+		name          string
+		onlyOwners    []string
+		owners        []string
+		excludeOwners []string
+		wantRepos     []string
+	}{
+		{name: "NoOwners", wantRepos: []string{"karpathy/nanoGPT", "ianyh/Amethyst", "open-policy-agent/gatekeeper", "lithammer/fuzzysearch", "katiem0/gh-export-secrets"}},
+		{name: "OnlyOwnersIgnoresCase", onlyOwners: []string{"IANYH", "lithammer"}, wantRepos: []string{"ianyh/Amethyst", "lithammer/fuzzysearch"}},
+		{name: "ExcludeOwners", excludeOwners: []string{"karpathy", "katiem0"}, wantRepos: []string{"ianyh/Amethyst", "open-policy-agent/gatekeeper", "lithammer/fuzzysearch"}},
+		// The blocklist drops some of the owners of the allowlist
+		{name: "OnlyThenExclude", onlyOwners: []string{"ianyh", "lithammer"}, excludeOwners: []string{"Lithammer"}, wantRepos: []string{"ianyh/Amethyst"}},
+		{name: "ExcludeEveryAllowedOwner", onlyOwners: []string{"ianyh"}, excludeOwners: []string{"ianyh"}, wantRepos: []string{}},
+		{name: "Owner", owners: []string{"Ianyh"}, wantRepos: []string{"ianyh/Amethyst"}},
+		// --owner and --only-owner add up to a single allowlist
+		{name: "OwnerAndOnlyOwner", onlyOwners: []string{"lithammer"}, owners: []string{"ianyh"}, wantRepos: []string{"ianyh/Amethyst", "lithammer/fuzzysearch"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onlyOwners, owners, excludeOwners = tt.onlyOwners, tt.owners, tt.excludeOwners
+			defer func() { onlyOwners, owners, excludeOwners = nil, nil, nil }()
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.Equal(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+}
+
+func TestLanguageFilter(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/language_repos.json")
+	defer func() { languages = nil }()
+
+	tests := []struct {
+		name      string
+		languages []string
+		wantRepos []string
+	}{
+		{name: "IgnoresCase", languages: []string{"GO"}, wantRepos: []string{"spf13/cobra"}},
+		{name: "SeveralLanguages", languages: []string{"go", " rust"}, wantRepos: []string{"spf13/cobra", "clap-rs/clap"}},
+		{name: "WithoutLanguage", languages: []string{"none"}, wantRepos: []string{"agarrharr/awesome-cli-apps"}},
+		{name: "NoneAndLanguage", languages: []string{"None", "typescript"}, wantRepos: []string{"agarrharr/awesome-cli-apps", "oclif/oclif"}},
+		{name: "UnknownLanguage", languages: []string{"cobol"}, wantRepos: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			languages = tt.languages
+			// The filter applies after the search, the repos are all found
+			found, err := Search(testData, "cli")
+			assert.NoError(t, err)
+			assert.Equal(t, 4, found.Len())
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.Equal(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+}
+
+func TestStarFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	defer func() { minStars, maxStars = countFlag{}, countFlag{} }()
+
+	tests := []struct {
+		name      string
+		minStars  string
+		maxStars  string
+		wantRepos []string
+	}{
+		{name: "MinStarsIncludesTheBound", minStars: "12647", wantRepos: []string{"karpathy/nanoGPT", "ianyh/Amethyst"}},
+		{name: "MaxStarsIncludesTheBound", maxStars: "904", wantRepos: []string{"lithammer/fuzzysearch", "katiem0/gh-export-secrets"}},
+		{name: "Between", minStars: "500", maxStars: "5000", wantRepos: []string{"open-policy-agent/gatekeeper", "lithammer/fuzzysearch"}},
+		{name: "SameBound", minStars: "3", maxStars: "3", wantRepos: []string{"katiem0/gh-export-secrets"}},
+		{name: "MaxStarsZero", maxStars: "0", wantRepos: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minStars, maxStars = countFlag{}, countFlag{}
+			if tt.minStars != "" {
+				assert.NoError(t, minStars.Set(tt.minStars))
+			}
+			if tt.maxStars != "" {
+				assert.NoError(t, maxStars.Set(tt.maxStars))
+			}
+			assert.NoError(t, validateSearchOptions())
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.Equal(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+
+	// Negative and non-numeric values are rejected when the flags are parsed
+	var count countFlag
+	assert.ErrorContains(t, count.Set("-1"), "must be positive")
+	assert.ErrorContains(t, count.Set("many"), "not a number")
+	assert.False(t, count.set)
+	assert.Equal(t, "", count.String())
+
+	minStars, maxStars = countFlag{value: 100, set: true}, countFlag{value: 10, set: true}
+	assert.ErrorContains(t, validateSearchOptions(), "--min-stars 100 is above --max-stars 10")
+}
+
+func TestOpenIssueFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	defer func() { minOpenIssues, maxOpenIssues = countFlag{}, countFlag{} }()
+
+	tests := []struct {
+		name          string
+		minOpenIssues string
+		maxOpenIssues string
+		wantRepos     []string
+	}{
+		{name: "MinIncludesTheBound", minOpenIssues: "164", wantRepos: []string{"ianyh/Amethyst", "open-policy-agent/gatekeeper"}},
+		{name: "MaxIncludesTheBound", maxOpenIssues: "131", wantRepos: []string{"karpathy/nanoGPT", "lithammer/fuzzysearch", "katiem0/gh-export-secrets"}},
+		{name: "Between", minOpenIssues: "1", maxOpenIssues: "200", wantRepos: []string{"karpathy/nanoGPT", "open-policy-agent/gatekeeper", "lithammer/fuzzysearch"}},
+		{name: "NoOpenIssues", maxOpenIssues: "0", wantRepos: []string{"katiem0/gh-export-secrets"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minOpenIssues, maxOpenIssues = countFlag{}, countFlag{}
+			if tt.minOpenIssues != "" {
+				assert.NoError(t, minOpenIssues.Set(tt.minOpenIssues))
+			}
+			if tt.maxOpenIssues != "" {
+				assert.NoError(t, maxOpenIssues.Set(tt.maxOpenIssues))
+			}
+			assert.NoError(t, validateSearchOptions())
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.Equal(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+
+	minOpenIssues, maxOpenIssues = countFlag{value: 10, set: true}, countFlag{value: 5, set: true}
+	assert.ErrorContains(t, validateSearchOptions(), "--min-open-issues 10 is above --max-open-issues 5")
+}
+
+func TestArchivedFilter(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/archived_repos.json")
+	defer func() { noArchived, archivedOnly = false, false }()
+
+	found, err := Search(testData, "terraform")
+	assert.NoError(t, err)
+	kept, dropped := ApplyFilters(found, activeFilters())
+	assert.Zero(t, dropped)
+	assert.Len(t, kept, 3)
+
+	// The repos of caches written without the field aren't archived
+	noArchived = true
+	kept, dropped = ApplyFilters(found, activeFilters())
+	assert.Equal(t, 1, dropped)
+	assert.ElementsMatch(t, []string{"hashicorp/terraform-provider-aws", "gruntwork-io/terragrunt"}, uniqueRepos(kept))
+
+	// Listing every repo finds the ones archived since they were starred
+	noArchived, archivedOnly = false, true
+	assert.NoError(t, validateSearchOptions())
+	all, err := ListAll(testData)
+	assert.NoError(t, err)
+	kept, _ = ApplyFilters(all, activeFilters())
+	assert.Equal(t, []string{"hashicorp/terraform-provider-template"}, uniqueRepos(kept))
+
+	noArchived = true
+	assert.ErrorContains(t, validateSearchOptions(), "--archived-only can't be combined with --no-archived")
+}
+
+func TestLicenseFilter(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/license_repos.json")
+	defer func() { licenses = nil }()
+
+	tests := []struct {
+		name      string
+		licenses  []string
+		wantRepos []string
+	}{
+		{name: "NoFilter", wantRepos: []string{"go-yaml/yaml", "nodeca/js-yaml", "jbeder/yaml-cpp", "someone/yamlfmt"}},
+		{name: "OneLicense", licenses: []string{"MIT"}, wantRepos: []string{"nodeca/js-yaml"}},
+		{name: "IgnoresCase", licenses: []string{"mit", " apache-2.0"}, wantRepos: []string{"go-yaml/yaml", "nodeca/js-yaml"}},
+		{name: "NoLicense", licenses: []string{"none"}, wantRepos: []string{"someone/yamlfmt"}},
+		{name: "UnknownLicense", licenses: []string{"NOASSERTION"}, wantRepos: []string{"jbeder/yaml-cpp"}},
+		{name: "MissingLicense", licenses: []string{"GPL-3.0"}, wantRepos: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			licenses = tt.licenses
+			found, err := Search(testData, "yaml")
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.ElementsMatch(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+}
+
+func TestVisibilityFilter(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/license_repos.json")
+	defer func() { visibility = "all" }()
+
+	tests := []struct {
+		name       string
+		visibility string
+		wantRepos  []string
+	}{
+		{name: "All", visibility: "all", wantRepos: []string{"go-yaml/yaml", "nodeca/js-yaml", "jbeder/yaml-cpp", "someone/yamlfmt"}},
+		{name: "Public", visibility: "public", wantRepos: []string{"go-yaml/yaml", "nodeca/js-yaml", "jbeder/yaml-cpp"}},
+		{name: "Private", visibility: "Private", wantRepos: []string{"someone/yamlfmt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visibility = tt.visibility
+			assert.NoError(t, validateSearchOptions())
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.ElementsMatch(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+
+	visibility = "internal"
+	assert.ErrorContains(t, validateSearchOptions(), `--visibility must be one of public, private or all, got: "internal"`)
+}
+
+func TestHygieneFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/hygiene_repos.json")
+	defer func() { hasTopics, hasDesc = false, false }()
+
+	tests := []struct {
+		name      string
+		hasTopics bool
+		hasDesc   bool
+		wantRepos []string
+	}{
+		{name: "NoFilter", wantRepos: []string{"BurntSushi/ripgrep", "someone/grep-notes", "someone/grep-tool", "someone/grep"}},
+		{name: "HasTopics", hasTopics: true, wantRepos: []string{"BurntSushi/ripgrep", "someone/grep-tool"}},
+		{name: "HasDescription", hasDesc: true, wantRepos: []string{"BurntSushi/ripgrep", "someone/grep-notes"}},
+		{name: "Both", hasTopics: true, hasDesc: true, wantRepos: []string{"BurntSushi/ripgrep"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasTopics, hasDesc = tt.hasTopics, tt.hasDesc
+			found, err := Search(testData, "grep")
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.ElementsMatch(t, tt.wantRepos, uniqueRepos(kept))
+
+			// The list of every repo is filtered the same
+			found, err = ListAll(testData)
+			assert.NoError(t, err)
+			kept, _ = ApplyFilters(found, activeFilters())
+			assert.ElementsMatch(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+}
+
+func TestForkFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/fork_repos.json")
+	defer func() { noForks, forksOnly = false, false }()
+
+	tests := []struct {
+		name      string
+		noForks   bool
+		forksOnly bool
+		wantRepos []string
+	}{
+		{name: "NoFilter", wantRepos: []string{"acme/linter"}},
+		{name: "NoForks", noForks: true, wantRepos: []string{"acme/linter"}},
+		// Without acme/linter, bob/linter is grouped under alice/linter, the fork it was forked from
+		{name: "ForksOnly", forksOnly: true, wantRepos: []string{"alice/linter"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noForks, forksOnly = tt.noForks, tt.forksOnly
+			assert.NoError(t, validateSearchOptions())
+			found, err := Search(testData, "linter")
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.ElementsMatch(t, tt.wantRepos, uniqueRepos(CollapseForks(kept, testParents)))
+		})
+	}
+
+	noForks, forksOnly = true, true
+	assert.ErrorContains(t, validateSearchOptions(), "--forks-only can't be combined with --no-forks")
+}
+
+func TestTemplateAndMirrorFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/template_mirror_repos.json")
+	defer func() { noTemplates, noMirrors = false, false }()
+
+	// The repos of caches written without the fields are neither templates nor mirrors
+	repos := loadRepos(t, "testdata/template_mirror_repos.json")
+	assert.Equal(t, []bool{true, false, false}, []bool{repos[0].Is_template, repos[1].Is_template, repos[2].Is_template})
+	assert.Equal(t, []bool{false, true, false}, []bool{repos[0].Mirror, repos[1].Mirror, repos[2].Mirror})
+
+	tests := []struct {
+		name        string
+		noTemplates bool
+		noMirrors   bool
+		wantRepos   []string
+	}{
+		{name: "NoFilter", wantRepos: []string{"vercel/nextjs-starter", "someone/website-mirror", "someone/website"}},
+		{name: "NoTemplates", noTemplates: true, wantRepos: []string{"someone/website-mirror", "someone/website"}},
+		{name: "NoMirrors", noMirrors: true, wantRepos: []string{"vercel/nextjs-starter", "someone/website"}},
+		{name: "Both", noTemplates: true, noMirrors: true, wantRepos: []string{"someone/website"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noTemplates, noMirrors = tt.noTemplates, tt.noMirrors
+			found, err := Search(testData, "website")
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.ElementsMatch(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+}
+
+func TestTopicFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/language_repos.json")
+	defer func() { allTopics, anyTopics = nil, nil }()
+
+	tests := []struct {
+		name      string
+		allTopics []string
+		anyTopics []string
+		wantRepos []string
+	}{
+		{name: "EveryTopic", allTopics: []string{"CLI", "go"}, wantRepos: []string{"spf13/cobra"}},
+		{name: "EveryTopicMissingOne", allTopics: []string{"go", "rust"}, wantRepos: []string{}},
+		{name: "AnyTopic", anyTopics: []string{"go", " rust"}, wantRepos: []string{"spf13/cobra", "clap-rs/clap"}},
+		{name: "WholeTopicsOnly", anyTopics: []string{"type"}, wantRepos: []string{}},
+		{name: "EveryAndAny", allTopics: []string{"cli"}, anyTopics: []string{"awesome", "typescript"}, wantRepos: []string{"agarrharr/awesome-cli-apps", "oclif/oclif"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allTopics, anyTopics = tt.allTopics, tt.anyTopics
+			// The topics filter the list of every repo as well
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.Equal(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+}
+
+func TestPushedFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	defer func() { pushedAfter, pushedBefore = dateFlag{}, dateFlag{} }()
+
+	tests := []struct {
+		name         string
+		pushedAfter  string
+		pushedBefore string
+		wantRepos    []string
+	}{
+		{name: "AfterDay", pushedAfter: "2023-04-05", wantRepos: []string{"karpathy/nanoGPT", "open-policy-agent/gatekeeper", "katiem0/gh-export-secrets"}},
+		{name: "AfterIncludesTheDate", pushedAfter: "2023-04-07T16:17:54Z", wantRepos: []string{"open-policy-agent/gatekeeper"}},
+		{name: "BeforeExcludesTheDate", pushedBefore: "2023-04-01T13:09:55Z", wantRepos: []string{"lithammer/fuzzysearch"}},
+		{name: "Between", pushedAfter: "2023-04-02", pushedBefore: "2023-04-07", wantRepos: []string{"katiem0/gh-export-secrets"}},
+		{name: "Relative", pushedAfter: "1w", wantRepos: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pushedAfter, pushedBefore = dateFlag{}, dateFlag{}
+			if tt.pushedAfter != "" {
+				assert.NoError(t, pushedAfter.Set(tt.pushedAfter))
+			}
+			if tt.pushedBefore != "" {
+				assert.NoError(t, pushedBefore.Set(tt.pushedBefore))
+			}
+			assert.NoError(t, validateSearchOptions())
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.Equal(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+
+	// The repos without a push date are dropped as soon as a date is set
+	assert.False(t, pushedBetween(dateFlag{}, dateFlag{text: "1y", time: time.Now()})(Repo{Full_name: "someone/unknown"}))
+
+	pushedAfter, pushedBefore = dateFlag{}, dateFlag{}
+	assert.NoError(t, pushedAfter.Set("2023-04-07"))
+	assert.NoError(t, pushedBefore.Set("2023-04-01"))
+	assert.ErrorContains(t, validateSearchOptions(), "--pushed-after 2023-04-07 is not before --pushed-before 2023-04-01")
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Link-/gh-stars/lib/pq"
+)
+
+const PARENTS_BATCH_SIZE = 50 // Forks whose parent is asked for in a single GraphQL query
+
+// groupForks collapses the forks among the results under their upstream, see CollapseForks.
+// The parents of the forks are optional, the forks are listed on their own without them,
+// like with --input where they aren't fetched.
+func groupForks(results pq.PriorityQueue, cacheKey [32]byte) pq.PriorityQueue {
+	if inputFile != "" {
+		return results
+	}
+	var forks []string
+	for _, item := range results {
+		if repo := item.Value.(Result).Repo; repo.Fork {
+			forks = append(forks, repo.Full_name)
+		}
+	}
+	if len(forks) == 0 {
+		return results
+	}
+	parents, err := GetParents(forks, cacheKey)
+	if err != nil {
+		WarnLogger.Println("Not able to get the parents of the forks, listing them on their own:", err)
+		return results
+	}
+	return CollapseForks(results, parents)
+}
+
+// CollapseForks groups the forks under their upstream when the upstream is among the
+// results as well: the forks are dropped from the results and listed in the Forks of the
+// upstream, which ranks like the best of the group. The upstream of a fork of a fork is
+// its oldest ancestor in the results. A fork whose upstream isn't in the results stays on
+// its own.
+func CollapseForks(results pq.PriorityQueue, parents map[string]string) pq.PriorityQueue {
+	byName := make(map[string]*pq.Item, results.Len())
+	for _, item := range results {
+		byName[item.Value.(Result).Full_name] = item
+	}
+	upstream := func(name string) (string, bool) {
+		root := ""
+		seen := map[string]bool{name: true}
+		for parent := parents[name]; parent != "" && !seen[parent]; parent = parents[parent] {
+			if _, ok := byName[parent]; ok {
+				root = parent
+			}
+			seen[parent] = true
+		}
+		return root, root != ""
+	}
+
+	// Forks are added in the order of their rank, the best one first
+	sorted := append(pq.PriorityQueue{}, results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted.Less(i, j) })
+	groups := make(map[string][]*pq.Item)
+	for _, item := range sorted {
+		name := item.Value.(Result).Full_name
+		if root, ok := upstream(name); ok {
+			groups[root] = append(groups[root], item)
+		}
+	}
+
+	var collapsed = make(pq.PriorityQueue, 0, results.Len())
+	for _, item := range sorted {
+		result := item.Value.(Result)
+		if _, ok := upstream(result.Full_name); ok {
+			continue
+		}
+		priority := item.Priority
+		for _, fork := range groups[result.Full_name] {
+			forkResult := fork.Value.(Result)
+			forkResult.rank = fork.Priority
+			result.Forks = append(result.Forks, forkResult)
+			if fork.Priority > priority {
+				priority = fork.Priority
+			}
+		}
+		collapsed = append(collapsed, &pq.Item{Value: result, Priority: priority})
+	}
+	heap.Init(&collapsed)
+	return collapsed
+}
+
+// GetParents returns the full name of the parent of every fork, from the cache of the
+// parents next to the cache file. The forks missing from the cache are fetched and added
+// to it. A fork whose parent was deleted has an empty parent.
+func GetParents(forks []string, cacheKey [32]byte) (map[string]string, error) {
+	// Without a cache file, the parents are fetched without being cached
+	path, err := GetParentsPath(cacheKey)
+	if err != nil && !errors.Is(err, errCacheBypassed) {
+		return nil, err
+	}
+
+	parents := make(map[string]string)
+	if data, err := os.ReadFile(path); path != "" && err == nil && len(data) > 0 {
+		InfoLogger.Println("Reading the parents of the forks from the cache:", path)
+		if err := json.Unmarshal(data, &parents); err != nil {
+			return nil, fmt.Errorf("not able to read the parents cache %s: %w", path, err)
+		}
+	}
+
+	var pending []string
+	for _, fork := range forks {
+		if _, ok := parents[fork]; !ok {
+			pending = append(pending, fork)
+		}
+	}
+	if len(pending) == 0 {
+		return parents, nil
+	}
+
+	for start := 0; start < len(pending); start += PARENTS_BATCH_SIZE {
+		end := start + PARENTS_BATCH_SIZE
+		if end > len(pending) {
+			end = len(pending)
+		}
+		fetched, err := fetchParents(pending[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for fork, parent := range fetched {
+			parents[fork] = parent
+		}
+	}
+	if path == "" {
+		return parents, nil
+	}
+	InfoLogger.Printf("Writing the parents of %d forks to the cache: %s", len(pending), path)
+	data, err := json.Marshal(parents)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveCache(path, data); err != nil {
+		return nil, err
+	}
+	return parents, nil
+}
+
+// fetchParents returns the full name of the parent of every fork with a single GraphQL
+// query. The starred repos returned by the REST API don't include the parent of a fork.
+func fetchParents(forks []string) (map[string]string, error) {
+	InfoLogger.Printf("Fetching the parents of %d forks", len(forks))
+	var query strings.Builder
+	query.WriteString("query {")
+	for i, fork := range forks {
+		owner, name, _ := strings.Cut(fork, "/")
+		fmt.Fprintf(&query, " r%d: repository(owner: %q, name: %q) { parent { nameWithOwner } }", i, owner, name)
+	}
+	query.WriteString(" }")
+
+	stdOut, stdErr, err := ghClient.Exec("api", "graphql", "-f", "query="+query.String())
+	// A fork that can't be found fails the query, the others are still in the response
+	if err != nil && stdOut.Len() == 0 {
+		return nil, fmt.Errorf("%w %s", err, stdErr.String())
+	}
+	var response struct {
+		Data map[string]*struct {
+			Parent *struct {
+				NameWithOwner string `json:"nameWithOwner"`
+			} `json:"parent"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(stdOut.Bytes(), &response); err != nil {
+		return nil, err
+	}
+
+	parents := make(map[string]string, len(forks))
+	for i, fork := range forks {
+		repository := response.Data[fmt.Sprintf("r%d", i)]
+		if repository == nil {
+			InfoLogger.Println("Not able to find the fork", fork)
+			continue
+		}
+		parents[fork] = ""
+		if repository.Parent != nil {
+			parents[fork] = repository.Parent.NameWithOwner
+		}
+	}
+	return parents, nil
+}
+
+// GetParentsPath returns the path of the cache of the parents of the forks, next to the
+// cache file.
+//
+// Example: ~/.cache/gh-stars/stars_link-_2d06a89b2687.parents.json for ~/.cache/gh-stars/stars_link-_2d06a89b2687.json.gz
+func GetParentsPath(cacheKey [32]byte) (string, error) {
+	path, err := GetCachePath(cacheKey)
+	if err != nil {
+		return "", fmt.Errorf("not able to locate the cache: %w", err)
+	}
+	return trimCacheExt(path) + ".parents.json", nil
+}
+
+// forksLabel returns the annotation of an upstream with collapsed forks, e.g. (+2 forks)
+func forksLabel(forks int) string {
+	if forks == 1 {
+		return "(+1 fork)"
+	}
+	return fmt.Sprintf("(+%d forks)", forks)
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockParentsGithub answers the GraphQL queries for the parents of the forks
+type mockParentsGithub struct {
+	parents map[string]string
+	calls   int
+}
+
+func (m *mockParentsGithub) Exec(args ...string) (bytes.Buffer, bytes.Buffer, error) {
+	m.calls++
+	query := args[len(args)-1]
+	data := make(map[string]any)
+	for _, match := range regexp.MustCompile(`(r\d+): repository\(owner: "([^"]+)", name: "([^"]+)"\)`).FindAllStringSubmatch(query, -1) {
+		parent, ok := m.parents[match[2]+"/"+match[3]]
+		switch {
+		case !ok:
+			data[match[1]] = nil
+		case parent == "":
+			data[match[1]] = map[string]any{"parent": nil}
+		default:
+			data[match[1]] = map[string]any{"parent": map[string]string{"nameWithOwner": parent}}
+		}
+	}
+	response, _ := json.Marshal(map[string]any{"data": data})
+	return *bytes.NewBuffer(response), bytes.Buffer{}, nil
+}
+
+// In fork_repos.json, bob/linter is a fork of alice/linter, itself a fork of acme/linter.
+// carol/formatter is a fork of a repo that wasn't starred.
+var testParents = map[string]string{
+	"alice/linter":    "acme/linter",
+	"bob/linter":      "alice/linter",
+	"carol/formatter": "dave/formatter",
+}
+
+func TestCollapseForks(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/fork_repos.json")
+
+	tests := []struct {
+		name      string
+		find      string
+		wantRepos []string
+		wantForks [][]string
+	}{
+		{
+			name:      "ForksUnderUpstream",
+			find:      "linter",
+			wantRepos: []string{"acme/linter"},
+			wantForks: [][]string{{"alice/linter", "bob/linter"}},
+		},
+		{
+			name:      "UpstreamMissingFromResults",
+			find:      "fixes",
+			wantRepos: []string{"bob/linter"},
+			wantForks: [][]string{nil},
+		},
+		{
+			name:      "UpstreamNotStarred",
+			find:      "formatter",
+			wantRepos: []string{"carol/formatter"},
+			wantForks: [][]string{nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := Search(testData, tt.find)
+			assert.NoError(t, err)
+			results := topResults(CollapseForks(found, testParents), -1)
+			gotRepos := []string{}
+			gotForks := [][]string{}
+			for _, result := range results {
+				gotRepos = append(gotRepos, result.Full_name)
+				var forks []string
+				for _, fork := range result.Forks {
+					forks = append(forks, fork.Full_name)
+				}
+				gotForks = append(gotForks, forks)
+			}
+			assert.Equal(t, tt.wantRepos, gotRepos)
+			assert.Equal(t, tt.wantForks, gotForks)
+		})
+	}
+
+	// The group ranks like its best repo, bob/linter matches fixes and shell
+	found, err := Search(testData, "shell fixes")
+	assert.NoError(t, err)
+	best := found[0].Priority
+	collapsed := CollapseForks(found, testParents)
+	assert.Equal(t, 2, collapsed.Len())
+	assert.Equal(t, best, collapsed[0].Priority)
+	assert.Equal(t, "acme/linter", collapsed[0].Value.(Result).Full_name)
+}
+
+func TestGetParents(t *testing.T) {
+	setup([]string{})
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+
+	github := &mockParentsGithub{parents: map[string]string{"alice/linter": "acme/linter", "erin/deleted": ""}}
+	ghClient = github
+	parents, err := GetParents([]string{"alice/linter", "erin/deleted", "frank/missing"}, [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice/linter": "acme/linter", "erin/deleted": ""}, parents)
+	assert.Equal(t, 1, github.calls)
+	assert.FileExists(t, filepath.Join(filepath.Dir(cacheFile), "stars.parents.json"))
+
+	// The parents are read from the cache, only the missing forks are fetched again
+	_, err = GetParents([]string{"alice/linter", "erin/deleted"}, [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, github.calls)
+	_, err = GetParents([]string{"alice/linter", "frank/missing"}, [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, github.calls)
+
+	// With --no-cache, the parents are fetched every time and never cached
+	noCache = true
+	defer func() { noCache = false }()
+	assert.NoError(t, os.Remove(filepath.Join(filepath.Dir(cacheFile), "stars.parents.json")))
+	parents, err = GetParents([]string{"alice/linter"}, [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice/linter": "acme/linter"}, parents)
+	assert.Equal(t, 3, github.calls)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(cacheFile), "stars.parents.json"))
+}
+
+func TestRenderForks(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/fork_repos.json")
+	defer func() {
+		showForks = false
+		jsonOutput = false
+	}()
+	render := func() string {
+		found, err := Search(testData, "linter")
+		assert.NoError(t, err)
+		var output bytes.Buffer
+		assert.NoError(t, Render(CollapseForks(found, testParents), 10, &output))
+		return output.String()
+	}
+
+	// The forks are counted next to the name of the upstream
+	lines := strings.Split(strings.TrimSpace(render()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "acme/linter (+2 forks)")
+
+	// With --show-forks they are listed below it
+	showForks = true
+	lines = strings.Split(strings.TrimSpace(render()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[2], "fork: alice/linter")
+	assert.Contains(t, lines[3], "fork: bob/linter")
+	assert.NotContains(t, lines[1], "forks)")
+
+	// The JSON output nests them under the upstream
+	jsonOutput = true
+	var results []Result
+	assert.NoError(t, json.Unmarshal([]byte(render()), &results))
+	assert.Len(t, results, 1)
+	assert.Len(t, results[0].Forks, 2)
+	assert.Equal(t, "alice/linter", results[0].Forks[0].Full_name)
+}
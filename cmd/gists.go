@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/cli/go-gh/pkg/tableprinter"
+)
+
+const FILENAME_SCORE = 2 * DESCRIPTION_SCORE // Score of an exact match in the filenames of a gist, which are its name on GitHub
+
+// A Gist is a starred gist, as returned by the API
+type Gist struct {
+	Id          string              `json:"id"`
+	Description string              `json:"description"`
+	Url         string              `json:"html_url"`
+	Files       map[string]GistFile `json:"files"`
+}
+
+// A GistFile is one of the files of a gist
+type GistFile struct {
+	Filename string `json:"filename"`
+}
+
+// Filenames returns the names of the files of the gist in alphabetical order
+func (g Gist) Filenames() []string {
+	names := make([]string, 0, len(g.Files))
+	for name := range g.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A GistResult is a gist returned by a search, along with the best match of every search term
+type GistResult struct {
+	Id          string   `json:"id"`
+	Description string   `json:"description"`
+	Files       []string `json:"files"`
+	Url         string   `json:"html_url"`
+	Matches     []Match  `json:"matched,omitempty"`
+}
+
+// Before orders the gists with the same rank by id, for a deterministic order
+func (r GistResult) Before(other any) bool {
+	o, ok := other.(GistResult)
+	if !ok {
+		return false
+	}
+	return r.Id < o.Id
+}
+
+// runGists searches the starred gists of the authenticated user, see --gists
+func runGists(queries []string) {
+	if err := checkGistsUser(user); err != nil {
+		ErrorLogger.Fatal(err)
+	}
+	key, err := GenerateGistsCacheKey(user)
+	if err != nil {
+		ErrorLogger.Fatal("Not able to generate a cache key", err)
+	}
+	starred, err := GetStarredGists(key)
+	if err != nil {
+		ErrorLogger.Fatal("Not able to get starred gists", err)
+	}
+	found, err := SearchGists(starred, queries)
+	if err != nil {
+		ErrorLogger.Fatal("Not able to search starred gists", err)
+	}
+	if minRank > 0 {
+		var suppressed int
+		found, suppressed = FilterMinRank(found, minRank)
+		if suppressed > 0 {
+			WarnLogger.Printf("%d results with a rank below %d were suppressed", suppressed, minRank)
+		}
+	}
+	if found.Len() == 0 {
+		if len(queries) == 0 {
+			WarnLogger.Printf("%s has not starred any gist", user)
+		} else {
+			WarnLogger.Printf("No results for %s in the starred gists", quoteQueries(queries))
+		}
+	}
+	if err := RenderGists(found, limit, os.Stdout); err != nil {
+		ErrorLogger.Fatal("Not able to render the table", err)
+	}
+}
+
+// checkGistsUser returns an error unless the user is the authenticated user, GitHub only
+// lists the starred gists of the authenticated user
+func checkGistsUser(user string) error {
+	login, err := AuthenticatedUser()
+	if err != nil {
+		return fmt.Errorf("not able to get the authenticated user: %w", err)
+	}
+	if !strings.EqualFold(login, user) {
+		return fmt.Errorf("--gists only works for the authenticated user %s, GitHub doesn't list the starred gists of %s", login, user)
+	}
+	return nil
+}
+
+// GenerateGistsCacheKey returns the cache key of the starred gists, built like the cache
+// key of the starred repos from the Link header of the first page, see GenerateCacheKey.
+// The key is prefixed so the gists never share a cache with the repos.
+func GenerateGistsCacheKey(user string) ([32]byte, error) {
+	InfoLogger.Println("Attempting to fetch the total number of starred gists for user", user)
+	stdOut, stdErr, err := ghClient.Exec("api", "--include", "gists/starred?page=1&per_page=1")
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("not able to list the starred gists: %w %s", err, stdErr.String())
+	}
+	var header string
+	for _, line := range strings.Split(stdOut.String(), "\n") {
+		if strings.HasPrefix(strings.ToLower(line), "link:") {
+			header = strings.TrimSpace(line[len("link:"):])
+			break
+		}
+	}
+	cacheKey := sha256.Sum256([]byte("gists:" + user + ":" + header))
+	InfoLogger.Println("Gists CacheKey generated:", fmt.Sprintf("%x", cacheKey))
+	return cacheKey, nil
+}
+
+// GetGistsPath returns the path of the cache of the starred gists. It is next to the cache
+// file when one is provided, otherwise the first 6 bytes of the cache key name it.
+//
+// Example: ~/.cache/gh-stars/gists_2d06a89b2687.json, or stars.gists.json for --cache-file stars.json
+func GetGistsPath(cacheKey [32]byte) (string, error) {
+	if cacheFile != "" {
+		path, err := expandHome(cacheFile)
+		if err != nil {
+			return "", err
+		}
+		return trimCacheExt(path) + ".gists.json", nil
+	}
+	if cacheKey == [32]byte{} {
+		return "", fmt.Errorf("cachekey cannot be empty, the implementation is faulty")
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("gists_%x.json", cacheKey[:6])), nil
+}
+
+// GetStarredGists returns the starred gists of the authenticated user, from the cache if
+// it isn't empty, otherwise from the API and the result is cached. With --no-cache, the
+// gists are fetched without reading or writing the cache.
+func GetStarredGists(cacheKey [32]byte) (bytes.Buffer, error) {
+	if noCache {
+		InfoLogger.Println("The cache is bypassed with --no-cache. Fetching the starred gists")
+		return fetchStarredGists()
+	}
+	path, err := GetGistsPath(cacheKey)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	if cacheFile == "" && !cacheReadOnly {
+		if err := prepareCacheFile(path, filepath.Base(path)); err != nil {
+			return bytes.Buffer{}, err
+		}
+	} else if !cacheReadOnly {
+		if err := createCacheFileDir(path); err != nil {
+			return bytes.Buffer{}, err
+		}
+	}
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+		InfoLogger.Println("Reading the starred gists from the cache:", path)
+		return *bytes.NewBuffer(data), nil
+	}
+
+	InfoLogger.Println("Cache is empty. Fetching the starred gists")
+	result, err := fetchStarredGists()
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+
+	InfoLogger.Println("Writing the fetched gists to cache:", path)
+	if err := saveCache(path, result.Bytes()); err != nil {
+		return bytes.Buffer{}, err
+	}
+	return result, nil
+}
+
+// fetchStarredGists fetches every page of the starred gists of the authenticated user
+func fetchStarredGists() (bytes.Buffer, error) {
+	stdOut, stdErr, err := ghClient.Exec("api", "--paginate", "gists/starred")
+	if err != nil {
+		return bytes.Buffer{}, fmt.Errorf("%w %s", err, stdErr.String())
+	}
+	// gh api --paginate concatenates the pages, e.g. [...][...]
+	return *bytes.NewBufferString(strings.Replace(stdOut.String(), "][", ",", -1)), nil
+}
+
+// SearchGists runs the queries against the description and the filenames of the starred
+// gists, see Search. Every gist is listed with a rank of 0 without queries. Terms scoped
+// to the description with a prefix only search it, the other prefixes match nothing.
+// Boolean queries and wildcards are not supported.
+func SearchGists(starredGists bytes.Buffer, queries []string) (pq.PriorityQueue, error) {
+	var all []Gist
+	if err := json.Unmarshal(starredGists.Bytes(), &all); err != nil {
+		return nil, err
+	}
+
+	var found = make(pq.PriorityQueue, 0)
+	heap.Init(&found)
+	if len(queries) == 0 {
+		for _, gist := range all {
+			heap.Push(&found, &pq.Item{Value: newGistResult(gist, nil), Priority: 0})
+		}
+		return found, nil
+	}
+
+	best := make(map[string]*pq.Item)
+	for _, query := range queries {
+		if IsBooleanQuery(query) {
+			return nil, fmt.Errorf("boolean queries are not supported with --gists: %q", query)
+		}
+		terms, err := ParseQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		needles, excluded := splitTerms(terms)
+		for _, gist := range all {
+			matches, ok := scoreGist(gist, needles, excluded)
+			if !ok {
+				continue
+			}
+			// A gist matching several queries is ranked by the query it matches best
+			rank := totalScore(matches)
+			if item, seen := best[gist.Id]; !seen || rank > item.Priority {
+				best[gist.Id] = &pq.Item{Value: newGistResult(gist, matches), Priority: rank}
+			}
+		}
+	}
+	for _, item := range best {
+		heap.Push(&found, item)
+	}
+	return found, nil
+}
+
+func newGistResult(gist Gist, matches []Match) GistResult {
+	return GistResult{Id: gist.Id, Description: gist.Description, Files: gist.Filenames(), Url: gist.Url, Matches: matches}
+}
+
+// scoreGist returns the best match of every needle in the gist, and false when the gist
+// doesn't match, see --match-all, or contains an excluded term
+func scoreGist(gist Gist, needles []Term, excluded []Term) ([]Match, bool) {
+	for _, term := range excluded {
+		if term.Field != "" && term.Field != "description" {
+			continue
+		}
+		value := normalize(term.Value)
+		candidates := []string{gist.Description}
+		if term.Field == "" {
+			candidates = append(candidates, gist.Filenames()...)
+		}
+		for _, candidate := range candidates {
+			if strings.Contains(normalize(candidate), value) {
+				return nil, false
+			}
+		}
+	}
+
+	var descriptionWords []string
+	for _, word := range tokenize(gist.Description) {
+		descriptionWords = append(descriptionWords, word.Text)
+	}
+	var fileWords []string
+	for _, name := range gist.Filenames() {
+		fileWords = append(fileWords, name)
+		fileWords = append(fileWords, splitName(strings.TrimSuffix(name, filepath.Ext(name)))...)
+	}
+
+	var matches []Match
+	for _, needle := range needles {
+		var candidates []Match
+		if needle.Field == "" || needle.Field == "description" {
+			if match, ok := bestGistMatch("description", DESCRIPTION_SCORE, needle.Value, descriptionWords); ok {
+				match.Start, match.End = textSpan(gist.Description, match.Word)
+				candidates = append(candidates, match)
+			}
+		}
+		if needle.Field == "" {
+			if match, ok := bestGistMatch("files", FILENAME_SCORE, needle.Value, fileWords); ok {
+				match.Start, match.End = filenameSpan(gist.Filenames(), match.Word)
+				candidates = append(candidates, match)
+			}
+		}
+		if len(candidates) == 0 {
+			if matchAll {
+				return nil, false
+			}
+			continue
+		}
+		matches = append(matches, bestOf(candidates))
+	}
+	return matches, len(matches) > 0
+}
+
+// filenameSpan returns the byte offsets of the word in the first filename containing it,
+// every filename is a field of its own
+func filenameSpan(filenames []string, word string) (int, int) {
+	for _, name := range filenames {
+		if strings.Contains(name, word) {
+			return textSpan(name, word)
+		}
+	}
+	return 0, 0
+}
+
+// bestGistMatch returns the closest word matching the needle, scored out of fieldScore,
+// and false if none of the words match
+func bestGistMatch(field string, fieldScore int, needle string, words []string) (Match, bool) {
+	best := Match{Field: field, Needle: needle}
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		if distance, ok := matchWord(needle, word); ok {
+			if score := rankScore(fieldScore, needle, word, distance); score > best.Score {
+				best.Word = word
+				best.Distance = distance
+				best.Score = score
+			}
+		}
+	}
+	return best, best.Score > 0
+}
+
+// RenderGists renders the gists in a table with their id, description, files and URL, or
+// in JSON format with --json
+func RenderGists(results pq.PriorityQueue, limit int, renderTarget io.Writer) error {
+	renderLimit := RenderLimit(results.Len(), limit)
+	if jsonOutput {
+		InfoLogger.Println("Rendering the gists in JSON format")
+		// No results are rendered as an empty array rather than null
+		gists := []GistResult{}
+		for i := 0; i < renderLimit; i++ {
+			gists = append(gists, heap.Pop(&results).(*pq.Item).Value.(GistResult))
+		}
+		jsonOutput, err := json.MarshalIndent(gists, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(renderTarget, "%s", jsonOutput)
+		return nil
+	}
+
+	InfoLogger.Println("Rendering the gists in table format")
+	tp := tableprinter.New(renderTarget, true, tableMaxWidth)
+	headerRow := []string{"ID", "Description", "Files", "URL"}
+	if !listAll {
+		headerRow = append(headerRow, "Rank", "Matched")
+	}
+	for _, item := range headerRow {
+		tp.AddField(item)
+	}
+	tp.EndRow()
+	for i := 0; i < renderLimit; i++ {
+		item := heap.Pop(&results).(*pq.Item)
+		result := item.Value.(GistResult)
+		tp.AddField(result.Id)
+		tp.AddField(result.Description)
+		tp.AddField(strings.Join(result.Files, ", "))
+		tp.AddField(result.Url)
+		if !listAll {
+			tp.AddField(fmt.Sprintf("%d", item.Priority))
+			var matched []string
+			for _, match := range result.Matches {
+				matched = append(matched, match.String())
+			}
+			tp.AddField(strings.Join(matched, ", "))
+		}
+		tp.EndRow()
+	}
+	return tp.Render()
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockGistsGithub serves the authenticated user and the pages of the starred gists
+type mockGistsGithub struct {
+	login string
+	pages string
+	calls []string
+}
+
+func (m *mockGistsGithub) Exec(args ...string) (bytes.Buffer, bytes.Buffer, error) {
+	call := strings.Join(args, " ")
+	m.calls = append(m.calls, call)
+	switch call {
+	case "api user --jq .login":
+		return *bytes.NewBufferString(m.login + "\n"), bytes.Buffer{}, nil
+	case "api --paginate gists/starred":
+		return *bytes.NewBufferString(m.pages), bytes.Buffer{}, nil
+	}
+	return bytes.Buffer{}, *bytes.NewBufferString("gh: Not Found (HTTP 404)"), errors.New("exit status 1")
+}
+
+func TestCheckGistsUser(t *testing.T) {
+	setup([]string{})
+	ghClient = &mockGistsGithub{login: "link-"}
+
+	assert.NoError(t, checkGistsUser("Link-"))
+	err := checkGistsUser("octocat")
+	assert.ErrorContains(t, err, "--gists only works for the authenticated user link-")
+}
+
+func TestGetStarredGists(t *testing.T) {
+	setup([]string{})
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+
+	// The pages are concatenated by gh api --paginate
+	github := &mockGistsGithub{pages: `[{"id":"aa11"}][{"id":"bb22"}]`}
+	ghClient = github
+	got, err := GetStarredGists([32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"id":"aa11"},{"id":"bb22"}]`, got.String())
+	assert.FileExists(t, filepath.Join(filepath.Dir(cacheFile), "stars.gists.json"))
+
+	// The cache is read on the next run
+	github = &mockGistsGithub{}
+	ghClient = github
+	got, err = GetStarredGists([32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"id":"aa11"},{"id":"bb22"}]`, got.String())
+	assert.Empty(t, github.calls)
+}
+
+func TestGetGistsPath(t *testing.T) {
+	setup([]string{})
+	key := [32]byte{0x2d, 0x06, 0xa8, 0x9b, 0x26, 0x87}
+	path, err := GetGistsPath(key)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "gists_2d06a89b2687.json"), path)
+
+	_, err = GetGistsPath([32]byte{})
+	assert.Error(t, err)
+}
+
+func TestSearchGists(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/gists.json")
+
+	tests := []struct {
+		name      string
+		queries   []string
+		wantGists []string
+		wantRank  int
+	}{
+		{name: "ListAll", queries: nil, wantGists: []string{"aa11", "bb22", "cc33"}},
+		{name: "Description", queries: []string{"exponential"}, wantGists: []string{"bb22"}, wantRank: DESCRIPTION_SCORE},
+		// The words of the filenames are matched without the extension
+		{name: "Filename", queries: []string{"cheatsheet"}, wantGists: []string{"cc33"}, wantRank: FILENAME_SCORE},
+		{name: "FilenameBeforeDescription", queries: []string{"backoff"}, wantGists: []string{"bb22"}, wantRank: FILENAME_SCORE},
+		{name: "DescriptionPrefix", queries: []string{"desc:backoff"}, wantGists: []string{"bb22"}, wantRank: DESCRIPTION_SCORE},
+		{name: "Excluded", queries: []string{"tmux -zsh"}, wantGists: []string{}},
+		{name: "SeveralQueries", queries: []string{"tmux", "kubernetes"}, wantGists: []string{"aa11", "cc33"}, wantRank: FILENAME_SCORE},
+		{name: "OtherField", queries: []string{"name:tmux"}, wantGists: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SearchGists(testData, tt.queries)
+			assert.NoError(t, err)
+			if len(tt.wantGists) > 0 {
+				assert.Equal(t, tt.wantRank, got[0].Priority)
+			}
+			var ids []string
+			for _, item := range topGists(got) {
+				ids = append(ids, item.Id)
+			}
+			assert.ElementsMatch(t, tt.wantGists, ids)
+		})
+	}
+
+	_, err := SearchGists(testData, []string{"zsh OR tmux"})
+	assert.ErrorContains(t, err, "boolean queries are not supported with --gists")
+}
+
+func TestRenderGists(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/gists.json")
+	found, err := SearchGists(testData, []string{"retry"})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, RenderGists(found, -1, &buf))
+	assert.Equal(t, "ID    Description                                     Files             URL                           Rank  Matched\nbb22  Retry an HTTP request with exponential backoff  retry_backoff.go  https://gist.github.com/bb22  500   files:retry\n", buf.String())
+}
+
+// topGists pops every gist of the results, the best ranked first
+func topGists(results pq.PriorityQueue) []GistResult {
+	var gists []GistResult
+	for results.Len() > 0 {
+		gists = append(gists, heap.Pop(&results).(*pq.Item).Value.(GistResult))
+	}
+	return gists
+}
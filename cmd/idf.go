@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Frequencies counts the descriptions containing each word among the starred repos, so
+// description matches can be weighed by how rare the word is: matching "tokenizer"
+// tells more about a repo than matching "library" or "simple".
+type Frequencies struct {
+	Words map[string]int // Number of descriptions containing each word, by stem
+	Total int            // Number of repos
+}
+
+// NewFrequencies counts the descriptions of the repos containing each word
+func NewFrequencies(repos []Repo) Frequencies {
+	frequencies := Frequencies{Words: make(map[string]int), Total: len(repos)}
+	for _, repo := range repos {
+		seen := make(map[string]bool)
+		for _, word := range strings.Fields(repo.Description) {
+			key := frequencyKey(word)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			frequencies.Words[key]++
+		}
+	}
+	return frequencies
+}
+
+// Weight returns the inverse document frequency of the word relative to a word found in
+// a single description, between 0 and 1. A word found in a single description keeps the
+// full score of the field, and the more descriptions contain the word the less it scores.
+// Without frequencies every word has a weight of 1.
+func (f Frequencies) Weight(word string) float64 {
+	if f.Total == 0 {
+		return 1
+	}
+	rarest := f.idf(1)
+	return math.Min(1, f.idf(f.Words[frequencyKey(word)])/rarest)
+}
+
+// idf is the smoothed inverse document frequency of a word found in count descriptions
+func (f Frequencies) idf(count int) float64 {
+	return math.Log(float64(1+f.Total)/float64(1+count)) + 1
+}
+
+// frequencyKey returns the stem of the word without the surrounding punctuation, so
+// "Parsers," and "parser" are counted as the same word
+func frequencyKey(word string) string {
+	word = strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return stem(normalize(word))
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"container/heap"
+	"testing"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrequencies(t *testing.T) {
+	repos := loadRepos(t, "testdata/idf_repos.json")
+	frequencies := NewFrequencies(repos)
+
+	assert.Equal(t, 8, frequencies.Total)
+	// Case, punctuation and plurals are ignored
+	assert.Equal(t, 6, frequencies.Words["simple"])
+	assert.Equal(t, 4, frequencies.Words["library"])
+	assert.Equal(t, 1, frequencies.Words["tokenizer"])
+	assert.Equal(t, 2, frequencies.Words["config"])
+
+	assert.Equal(t, 1.0, frequencies.Weight("tokenizer"))
+	assert.Equal(t, 1.0, frequencies.Weight("unknown"))
+	assert.Less(t, frequencies.Weight("simple"), frequencies.Weight("library"))
+	assert.Less(t, frequencies.Weight("library"), frequencies.Weight("config"))
+	assert.Less(t, frequencies.Weight("config"), frequencies.Weight("tokenizer"))
+	assert.Equal(t, frequencies.Weight("simple"), frequencies.Weight("Simple,"))
+
+	// Without frequencies, every word keeps the full score
+	assert.Equal(t, 1.0, Frequencies{}.Weight("simple"))
+}
+
+func TestSearchIDF(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/idf_repos.json")
+
+	// Both repos match a single word of the description, the rare word comes first
+	// although the repo matching the common word has more stars
+	found, err := Search(testData, "simple tokenizer")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, found.Len())
+	first := heap.Pop(&found).(*pq.Item)
+	assert.Equal(t, "someone/lexer", first.Value.(Result).Full_name)
+	assert.Equal(t, DESCRIPTION_SCORE, first.Priority)
+	second := heap.Pop(&found).(*pq.Item)
+	assert.Equal(t, "someone/helper", second.Value.(Result).Full_name)
+	// simple is in 6 of the 8 descriptions
+	assert.Equal(t, DESCRIPTION_SCORE/2, second.Priority)
+
+	// Matching the rarer word ranks higher than matching the more common one
+	found, err = Search(testData, "config library")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"someone/cfg", "someone/lexer", "someone/helper", "someone/logs", "someone/fmt"}, uniqueRepos(found))
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultIgnorePath returns the path of the ignore file read without --ignore-file:
+// ~/.config/gh-stars/ignore
+func defaultIgnorePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gh-stars", "ignore"), nil
+}
+
+// loadIgnorePatterns returns the patterns of the ignore file, see --ignore-file. The file has
+// one owner/name or glob pattern per line, empty lines and lines starting with # are
+// ignored. Malformed patterns are skipped with a warning. Without a path the default ignore
+// file is read, and it's fine if it doesn't exist.
+func loadIgnorePatterns(filePath string) ([]string, error) {
+	if filePath == "" {
+		var err error
+		if filePath, err = defaultIgnorePath(); err != nil {
+			return nil, nil
+		}
+		if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		pattern := strings.ToLower(text)
+		if _, err := path.Match(pattern, ""); err != nil {
+			WarnLogger.Printf("%s:%d: skipping the malformed pattern %q", filePath, line, text)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	InfoLogger.Printf("Ignoring the repos matching the %d patterns of %s", len(patterns), filePath)
+	return patterns, nil
+}
+
+// notIgnored returns the filter dropping the repos matching one of the patterns, ignoring
+// the case. A pattern with a slash is matched against the full name, e.g. someone/* drops
+// every repo of someone, a pattern without one against the name.
+func notIgnored(patterns []string) Filter {
+	return func(repo Repo) bool {
+		_, matched := matchingPattern(patterns, repo)
+		return !matched
+	}
+}
+
+// notExcluded returns the filter dropping the repos matching one of the patterns of
+// --exclude, matched like the patterns of the ignore file. Every repo dropped is logged
+// once along with the pattern it matched.
+func notExcluded(patterns []string) Filter {
+	lowered := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowered[i] = strings.ToLower(strings.TrimSpace(pattern))
+	}
+	logged := map[string]bool{}
+	return func(repo Repo) bool {
+		pattern, matched := matchingPattern(lowered, repo)
+		if matched && !logged[repo.Full_name] {
+			logged[repo.Full_name] = true
+			InfoLogger.Printf("%s was left out by --exclude %s", repo.Full_name, pattern)
+		}
+		return !matched
+	}
+}
+
+// matchingPattern returns the first of the lowercase patterns matching the repo, see
+// notIgnored
+func matchingPattern(patterns []string, repo Repo) (string, bool) {
+	for _, pattern := range patterns {
+		name := repo.Name
+		if strings.Contains(pattern, "/") {
+			name = repo.Full_name
+		}
+		if matched, _ := path.Match(pattern, strings.ToLower(name)); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadIgnorePatterns(t *testing.T) {
+	setup([]string{})
+	path := filepath.Join(t.TempDir(), "ignore")
+	content := "# Old experiments\nianyh/Amethyst\n\nKATIEM0/*\nbroken[\n*gpt\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	// The malformed pattern is skipped, the others are lowercased
+	patterns, err := loadIgnorePatterns(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ianyh/amethyst", "katiem0/*", "*gpt"}, patterns)
+
+	_, err = loadIgnorePatterns(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	// The default ignore file is optional
+	setHomeDir(t, t.TempDir())
+	patterns, err = loadIgnorePatterns("")
+	assert.NoError(t, err)
+	assert.Empty(t, patterns)
+}
+
+func TestNotIgnored(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	found, err := ListAll(testData)
+	assert.NoError(t, err)
+
+	// Full names, owners and names are matched ignoring the case
+	kept, dropped := ApplyFilters(found, []Filter{notIgnored([]string{"ianyh/amethyst", "katiem0/*", "*gpt"})})
+	assert.Equal(t, 3, dropped)
+	assert.Equal(t, []string{"open-policy-agent/gatekeeper", "lithammer/fuzzysearch"}, uniqueRepos(kept))
+}
+
+func TestNotExcluded(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	defer func() { excludes = nil }()
+
+	tests := []struct {
+		name      string
+		excludes  []string
+		wantRepos []string
+	}{
+		{name: "FullName", excludes: []string{"karpathy/nanoGPT"}, wantRepos: []string{"ianyh/Amethyst", "open-policy-agent/gatekeeper", "lithammer/fuzzysearch", "katiem0/gh-export-secrets"}},
+		{name: "OwnerGlob", excludes: []string{"KATIEM0/*", " ianyh/*"}, wantRepos: []string{"karpathy/nanoGPT", "open-policy-agent/gatekeeper", "lithammer/fuzzysearch"}},
+		{name: "NameGlob", excludes: []string{"*gpt", "fuzzy*"}, wantRepos: []string{"ianyh/Amethyst", "open-policy-agent/gatekeeper", "katiem0/gh-export-secrets"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			excludes = tt.excludes
+			assert.NoError(t, validateSearchOptions())
+			found, err := ListAll(testData)
+			assert.NoError(t, err)
+			kept, dropped := ApplyFilters(found, activeFilters())
+			assert.Equal(t, 5-len(tt.wantRepos), dropped)
+			assert.Equal(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+
+	excludes = []string{"google/[a-"}
+	assert.ErrorContains(t, validateSearchOptions(), `--exclude "google/[a-" is not a valid pattern`)
+}
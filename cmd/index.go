@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// An Index maps the words of the starred repos to the repos containing them. It is saved
+// next to the cache, so repeated exact and prefix searches only score the repos
+// containing the search terms instead of splitting every repo into words.
+type Index struct {
+	Version     int              // Format of the index, an index with another version is rebuilt
+	Checksum    [32]byte         // Checksum of the cache the index was built from
+	Words       map[string][]int // Normalized words and their stems, with the position of the repos containing them
+	Frequencies Frequencies      // Number of descriptions containing each word, see Frequencies
+	sorted      []string         // Words in alphabetical order, for prefix matching
+}
+
+const INDEX_VERSION = 3 // Version of the Index format, bumped when fields are added
+
+// BuildIndex indexes every word searched by scoreNeedle in the repos
+func BuildIndex(repos []Repo, checksum [32]byte) *Index {
+	index := &Index{
+		Version:     INDEX_VERSION,
+		Checksum:    checksum,
+		Words:       make(map[string][]int),
+		Frequencies: NewFrequencies(repos),
+	}
+	for i, repo := range repos {
+		doc := newDocument(repo)
+		words := append([]string{doc.Owner.Login, doc.Full_name}, doc.nameWords...)
+		for _, word := range doc.descriptionWords {
+			words = append(words, word.Text)
+		}
+		words = append(words, doc.Topics...)
+		words = append(words, doc.homepageWords...)
+		for _, word := range words {
+			normalized := normalize(word)
+			index.add(normalized, i)
+			index.add(stem(normalized), i)
+		}
+	}
+	index.sort()
+	return index
+}
+
+// add records that the repo at position i contains the word. Repos are indexed in
+// order, so the positions of a word stay sorted and only the last one can be a duplicate.
+func (idx *Index) add(word string, i int) {
+	if word == "" {
+		return
+	}
+	positions := idx.Words[word]
+	if len(positions) > 0 && positions[len(positions)-1] == i {
+		return
+	}
+	idx.Words[word] = append(positions, i)
+}
+
+func (idx *Index) sort() {
+	idx.sorted = make([]string, 0, len(idx.Words))
+	for word := range idx.Words {
+		idx.sorted = append(idx.sorted, word)
+	}
+	sort.Strings(idx.sorted)
+}
+
+// LoadIndex returns the index saved at path if it was built from the cache. Otherwise
+// the index is built from the repos and saved, the index of a cache that was
+// rewritten is never used.
+func LoadIndex(path string, cache []byte, repos []Repo) (*Index, error) {
+	checksum := sha256.Sum256(cache)
+	if data, err := os.ReadFile(path); err == nil {
+		var index Index
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&index); err == nil && index.Version == INDEX_VERSION && index.Checksum == checksum {
+			InfoLogger.Println("Reading the search index:", path)
+			index.sort()
+			return &index, nil
+		}
+	}
+
+	InfoLogger.Println("Building the search index:", path)
+	index := BuildIndex(repos, checksum)
+	if skipCacheWrite(path) {
+		return index, nil
+	}
+	// Renamed over the previous index like the cache files, see writeCacheFile
+	var encoded bytes.Buffer
+	if err := gob.NewEncoder(&encoded).Encode(index); err != nil {
+		return nil, err
+	}
+	if err := writeCacheFile(path, encoded.Bytes()); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Candidates returns the positions of the repos containing one of the needles, one of
+// their aliases or one of the words of a phrase, in increasing order. With --prefix, the
+// repos containing a word starting with a needle are returned, otherwise the repos
+// containing the needle or its stem.
+func (idx *Index) Candidates(needles []string) []int {
+	found := make(map[int]bool)
+	for _, needle := range needles {
+		needle = normalize(unescapeWildcards(needle))
+		values := append([]string{needle}, aliases[needle]...)
+		// A phrase is found in the repos containing its words
+		if isPhrase(needle) {
+			values = append(values, strings.Fields(needle)...)
+		}
+		for _, value := range values {
+			if prefixMatch {
+				for i := sort.SearchStrings(idx.sorted, value); i < len(idx.sorted) && strings.HasPrefix(idx.sorted[i], value); i++ {
+					for _, position := range idx.Words[idx.sorted[i]] {
+						found[position] = true
+					}
+				}
+				continue
+			}
+			for _, word := range []string{value, stem(value)} {
+				for _, position := range idx.Words[word] {
+					found[position] = true
+				}
+			}
+		}
+	}
+
+	candidates := make([]int, 0, len(found))
+	for position := range found {
+		candidates = append(candidates, position)
+	}
+	sort.Ints(candidates)
+	return candidates
+}
+
+// indexable reports whether the words matching a needle can be looked up in the index,
+// which is only the case for exact and prefix matching
+func indexable() bool {
+	if prefixMatch {
+		return true
+	}
+	if absoluteDist {
+		return fuzzyDistance == 0
+	}
+	if adaptiveDist {
+		return false
+	}
+	return fuzzyRatio == 0
+}
+
+// GetIndexPath returns the path of the search index of the cache, next to the cache file.
+//
+// Example: ~/.cache/gh-stars/stars_link-_2d06a89b2687.index for ~/.cache/gh-stars/stars_link-_2d06a89b2687.json.gz
+func GetIndexPath(cacheKey [32]byte) (string, error) {
+	path, err := GetCachePath(cacheKey)
+	if err != nil {
+		return "", fmt.Errorf("not able to locate the cache: %w", err)
+	}
+	return trimCacheExt(path) + ".index", nil
+}
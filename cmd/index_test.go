@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildIndex(t *testing.T) {
+	repos := []Repo{
+		{Name: "go-parsers", Full_name: "someone/go-parsers", Description: "Parsers and parsers", Topics: []string{"Résumé"}},
+		{Name: "lexer", Full_name: "other/lexer", Description: "A parser"},
+	}
+	repos[0].Owner.Login = "someone"
+	repos[1].Owner.Login = "other"
+
+	index := BuildIndex(repos, [32]byte{})
+	assert.Equal(t, []int{0}, index.Words["go"])
+	assert.Equal(t, []int{0}, index.Words["someone"])
+	assert.Equal(t, []int{0}, index.Words["someone/go-parsers"])
+	// Every repo appears once, even when it contains the word several times
+	assert.Equal(t, []int{0}, index.Words["parsers"])
+	// Words are normalized and their stems are indexed
+	assert.Equal(t, []int{0, 1}, index.Words["parser"])
+	assert.Equal(t, []int{0}, index.Words["resume"])
+	assert.Nil(t, index.Words["Parsers"])
+}
+
+func TestIndexCandidates(t *testing.T) {
+	setup([]string{})
+	repos := loadRepos(t, "testdata/5_repos.json")
+	index := BuildIndex(repos, [32]byte{})
+	aliases, _ = loadAliases("")
+	defer func() { prefixMatch = false }()
+
+	tests := []struct {
+		name    string
+		needles []string
+		prefix  bool
+		want    []int
+	}{
+		{name: "Exact", needles: []string{"kubernetes"}, want: []int{2}},
+		{name: "ExactIgnoresCase", needles: []string{"MACOS"}, want: []int{0}},
+		{name: "ExactStem", needles: []string{"secret"}, want: []int{1}},
+		{name: "SeveralNeedles", needles: []string{"go", "macos"}, want: []int{0, 1, 4}},
+		{name: "Alias", needles: []string{"k8s"}, want: []int{2}},
+		{name: "NoMatch", needles: []string{"kube"}, want: []int{}},
+		{name: "PhraseWords", needles: []string{"kubernetes policy"}, want: []int{2}},
+		{name: "Prefix", needles: []string{"kube"}, prefix: true, want: []int{2}},
+		{name: "PrefixOfSeveralWords", needles: []string{"ma"}, prefix: true, want: []int{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixMatch = tt.prefix
+			assert.Equal(t, tt.want, index.Candidates(tt.needles))
+		})
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	setup([]string{})
+	var repos []Repo
+	testData := loadTestData(t, "testdata/5_repos.json")
+	assert.NoError(t, json.Unmarshal(testData.Bytes(), &repos))
+	path := filepath.Join(t.TempDir(), "stars.index")
+
+	// The index is built and saved on first use
+	index, err := LoadIndex(path, testData.Bytes(), repos)
+	assert.NoError(t, err)
+	assert.Equal(t, sha256.Sum256(testData.Bytes()), index.Checksum)
+	assert.FileExists(t, path)
+
+	// Then read back as long as the cache is the same
+	loaded, err := LoadIndex(path, testData.Bytes(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, index.Words, loaded.Words)
+
+	// A rewritten cache invalidates the index
+	cache := []byte(`[{"name": "other"}]`)
+	rebuilt, err := LoadIndex(path, cache, []Repo{{Name: "other"}})
+	assert.NoError(t, err)
+	assert.Equal(t, sha256.Sum256(cache), rebuilt.Checksum)
+	assert.Equal(t, map[string][]int{"other": {0}}, rebuilt.Words)
+
+	// So does a corrupted index
+	assert.NoError(t, os.WriteFile(path, []byte("corrupted"), 0644))
+	rebuilt, err = LoadIndex(path, testData.Bytes(), repos)
+	assert.NoError(t, err)
+	assert.Equal(t, index.Words, rebuilt.Words)
+}
+
+func TestGetIndexPath(t *testing.T) {
+	setup([]string{})
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+	path, err := GetIndexPath([32]byte{1})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(cacheFile), "stars.index"), path)
+
+	cacheFile = filepath.Join(t.TempDir(), ".starscache")
+	path, err = GetIndexPath([32]byte{1})
+	assert.NoError(t, err)
+	assert.Equal(t, cacheFile+".index", path)
+}
+
+func TestSearchWithIndex(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/all_repos.json")
+	defer func() {
+		indexPath = ""
+		absoluteDist = false
+		fuzzyDistance = DEFAULT_FUZZY_DISTANCE
+		prefixMatch = false
+	}()
+
+	tests := []struct {
+		name   string
+		find   string
+		prefix bool
+	}{
+		{name: "Exact", find: "kubernetes"},
+		{name: "ExactSeveralTerms", find: "go cli parsers"},
+		{name: "ExactWithExclusion", find: "terraform -aws"},
+		{name: "ExactFieldPrefix", find: "topic:golang name:cli"},
+		{name: "Prefix", find: "kube", prefix: true},
+		{name: "PrefixSeveralTerms", find: "gh- act", prefix: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixMatch = tt.prefix
+			absoluteDist = !tt.prefix
+			fuzzyDistance = 0
+
+			indexPath = ""
+			want, err := SearchAll(testData, []string{tt.find})
+			assert.NoError(t, err)
+			assert.NotZero(t, want.Len())
+
+			indexPath = filepath.Join(t.TempDir(), "stars.index")
+			for run := 0; run < 2; run++ {
+				got, err := SearchAll(testData, []string{tt.find})
+				assert.NoError(t, err)
+				assert.Equal(t, rankedRepos(want), rankedRepos(got))
+			}
+		})
+	}
+}
+
+// rankedRepos returns the full names and ranks of the results, in order
+func rankedRepos(results pq.PriorityQueue) []string {
+	results = append(pq.PriorityQueue{}, results...)
+	var ranked []string
+	for results.Len() > 0 {
+		item := heap.Pop(&results).(*pq.Item)
+		ranked = append(ranked, fmt.Sprintf("%s %d", item.Value.(Result).Full_name, item.Priority))
+	}
+	return ranked
+}
+
+// generateRepos returns n repos with random names, descriptions and topics
+func generateRepos(n int) []Repo {
+	words := []string{
+		"fast", "simple", "cli", "library", "framework", "kubernetes", "operator", "terraform",
+		"provider", "parser", "static", "site", "generator", "database", "driver", "http",
+		"server", "client", "markdown", "editor", "window", "manager", "machine", "learning",
+		"neural", "network", "game", "engine", "terminal", "emulator", "shell", "prompt",
+	}
+	random := rand.New(rand.NewSource(1))
+	repos := make([]Repo, n)
+	for i := range repos {
+		description := make([]string, 8+random.Intn(12))
+		for j := range description {
+			description[j] = words[random.Intn(len(words))]
+		}
+		repos[i] = Repo{
+			Name:        fmt.Sprintf("%s-%s-%d", words[random.Intn(len(words))], words[random.Intn(len(words))], i),
+			Full_name:   fmt.Sprintf("owner%d/repo%d", i%500, i),
+			Description: fmt.Sprint(description),
+			Stars:       random.Intn(10000),
+			Topics:      []string{words[random.Intn(len(words))], words[random.Intn(len(words))]},
+		}
+		repos[i].Owner.Login = fmt.Sprintf("owner%d", i%500)
+	}
+	return repos
+}
+
+func BenchmarkSearchRepos(b *testing.B) {
+	setup([]string{})
+	repos := generateRepos(10000)
+	cache, err := json.Marshal(repos)
+	if err != nil {
+		b.Fatal(err)
+	}
+	absoluteDist = true
+	fuzzyDistance = 0
+	defer func() {
+		absoluteDist = false
+		fuzzyDistance = DEFAULT_FUZZY_DISTANCE
+	}()
+	index, err := LoadIndex(filepath.Join(b.TempDir(), "stars.index"), cache, repos)
+	if err != nil {
+		b.Fatal(err)
+	}
+	query, err := NewQuery("emulator")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("FullScan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := newCorpus(repos).search(query, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := newCorpus(repos).search(query, index); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkLoadIndex(b *testing.B) {
+	setup([]string{})
+	repos := generateRepos(10000)
+	var cache bytes.Buffer
+	if err := json.NewEncoder(&cache).Encode(repos); err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "stars.index")
+	if _, err := LoadIndex(path, cache.Bytes(), repos); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadIndex(path, cache.Bytes(), repos); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ReadInputFile returns the repos of the file provided with --input instead of the starred
+// repos: either the list of repos the API returns, with or without their star date, see
+// unwrapStars, or a cache file of gh stars, compressed or not, whoever the repos belong to.
+// The file is validated the way the repos are searched, an error tells where it went wrong,
+// and the repos of a cache file are checked against its checksum, see verifyChecksum.
+// Nothing is fetched nor cached.
+func ReadInputFile(path string) (bytes.Buffer, error) {
+	data, err := readCacheFile(path)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return bytes.Buffer{}, errors.New("the file is empty, it should hold a JSON array of repos or a cache file of gh stars")
+	}
+
+	// Decoding the whole file tells where it went wrong
+	var shape any = &[]Repo{}
+	switch data[0] {
+	case '{':
+		shape = &struct {
+			Repos []Repo `json:"repos"`
+		}{}
+	case '[':
+	default:
+		return bytes.Buffer{}, fmt.Errorf("the file should hold a JSON array of repos or a cache file of gh stars, it starts with %q", data[:1])
+	}
+	if err := json.Unmarshal(data, shape); err != nil {
+		return bytes.Buffer{}, inputError(data, err)
+	}
+
+	repos := data
+	if data[0] == '{' {
+		var cache cacheEnvelope
+		if err := json.Unmarshal(data, &cache); err != nil {
+			return bytes.Buffer{}, err
+		}
+		if len(cache.Repos) == 0 || string(cache.Repos) == "null" {
+			return bytes.Buffer{}, errors.New("the object has no repos, it should be a cache file of gh stars")
+		}
+		if err := cache.verifyChecksum(); err != nil {
+			return bytes.Buffer{}, err
+		}
+		repos = cache.Repos
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(repos, &items); err != nil {
+		return bytes.Buffer{}, err
+	}
+	if len(items) > 0 && items[0]["repo"] != nil {
+		var err error
+		if repos, err = unwrapStars(repos); err != nil {
+			return bytes.Buffer{}, fmt.Errorf("not able to read the star dates: %w", err)
+		}
+	}
+	decoded, err := DecodeRepos(*bytes.NewBuffer(repos))
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	for i, repo := range decoded {
+		if repo.Full_name == "" {
+			return bytes.Buffer{}, fmt.Errorf("the repo at index %d has no full_name, it should be a repo of the GitHub API", i)
+		}
+	}
+	return *bytes.NewBuffer(repos), nil
+}
+
+// inputError returns the error decoding the input file with the line and column it happened
+// at, when the JSON decoder tells
+func inputError(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Errorf("invalid JSON at line %d, column %d: %w", line, column, err)
+}
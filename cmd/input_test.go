@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadInputFile(t *testing.T) {
+	setup([]string{})
+	dir := t.TempDir()
+	compressed := filepath.Join(dir, "stars.json.gz")
+	data, err := os.ReadFile("testdata/cache_v2.json")
+	assert.NoError(t, err)
+	assert.NoError(t, writeCacheFile(compressed, data))
+	wrapped := filepath.Join(dir, "wrapped.json")
+	assert.NoError(t, os.WriteFile(wrapped, []byte(`[{"starred_at": "2024-01-01T10:00:00Z", "repo": {"name": "cobra", "full_name": "spf13/cobra"}}]`), 0644))
+
+	tests := []struct {
+		name      string
+		path      string
+		wantRepos []string
+	}{
+		{name: "RepositoriesOfTheAPI", path: "testdata/language_repos.json", wantRepos: []string{"spf13/cobra", "clap-rs/clap", "oclif/oclif", "agarrharr/awesome-cli-apps"}},
+		{name: "CacheFile", path: "testdata/cache_v2.json", wantRepos: []string{"spf13/cobra", "clap-rs/clap"}},
+		{name: "CompressedCacheFile", path: compressed, wantRepos: []string{"spf13/cobra", "clap-rs/clap"}},
+		{name: "StarDates", path: wrapped, wantRepos: []string{"spf13/cobra"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starred, err := ReadInputFile(tt.path)
+			assert.NoError(t, err)
+			repos, err := DecodeRepos(starred)
+			assert.NoError(t, err)
+			var names []string
+			for _, repo := range repos {
+				names = append(names, repo.Full_name)
+			}
+			assert.Equal(t, tt.wantRepos, names)
+		})
+	}
+
+	// The star dates are kept along with the repos
+	starred, err := ReadInputFile(wrapped)
+	assert.NoError(t, err)
+	repos, err := DecodeRepos(starred)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-01T10:00:00Z", repos[0].Starred_at)
+}
+
+func TestReadInputFileErrors(t *testing.T) {
+	setup([]string{})
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "Empty", content: " \n", wantErr: "the file is empty, it should hold a JSON array of repos or a cache file of gh stars"},
+		{name: "NotJSON", content: "name,full_name\n", wantErr: `the file should hold a JSON array of repos or a cache file of gh stars, it starts with "n"`},
+		{name: "InvalidJSON", content: "[\n  {\"full_name\": \"spf13/cobra\",}\n]", wantErr: "invalid JSON at line 2, column 32: invalid character '}'"},
+		{name: "WrongType", content: "[\n  {\"full_name\": \"spf13/cobra\", \"stargazers_count\": \"many\"}\n]", wantErr: "invalid JSON at line 2, column 58: json: cannot unmarshal string into Go struct field"},
+		{name: "WrongTypeInTheCacheFile", content: "{\n  \"version\": 3,\n  \"repos\": {}\n}", wantErr: "invalid JSON at line 3, column 13: json: cannot unmarshal object"},
+		{name: "NoRepos", content: `{"version": 3}`, wantErr: "the object has no repos, it should be a cache file of gh stars"},
+		{name: "ChecksumMismatch", content: `{"version": 4, "count": 1, "checksum": "0000", "repos": [{"full_name": "spf13/cobra"}]}`, wantErr: "the cache file is corrupted: the checksum of the repos doesn't match"},
+		{name: "NoFullName", content: `[{"full_name": "spf13/cobra"}, {"name": "clap"}]`, wantErr: "the repo at index 1 has no full_name, it should be a repo of the GitHub API"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+			_, err := ReadInputFile(path)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := ReadInputFile(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestInputSearch(t *testing.T) {
+	setup([]string{})
+	t.Setenv(CACHE_DIR_ENV, t.TempDir())
+	// Nothing is fetched with --input
+	client = NewTestClient(func(req *http.Request) *http.Response {
+		t.Errorf("unexpected request: %s", req.URL)
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}
+	})
+	inputFile = "testdata/fork_repos.json"
+	defer func() { inputFile = "" }()
+
+	starred, err := ReadInputFile(inputFile)
+	assert.NoError(t, err)
+	found, err := Search(starred, "linter")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, found.Len())
+
+	// The forks are listed on their own
+	assert.Equal(t, 3, groupForks(found, [32]byte{}).Len())
+}
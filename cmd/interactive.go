@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/cli/go-gh/pkg/text"
+	"golang.org/x/term"
+)
+
+const PICKER_MAX_RESULTS = 500 // Results kept while filtering, more never fit on a screen
+
+// A key is a rune typed in the picker, or one of the special keys below
+type key rune
+
+const (
+	keyUp key = -(iota + 1)
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyEnter
+	keyBackspace
+	keyClear
+	keyOpen
+	keyQuit
+)
+
+// A pickerAction is what the user chose to do with the selected repo
+type pickerAction int
+
+const (
+	pickerNone  pickerAction = iota // Still picking
+	pickerQuit                      // Left without selecting a repo
+	pickerPrint                     // Print the URL of the selected repo
+	pickerOpen                      // Open the selected repo in the browser
+)
+
+// A picker is the state of the --interactive mode: the query typed so far and the repos
+// matching it, ranked like the results of a search
+type picker struct {
+	repos   []Repo
+	corpus  *corpus // The repos prepared once for the queries typed, see corpus
+	query   []rune
+	results []Result
+	total   int   // Number of repos matching the query, only the best PICKER_MAX_RESULTS are kept
+	err     error // Error of the last query, the results of the previous one are kept
+	cursor  int   // Position of the selected result
+	offset  int   // Position of the first result on screen
+	page    int   // Number of results on screen
+}
+
+func newPicker(repos []Repo, query string) *picker {
+	p := &picker{repos: repos, corpus: newCorpus(repos), query: []rune(query), page: 1}
+	p.filter()
+	return p
+}
+
+// filter ranks the repos matching the query, every repo is listed when it's empty
+func (p *picker) filter() {
+	found := listRepos(p.repos)
+	if text := strings.TrimSpace(string(p.query)); text != "" {
+		// The query is incomplete while it's being typed, e.g. an unterminated quote
+		query, err := NewQuery(text)
+		if err == nil {
+			found, err = p.corpus.search(query, nil)
+		}
+		if err != nil {
+			p.err = err
+			return
+		}
+		if boostRecent {
+			found = BoostRecent(found, time.Now())
+		}
+	}
+	p.err = nil
+	p.total = found.Len()
+	p.results = nil
+	for found.Len() > 0 && len(p.results) < PICKER_MAX_RESULTS {
+		p.results = append(p.results, heap.Pop(&found).(*pq.Item).Value.(Result))
+	}
+	p.cursor, p.offset = 0, 0
+}
+
+// selected returns the selected repo, false when no repo matches the query
+func (p *picker) selected() (Result, bool) {
+	if len(p.results) == 0 {
+		return Result{}, false
+	}
+	return p.results[p.cursor], true
+}
+
+// handle updates the picker with the key and returns what to do with the selected repo
+func (p *picker) handle(k key) pickerAction {
+	switch k {
+	case keyUp:
+		p.move(-1)
+	case keyDown:
+		p.move(1)
+	case keyPageUp:
+		p.move(-p.page)
+	case keyPageDown:
+		p.move(p.page)
+	case keyEnter, keyOpen:
+		if _, ok := p.selected(); !ok {
+			return pickerNone
+		}
+		if k == keyOpen {
+			return pickerOpen
+		}
+		return pickerPrint
+	case keyQuit:
+		return pickerQuit
+	case keyBackspace:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.filter()
+		}
+	case keyClear:
+		p.query = nil
+		p.filter()
+	default:
+		p.query = append(p.query, rune(k))
+		p.filter()
+	}
+	return pickerNone
+}
+
+// move moves the cursor by delta results, within the results
+func (p *picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.results) {
+		p.cursor = len(p.results) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// view returns the lines of the screen: the query, the number of matching repos or the
+// error of the query, then as many results as fit with the selected one highlighted
+func (p *picker) view(width int, height int) []string {
+	lines := []string{text.Truncate(width, "> "+string(p.query))}
+	var queryErr *QueryError
+	switch {
+	case errors.As(p.err, &queryErr):
+		lines = append(lines, text.Truncate(width, "  "+queryErr.Message))
+	case p.err != nil:
+		lines = append(lines, text.Truncate(width, "  "+p.err.Error()))
+	default:
+		lines = append(lines, fmt.Sprintf("  %d/%d", p.total, len(p.repos)))
+	}
+
+	p.page = height - len(lines)
+	if p.page < 1 {
+		p.page = 1
+	}
+	// Scroll just enough to keep the selected result on screen
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.page {
+		p.offset = p.cursor - p.page + 1
+	}
+	for i := p.offset; i < len(p.results) && i < p.offset+p.page; i++ {
+		result := p.results[i]
+		line := fmt.Sprintf("%s  ★ %d  %s", result.Full_name, result.Stars, result.Description)
+		if i == p.cursor {
+			lines = append(lines, "\x1b[7m"+text.Truncate(width, "> "+line)+"\x1b[0m")
+			continue
+		}
+		lines = append(lines, text.Truncate(width, "  "+line))
+	}
+	return lines
+}
+
+// parseKeys returns the keys pressed in the input read from a terminal in raw mode
+func parseKeys(input []byte) []key {
+	var keys []key
+	for len(input) > 0 {
+		// Escape sequences of the arrow and page keys
+		if input[0] == 0x1b {
+			if len(input) == 1 {
+				keys = append(keys, keyQuit)
+				break
+			}
+			if input[1] != '[' && input[1] != 'O' {
+				keys = append(keys, keyQuit)
+				input = input[1:]
+				continue
+			}
+			end := 2
+			for end < len(input) && !unicode.IsLetter(rune(input[end])) && input[end] != '~' {
+				end++
+			}
+			if end == len(input) {
+				break
+			}
+			switch string(input[2 : end+1]) {
+			case "A":
+				keys = append(keys, keyUp)
+			case "B":
+				keys = append(keys, keyDown)
+			case "5~":
+				keys = append(keys, keyPageUp)
+			case "6~":
+				keys = append(keys, keyPageDown)
+			}
+			input = input[end+1:]
+			continue
+		}
+
+		r, size := utf8.DecodeRune(input)
+		input = input[size:]
+		switch r {
+		case 3: // Ctrl-C
+			keys = append(keys, keyQuit)
+		case '\r', '\n':
+			keys = append(keys, keyEnter)
+		case 127, 8: // Backspace
+			keys = append(keys, keyBackspace)
+		case 21: // Ctrl-U
+			keys = append(keys, keyClear)
+		case 16: // Ctrl-P
+			keys = append(keys, keyUp)
+		case 14: // Ctrl-N
+			keys = append(keys, keyDown)
+		case 15: // Ctrl-O
+			keys = append(keys, keyOpen)
+		default:
+			if r != utf8.RuneError && unicode.IsPrint(r) {
+				keys = append(keys, key(r))
+			}
+		}
+	}
+	return keys
+}
+
+// runPicker draws the picker on out and handles the keys read from in until a repo is
+// selected or the user quits. The size of the screen is read before every draw.
+func runPicker(p *picker, in io.Reader, out io.Writer, size func() (int, int)) (pickerAction, error) {
+	buf := make([]byte, 256)
+	for {
+		width, height := size()
+		lines := p.view(width, height)
+		// Clear the screen, draw the lines and put the cursor at the end of the query
+		fmt.Fprintf(out, "\x1b[H\x1b[2J%s\x1b[1;%dH", strings.Join(lines, "\r\n"), text.DisplayWidth("> "+string(p.query))+1)
+
+		n, err := in.Read(buf)
+		if n > 0 {
+			for _, k := range parseKeys(buf[:n]) {
+				if action := p.handle(k); action != pickerNone {
+					return action, nil
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return pickerQuit, nil
+		}
+		if err != nil {
+			return pickerQuit, err
+		}
+	}
+}
+
+// checkInteractive returns an error when the picker can't be shown, the terminal is
+// needed both to draw it and to read the keys
+func checkInteractive() error {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("--interactive needs a terminal but stdout is not a TTY, use --find instead, with --json for scripts")
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("--interactive needs a terminal but stdin is not a TTY, use --find instead")
+	}
+	return nil
+}
+
+// Interactive opens a full-screen picker of the starred repos, filtered with the search
+// as the query is typed, starting with the query provided. The URL of the repo picked
+// with Enter is printed to stdout, Ctrl-O opens it in the browser instead. Nothing is
+// printed when the user leaves with Esc or Ctrl-C.
+func Interactive(all []Repo, query string) error {
+	if err := checkInteractive(); err != nil {
+		return err
+	}
+	// Only the repos kept by the filters are picked from
+	filters := activeFilters()
+	var repos []Repo
+	for _, repo := range all {
+		if keep(repo, filters) {
+			repos = append(repos, repo)
+		}
+	}
+
+	// The notices would be drawn over the picker
+	warnWriter := WarnLogger.Writer()
+	WarnLogger.SetOutput(io.Discard)
+	p := newPicker(repos, query)
+
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		WarnLogger.SetOutput(warnWriter)
+		return fmt.Errorf("not able to set up the terminal: %w", err)
+	}
+	// Draw on the alternate screen, so the terminal is left as it was
+	fmt.Fprint(os.Stdout, "\x1b[?1049h")
+	action, err := runPicker(p, os.Stdin, os.Stdout, func() (int, int) {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			return tableMaxWidth, 24
+		}
+		return width, height
+	})
+	fmt.Fprint(os.Stdout, "\x1b[?1049l")
+	term.Restore(fd, state)
+	WarnLogger.SetOutput(warnWriter)
+	if err != nil {
+		return err
+	}
+
+	result, _ := p.selected()
+	switch action {
+	case pickerPrint:
+		fmt.Fprintln(os.Stdout, result.Url)
+	case pickerOpen:
+		InfoLogger.Println("Opening in the browser:", result.Full_name)
+		if _, stdErr, err := ghClient.Exec("browse", "--repo", result.Full_name); err != nil {
+			return fmt.Errorf("not able to open %s: %w %s", result.Full_name, err, stdErr.String())
+		}
+	}
+	return nil
+}
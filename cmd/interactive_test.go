@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadPickerRepos(t *testing.T) []Repo {
+	return loadRepos(t, "testdata/5_repos.json")
+}
+
+func pickerNames(p *picker) []string {
+	var names []string
+	for _, result := range p.results {
+		names = append(names, result.Full_name)
+	}
+	return names
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []key
+	}{
+		{name: "Text", input: "gö", want: []key{'g', 'ö'}},
+		{name: "Arrows", input: "\x1b[A\x1b[B\x1bOA", want: []key{keyUp, keyDown, keyUp}},
+		{name: "Pages", input: "\x1b[5~\x1b[6~", want: []key{keyPageUp, keyPageDown}},
+		{name: "UnknownSequence", input: "\x1b[1;5Cx", want: []key{'x'}},
+		{name: "Escape", input: "\x1b", want: []key{keyQuit}},
+		{name: "Controls", input: "\r\x7f\x15\x10\x0e\x0f\x03", want: []key{keyEnter, keyBackspace, keyClear, keyUp, keyDown, keyOpen, keyQuit}},
+		{name: "IgnoredControls", input: "\t\x01a", want: []key{'a'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseKeys([]byte(tt.input)))
+		})
+	}
+}
+
+func TestPicker(t *testing.T) {
+	setup([]string{})
+	p := newPicker(loadPickerRepos(t), "")
+
+	// Unfiltered, the repos are sorted by stars
+	assert.Equal(t, 5, p.total)
+	assert.Equal(t, []string{"karpathy/nanoGPT", "ianyh/Amethyst", "open-policy-agent/gatekeeper", "lithammer/fuzzysearch", "katiem0/gh-export-secrets"}, pickerNames(p))
+
+	// Typing filters with the search ranking
+	for _, r := range "go" {
+		assert.Equal(t, pickerNone, p.handle(key(r)))
+	}
+	assert.Equal(t, []string{"lithammer/fuzzysearch", "katiem0/gh-export-secrets"}, pickerNames(p))
+
+	// The cursor stays within the results
+	p.handle(keyUp)
+	assert.Equal(t, 0, p.cursor)
+	p.handle(keyDown)
+	p.handle(keyDown)
+	assert.Equal(t, 1, p.cursor)
+	result, ok := p.selected()
+	assert.True(t, ok)
+	assert.Equal(t, "katiem0/gh-export-secrets", result.Full_name)
+	assert.Equal(t, pickerPrint, p.handle(keyEnter))
+	assert.Equal(t, pickerOpen, p.handle(keyOpen))
+
+	// Filtering again selects the best result
+	p.handle(keyBackspace)
+	assert.Equal(t, "g", string(p.query))
+	assert.Equal(t, 0, p.cursor)
+
+	// An incomplete query keeps the previous results
+	p.handle(keyClear)
+	p.handle('"')
+	assert.Error(t, p.err)
+	assert.Len(t, p.results, 5)
+	p.handle(keyBackspace)
+	assert.NoError(t, p.err)
+
+	// Nothing can be picked without results
+	for _, r := range "zzzzzz" {
+		p.handle(key(r))
+	}
+	assert.Empty(t, p.results)
+	assert.Equal(t, pickerNone, p.handle(keyEnter))
+	assert.Equal(t, pickerQuit, p.handle(keyQuit))
+}
+
+func TestPickerView(t *testing.T) {
+	setup([]string{})
+	p := newPicker(loadPickerRepos(t), "")
+
+	lines := p.view(40, 4)
+	assert.Equal(t, []string{
+		"> ",
+		"  5/5",
+		"\x1b[7m> karpathy/nanoGPT  ★ 17109  The simp...\x1b[0m",
+		"  ianyh/Amethyst  ★ 12647  Automatic ...",
+	}, lines)
+
+	// The list scrolls to keep the selected result on screen
+	p.handle(keyPageDown)
+	p.handle(keyDown)
+	lines = p.view(40, 4)
+	assert.Equal(t, "  open-policy-agent/gatekeeper  ★ 302...", lines[2])
+	assert.True(t, strings.HasPrefix(lines[3], "\x1b[7m> lithammer/fuzzysearch"))
+
+	// The query errors replace the count
+	p.handle('"')
+	assert.Equal(t, "  unterminated quote", p.view(40, 4)[1])
+}
+
+func TestRunPicker(t *testing.T) {
+	setup([]string{})
+	size := func() (int, int) { return 80, 10 }
+
+	tests := []struct {
+		name       string
+		input      string
+		wantAction pickerAction
+		wantRepo   string
+	}{
+		{name: "Print", input: "gatekeeper\r", wantAction: pickerPrint, wantRepo: "open-policy-agent/gatekeeper"},
+		{name: "Open", input: "\x1b[B\x0f", wantAction: pickerOpen, wantRepo: "ianyh/Amethyst"},
+		{name: "Quit", input: "go\x03", wantAction: pickerQuit},
+		{name: "EndOfInput", input: "go", wantAction: pickerQuit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPicker(loadPickerRepos(t), "")
+			var out bytes.Buffer
+			action, err := runPicker(p, strings.NewReader(tt.input), &out, size)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantAction, action)
+			if tt.wantRepo != "" {
+				result, _ := p.selected()
+				assert.Equal(t, tt.wantRepo, result.Full_name)
+			}
+			assert.Contains(t, out.String(), "\x1b[H\x1b[2J> ")
+		})
+	}
+}
+
+func TestInteractiveWithoutTerminal(t *testing.T) {
+	setup([]string{})
+	// The tests don't run in a terminal
+	err := Interactive(loadPickerRepos(t), "")
+	assert.ErrorContains(t, err, "--interactive needs a terminal")
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The star lists of a user, the first 100 are more than anyone makes
+const listsQuery = `query($login: String!) {
+	user(login: $login) {
+		lists(first: 100) {
+			nodes { id name slug }
+		}
+	}
+}`
+
+// The repositories of a star list, one page at a time for gh api --paginate
+const listItemsQuery = `query($id: ID!, $endCursor: String) {
+	node(id: $id) {
+		... on UserList {
+			items(first: 100, after: $endCursor) {
+				nodes { ... on Repository { nameWithOwner } }
+				pageInfo { hasNextPage endCursor }
+			}
+		}
+	}
+}`
+
+// A StarList is a named list the user sorted some of their stars into
+type StarList struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// GetStarLists returns the star lists of the user
+func GetStarLists(user string) ([]StarList, error) {
+	InfoLogger.Println("Fetching the star lists of", user)
+	stdOut, stdErr, err := ghClient.Exec("api", "graphql", "-F", "login="+user, "-f", "query="+listsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s", err, stdErr.String())
+	}
+	var response struct {
+		Data struct {
+			User struct {
+				Lists struct {
+					Nodes []StarList `json:"nodes"`
+				} `json:"lists"`
+			} `json:"user"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(stdOut.Bytes(), &response); err != nil {
+		return nil, err
+	}
+	return response.Data.User.Lists.Nodes, nil
+}
+
+// FindStarList returns the list with the name or the slug, ignoring the case. The error
+// of an unknown list names the lists of the user.
+func FindStarList(lists []StarList, name string) (StarList, error) {
+	var names []string
+	for _, list := range lists {
+		if strings.EqualFold(list.Name, name) || strings.EqualFold(list.Slug, name) {
+			return list, nil
+		}
+		names = append(names, list.Name)
+	}
+	if len(names) == 0 {
+		return StarList{}, fmt.Errorf("no star list named %q, %s has no star lists", name, user)
+	}
+	return StarList{}, fmt.Errorf("no star list named %q, the lists of %s are: %s", name, user, strings.Join(names, ", "))
+}
+
+// GetListRepos returns the full names of the repos in the star list, from the cache of the
+// list next to the cache file. The cache is fetched again along with the cache file, when
+// the number of starred repos changes.
+func GetListRepos(list StarList, cacheKey [32]byte) (map[string]bool, error) {
+	// Without a cache file, the list is fetched without being cached
+	path, err := GetListPath(cacheKey, list.Id)
+	if err != nil && !errors.Is(err, errCacheBypassed) {
+		return nil, err
+	}
+
+	var names []string
+	if data, err := os.ReadFile(path); path != "" && err == nil && len(data) > 0 {
+		InfoLogger.Println("Reading the star list from the cache:", path)
+		if err := json.Unmarshal(data, &names); err != nil {
+			return nil, fmt.Errorf("not able to read the star list cache %s: %w", path, err)
+		}
+	} else {
+		if names, err = fetchListRepos(list); err != nil {
+			return nil, err
+		}
+		if path != "" {
+			InfoLogger.Printf("Writing the %d repos of the star list to the cache: %s", len(names), path)
+			data, err := json.Marshal(names)
+			if err != nil {
+				return nil, err
+			}
+			if err := saveCache(path, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	repos := make(map[string]bool, len(names))
+	for _, name := range names {
+		repos[name] = true
+	}
+	return repos, nil
+}
+
+// fetchListRepos returns the full names of the repos in the star list, from every page
+func fetchListRepos(list StarList) ([]string, error) {
+	InfoLogger.Println("Fetching the repos of the star list", list.Name)
+	stdOut, stdErr, err := ghClient.Exec("api", "graphql", "--paginate", "-F", "id="+list.Id, "-f", "query="+listItemsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s", err, stdErr.String())
+	}
+
+	// gh api --paginate prints the response of every page one after the other
+	names := []string{}
+	decoder := json.NewDecoder(&stdOut)
+	for {
+		var page struct {
+			Data struct {
+				Node struct {
+					Items struct {
+						Nodes []struct {
+							NameWithOwner string `json:"nameWithOwner"`
+						} `json:"nodes"`
+					} `json:"items"`
+				} `json:"node"`
+			} `json:"data"`
+		}
+		if err := decoder.Decode(&page); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for _, node := range page.Data.Node.Items.Nodes {
+			// Other kinds of items have no name
+			if node.NameWithOwner != "" {
+				names = append(names, node.NameWithOwner)
+			}
+		}
+	}
+	return names, nil
+}
+
+// GetListPath returns the path of the cache of the star list, next to the cache file.
+//
+// Example: ~/.cache/gh-stars/stars_link-_2d06a89b2687.list_UL_kwDOAB.json for ~/.cache/gh-stars/stars_link-_2d06a89b2687.json.gz
+func GetListPath(cacheKey [32]byte, listId string) (string, error) {
+	path, err := GetCachePath(cacheKey)
+	if err != nil {
+		return "", fmt.Errorf("not able to locate the cache: %w", err)
+	}
+	return fmt.Sprintf("%s.list_%s.json", trimCacheExt(path), listId), nil
+}
+
+// inStarList returns the filter keeping the repos of the star list, see --list
+func inStarList(repos map[string]bool) Filter {
+	return func(repo Repo) bool {
+		return repos[repo.Full_name]
+	}
+}
+
+// readStarList returns the repos of the star list named name, see --list
+func readStarList(name string, cacheKey [32]byte) (map[string]bool, error) {
+	lists, err := GetStarLists(user)
+	if err != nil {
+		return nil, fmt.Errorf("not able to get the star lists: %w", err)
+	}
+	list, err := FindStarList(lists, name)
+	if err != nil {
+		return nil, err
+	}
+	return GetListRepos(list, cacheKey)
+}
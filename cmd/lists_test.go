@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockListsGithub serves the star lists of the user and the pages of the list items
+type mockListsGithub struct {
+	lists string
+	pages string
+	calls int
+}
+
+func (m *mockListsGithub) Exec(args ...string) (bytes.Buffer, bytes.Buffer, error) {
+	m.calls++
+	if strings.Contains(strings.Join(args, " "), "--paginate") {
+		return *bytes.NewBufferString(m.pages), bytes.Buffer{}, nil
+	}
+	return *bytes.NewBufferString(m.lists), bytes.Buffer{}, nil
+}
+
+const testLists = `{"data":{"user":{"lists":{"nodes":[{"id":"UL_1","name":"Reading list","slug":"reading-list"},{"id":"UL_2","name":"Tools","slug":"tools"}]}}}}`
+
+func TestFindStarList(t *testing.T) {
+	setup([]string{})
+	user = "Link-"
+	defer func() { user = "" }()
+	ghClient = &mockListsGithub{lists: testLists}
+	lists, err := GetStarLists(user)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		list    string
+		wantId  string
+		wantErr string
+	}{
+		{name: "Name", list: "Tools", wantId: "UL_2"},
+		{name: "NameIgnoresCase", list: "reading LIST", wantId: "UL_1"},
+		{name: "Slug", list: "reading-list", wantId: "UL_1"},
+		{name: "Unknown", list: "games", wantErr: `no star list named "games", the lists of Link- are: Reading list, Tools`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindStarList(lists, tt.list)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantId, got.Id)
+		})
+	}
+
+	_, err = FindStarList(nil, "tools")
+	assert.EqualError(t, err, `no star list named "tools", Link- has no star lists`)
+}
+
+func TestGetListRepos(t *testing.T) {
+	setup([]string{})
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+
+	// Every page is a response of its own, the items that aren't repos have no name
+	github := &mockListsGithub{pages: `{"data":{"node":{"items":{"nodes":[{"nameWithOwner":"ianyh/Amethyst"},{}]}}}}
+{"data":{"node":{"items":{"nodes":[{"nameWithOwner":"karpathy/nanoGPT"}]}}}}`}
+	ghClient = github
+	list := StarList{Id: "UL_2", Name: "Tools", Slug: "tools"}
+	want := map[string]bool{"ianyh/Amethyst": true, "karpathy/nanoGPT": true}
+	got, err := GetListRepos(list, [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.FileExists(t, filepath.Join(filepath.Dir(cacheFile), "stars.list_UL_2.json"))
+
+	// The list is read from its cache on the next run
+	github = &mockListsGithub{}
+	ghClient = github
+	got, err = GetListRepos(list, [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Zero(t, github.calls)
+}
+
+func TestSearchStarList(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	starList = map[string]bool{"lithammer/fuzzysearch": true}
+	defer func() { starList = nil }()
+
+	// Only the repos of the list are kept, with or without a search term
+	found, err := Search(testData, "go")
+	assert.NoError(t, err)
+	kept, dropped := ApplyFilters(found, activeFilters())
+	assert.Equal(t, []string{"lithammer/fuzzysearch"}, uniqueRepos(kept))
+	assert.Equal(t, 1, dropped)
+
+	found, err = ListAll(testData)
+	assert.NoError(t, err)
+	kept, dropped = ApplyFilters(found, activeFilters())
+	assert.Equal(t, []string{"lithammer/fuzzysearch"}, uniqueRepos(kept))
+	assert.Equal(t, 4, dropped)
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package cmd
+
+import "os"
+
+// lockFile doesn't lock anything, the platform has no file lock
+func lockFile(file *os.File) error {
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(file *os.File) error {
+	return nil
+}
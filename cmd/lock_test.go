@@ -0,0 +1,41 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stars_2d06a89b2687.json.gz.lock")
+	first, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	assert.NoError(t, err)
+	defer first.Close()
+	second, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	assert.NoError(t, err)
+	defer second.Close()
+	assert.NoError(t, lockFile(first))
+
+	// The lock is exclusive, even within the process
+	locked := make(chan error)
+	go func() { locked <- lockFile(second) }()
+	select {
+	case <-locked:
+		t.Fatal("the lock was taken twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, unlockFile(first))
+	select {
+	case err := <-locked:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the lock wasn't released")
+	}
+	assert.NoError(t, unlockFile(second))
+}
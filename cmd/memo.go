@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Link-/gh-stars/lib/pq"
+)
+
+const RESULT_CACHE_SIZE = 50 // Number of searches kept in the result cache, the oldest are dropped first
+
+const RESULT_CACHE_VERSION = 2 // Version of the resultCache format, bumped when the matches change
+
+// A resultCache holds the ranked results of the last searches, next to the cache file, so
+// running the same search again with another --limit or output format skips the search.
+// It is only valid for the cache it was built from.
+type resultCache struct {
+	Version  int                `json:"version"`  // Format of the result cache, one with another version is dropped
+	Checksum [32]byte           `json:"checksum"` // Checksum of the cache the results come from
+	Entries  []resultCacheEntry `json:"entries"`  // Oldest first
+}
+
+// A resultCacheEntry is the results of a search, see resultCacheKey
+type resultCacheEntry struct {
+	Key     string         `json:"key"`
+	Results []cachedResult `json:"results"`
+}
+
+// A cachedResult is a Result without the repo, which is read from the cache file
+type cachedResult struct {
+	Full_name   string       `json:"full_name"`
+	Priority    int          `json:"priority"`
+	Matches     []Match      `json:"matched,omitempty"`
+	Explanation *Explanation `json:"explain,omitempty"`
+}
+
+// resultCacheKey identifies a search: the normalized queries along with every flag that
+// changes what matches or how it is ranked, the aliases loaded from --aliases rather than
+// its path, so editing the file is another search, and the version of gh stars, whose
+// scoring may differ. Filters are applied after the search, they don't change the results
+// of the search itself.
+func resultCacheKey(queries []string) string {
+	normalized := make([]string, len(queries))
+	for i, query := range queries {
+		normalized[i] = normalize(strings.Join(strings.Fields(query), " "))
+	}
+	// An aliases file that can't be loaded fails the search, see loadAliases
+	loaded, _ := loadAliases(aliasesFile)
+	key, _ := json.Marshal(struct {
+		Version       string
+		Queries       []string
+		Source        string
+		In            []string
+		FuzzyDistance int
+		FuzzyRatio    float64
+		AbsoluteDist  bool
+		AdaptiveDist  bool
+		Prefix        bool
+		MatchAll      bool
+		Weights       map[string]int
+		Aliases       map[string][]string
+		Explain       bool
+		SearchLimit   int
+	}{VERSION, normalized, source, searchIn, fuzzyDistance, fuzzyRatio, absoluteDist, adaptiveDist, prefixMatch, matchAll, weights, loaded, explain, searchLimit})
+	return fmt.Sprintf("%x", sha256.Sum256(key))
+}
+
+// SearchMemoized returns the results of SearchAll from the result cache when the same
+// search was run on the same cache file, otherwise it searches and adds the results to the
+// result cache. The repos are the starred repos decoded from the cache file. The result
+// cache is optional, searching goes on when it can't be read or written.
+func SearchMemoized(starredRepos bytes.Buffer, repos []Repo, cacheKey [32]byte, queries []string) (pq.PriorityQueue, error) {
+	s, err := newSearcher(starredRepos.Bytes(), repos)
+	if err != nil {
+		return nil, err
+	}
+	path, err := GetResultCachePath(cacheKey)
+	if err != nil {
+		InfoLogger.Println("Not able to locate the result cache", err)
+		return s.all(queries)
+	}
+	checksum := sha256.Sum256(starredRepos.Bytes())
+	key := resultCacheKey(queries)
+
+	var cache resultCache
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			InfoLogger.Println("Not able to read the result cache, ignoring it", err)
+			cache = resultCache{}
+		}
+	}
+	// The results of another cache file are useless, it was rewritten since
+	if cache.Version != RESULT_CACHE_VERSION || cache.Checksum != checksum {
+		cache = resultCache{Version: RESULT_CACHE_VERSION, Checksum: checksum}
+	}
+	for _, entry := range cache.Entries {
+		if entry.Key != key {
+			continue
+		}
+		if found, ok := restoreResults(repos, entry.Results); ok {
+			InfoLogger.Println("Reading the results from the result cache:", path)
+			return found, nil
+		}
+	}
+
+	found, err := s.all(queries)
+	if err != nil {
+		return nil, err
+	}
+	entry := resultCacheEntry{Key: key, Results: make([]cachedResult, 0, found.Len())}
+	for _, item := range found {
+		result := item.Value.(Result)
+		entry.Results = append(entry.Results, cachedResult{Full_name: result.Full_name, Priority: item.Priority, Matches: result.Matches, Explanation: result.Explanation})
+	}
+	entries := []resultCacheEntry{}
+	for _, existing := range cache.Entries {
+		if existing.Key != key {
+			entries = append(entries, existing)
+		}
+	}
+	cache.Entries = append(entries, entry)
+	if len(cache.Entries) > RESULT_CACHE_SIZE {
+		cache.Entries = cache.Entries[len(cache.Entries)-RESULT_CACHE_SIZE:]
+	}
+	data, err := json.Marshal(cache)
+	if err == nil {
+		err = saveCache(path, data)
+	}
+	if err != nil {
+		InfoLogger.Println("Not able to write the result cache", err)
+	}
+	return found, nil
+}
+
+// restoreResults returns the cached results with their repos, and false if one of the
+// repos is missing from the starred repos
+func restoreResults(repos []Repo, cached []cachedResult) (pq.PriorityQueue, bool) {
+	byName := make(map[string]Repo, len(repos))
+	for _, repo := range repos {
+		byName[repo.Full_name] = repo
+	}
+
+	var found = make(pq.PriorityQueue, 0, len(cached))
+	heap.Init(&found)
+	for _, result := range cached {
+		repo, ok := byName[result.Full_name]
+		if !ok {
+			return nil, false
+		}
+		heap.Push(&found, &pq.Item{
+			Value:    Result{Repo: repo, Matches: result.Matches, Explanation: result.Explanation},
+			Priority: result.Priority,
+		})
+	}
+	return found, true
+}
+
+// GetResultCachePath returns the path of the result cache, next to the cache file.
+//
+// Example: ~/.cache/gh-stars/stars_link-_2d06a89b2687.results.json for ~/.cache/gh-stars/stars_link-_2d06a89b2687.json.gz
+func GetResultCachePath(cacheKey [32]byte) (string, error) {
+	path, err := GetCachePath(cacheKey)
+	if err != nil {
+		return "", fmt.Errorf("not able to locate the cache: %w", err)
+	}
+	return trimCacheExt(path) + ".results.json", nil
+}
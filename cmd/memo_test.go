@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchMemoized(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	repos := loadRepos(t, "testdata/5_repos.json")
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+	path, err := GetResultCachePath([32]byte{})
+	assert.NoError(t, err)
+
+	// The results are cached on the first search
+	want, err := Search(testData, "fuzzy")
+	assert.NoError(t, err)
+	found, err := SearchMemoized(testData, repos, [32]byte{}, []string{"fuzzy"})
+	assert.NoError(t, err)
+	assert.Equal(t, uniqueRepos(want), uniqueRepos(found))
+	var cache resultCache
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &cache))
+	assert.Len(t, cache.Entries, 1)
+
+	// The same search, with another spacing and case, reads the cached results
+	cache.Entries[0].Results[0].Priority = 1234
+	data, err = json.Marshal(cache)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+	found, err = SearchMemoized(testData, repos, [32]byte{}, []string{" FUZZY "})
+	assert.NoError(t, err)
+	assert.Equal(t, 1234, found[0].Priority)
+
+	// The results cached by another version are dropped, their matches may lack fields
+	cache.Version = RESULT_CACHE_VERSION - 1
+	data, err = json.Marshal(cache)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+	found, err = SearchMemoized(testData, repos, [32]byte{}, []string{"fuzzy"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, 1234, found[0].Priority)
+	cache.Version = RESULT_CACHE_VERSION
+	data, err = json.Marshal(cache)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+
+	// Another search flag is another search
+	matchAll = true
+	found, err = SearchMemoized(testData, repos, [32]byte{}, []string{"fuzzy"})
+	matchAll = false
+	assert.NoError(t, err)
+	assert.NotEqual(t, 1234, found[0].Priority)
+
+	// A rewritten cache file drops every cached result
+	rewritten := *bytes.NewBuffer(append(testData.Bytes(), '\n'))
+	found, err = SearchMemoized(rewritten, repos, [32]byte{}, []string{"fuzzy"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, 1234, found[0].Priority)
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &cache))
+	assert.Len(t, cache.Entries, 1)
+}
+
+func TestResultCacheSize(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	repos := loadRepos(t, "testdata/5_repos.json")
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+
+	for i := 0; i < RESULT_CACHE_SIZE+5; i++ {
+		_, err := SearchMemoized(testData, repos, [32]byte{}, []string{"go", string(rune('a' + i%26)), string(rune('a' + i/26))})
+		assert.NoError(t, err)
+	}
+	path, err := GetResultCachePath([32]byte{})
+	assert.NoError(t, err)
+	var cache resultCache
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &cache))
+	assert.Len(t, cache.Entries, RESULT_CACHE_SIZE)
+	assert.Equal(t, resultCacheKey([]string{"go", string(rune('a' + (RESULT_CACHE_SIZE+4)%26)), string(rune('a' + (RESULT_CACHE_SIZE+4)/26))}), cache.Entries[RESULT_CACHE_SIZE-1].Key)
+}
+
+func TestResultCacheKeyAliases(t *testing.T) {
+	setup([]string{})
+	aliasesFile = filepath.Join(t.TempDir(), "aliases")
+	defer func() { aliasesFile = "" }()
+	assert.NoError(t, os.WriteFile(aliasesFile, []byte("tf=terraform\n"), 0644))
+	key := resultCacheKey([]string{"tf"})
+	assert.Equal(t, key, resultCacheKey([]string{"tf"}))
+
+	// Editing the aliases file is another search
+	assert.NoError(t, os.WriteFile(aliasesFile, []byte("tf=tensorflow\n"), 0644))
+	assert.NotEqual(t, key, resultCacheKey([]string{"tf"}))
+}
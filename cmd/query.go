@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Field prefixes accepted in a query and the searchable field they scope a term to
+var queryFields = map[string]string{
+	"name":        "name",
+	"owner":       "owner",
+	"description": "description",
+	"desc":        "description",
+	"topic":       "topics",
+	"topics":      "topics",
+	"homepage":    "homepage",
+	"readme":      "readme",
+}
+
+// A Term is a single search term of a query
+type Term struct {
+	Value   string // The text to match
+	Field   string // The field the term is scoped to, empty for every field
+	Exclude bool   // Repos matching the term are dropped from the results
+}
+
+// A QueryError is returned when a query can't be parsed. It points at the
+// position of the problem in the query with a caret.
+type QueryError struct {
+	Query    string
+	Position int // 1-based position of the problem, in characters
+	Message  string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s at position %d in the query\n\t%s\n\t%s^", e.Message, e.Position, e.Query, strings.Repeat(" ", e.Position-1))
+}
+
+// ParseQuery splits the query into terms separated by whitespace.
+//
+// A term can be prefixed with a field and a colon to only search that field, e.g. name:cli
+// or topic:golang. Double quotes group several words into a single term, e.g. name:"go cli".
+// A term prefixed with a minus is an exclusion, e.g. -client or -topic:deprecated.
+// A quoted term is always taken literally, "http://" is not parsed as a field.
+func ParseQuery(query string) ([]Term, error) {
+	var terms []Term
+	runes := []rune(query)
+	i := 0
+	for i < len(runes) {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		term, next, err := readTerm(query, runes, i, unicode.IsSpace)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		i = next
+	}
+	return terms, nil
+}
+
+// readTerm reads the term starting at position i of the query and returns it along with
+// the position following it. An unquoted term ends at the first rune matching isEnd.
+func readTerm(query string, runes []rune, i int, isEnd func(rune) bool) (Term, int, error) {
+	term := Term{}
+	start := i
+	// A lone minus is a term on its own, not an exclusion
+	if runes[i] == '-' && i+1 < len(runes) && !isEnd(runes[i+1]) {
+		term.Exclude = true
+		i++
+	}
+
+	// Field prefix: letters followed by a colon
+	j := i
+	for j < len(runes) && unicode.IsLetter(runes[j]) {
+		j++
+	}
+	if j > i && j < len(runes) && runes[j] == ':' {
+		prefix := strings.ToLower(string(runes[i:j]))
+		field, ok := queryFields[prefix]
+		if !ok {
+			return term, i, &QueryError{Query: query, Position: i + 1, Message: fmt.Sprintf("unknown field %q (valid fields are: name, owner, description, topic, homepage, readme)", prefix)}
+		}
+		term.Field = field
+		i = j + 1
+	}
+
+	if i < len(runes) && runes[i] == '"' {
+		end := i + 1
+		for end < len(runes) && runes[end] != '"' {
+			end++
+		}
+		if end == len(runes) {
+			return term, i, &QueryError{Query: query, Position: i + 1, Message: "unterminated quote"}
+		}
+		term.Value = string(runes[i+1 : end])
+		i = end + 1
+	} else {
+		end := i
+		for end < len(runes) && !isEnd(runes[end]) {
+			end++
+		}
+		term.Value = string(runes[i:end])
+		i = end
+	}
+
+	if term.Value == "" {
+		return term, i, &QueryError{Query: query, Position: start + 1, Message: "empty search term"}
+	}
+	return term, i, nil
+}
+
+// IsBooleanQuery reports whether the query uses the AND, OR and NOT operators or
+// parentheses, outside of quotes. Other queries are a plain list of terms.
+func IsBooleanQuery(query string) bool {
+	quoted := false
+	for _, word := range strings.FieldsFunc(query, func(r rune) bool {
+		if r == '"' {
+			quoted = !quoted
+		}
+		return !quoted && unicode.IsSpace(r)
+	}) {
+		if word == "AND" || word == "OR" || word == "NOT" ||
+			strings.HasPrefix(word, "(") || strings.HasSuffix(word, ")") {
+			return true
+		}
+	}
+	return false
+}
+
+// A Query is a parsed search query: a plain list of terms, or a boolean query when Expr
+// is set. Parsing it once lets the same query run against several sets of repos.
+type Query struct {
+	Text  string // The query as it was typed
+	Terms []Term // Every term of the query, the terms of Expr for boolean queries
+	Expr  Expr   // Only set for boolean queries, see ParseBooleanQuery
+}
+
+// NewQuery parses the query with ParseBooleanQuery when it is a boolean query, and with
+// ParseQuery otherwise
+func NewQuery(text string) (Query, error) {
+	query := Query{Text: text}
+	if !IsBooleanQuery(text) {
+		terms, err := ParseQuery(text)
+		if err != nil {
+			return Query{}, err
+		}
+		query.Terms = terms
+		return query, nil
+	}
+	expr, err := ParseBooleanQuery(text)
+	if err != nil {
+		return Query{}, err
+	}
+	query.Expr = expr
+	query.Terms = expr.Terms()
+	return query, nil
+}
+
+// An Expr is a node of a boolean query
+type Expr interface {
+	// Eval reports whether the repo satisfies the expression and returns the best
+	// match of every positive term that matched
+	Eval(doc *document, fields map[string]bool) (bool, []Match)
+	// Terms returns the terms of the expression
+	Terms() []Term
+}
+
+type termExpr struct{ term Term }
+
+func (e termExpr) Eval(doc *document, fields map[string]bool) (bool, []Match) {
+	if e.term.Exclude {
+		return !isExcluded(doc.Repo, []Term{e.term}), nil
+	}
+	matches := scoreTerm(doc, e.term.Value, termFields(e.term, fields))
+	if len(matches) == 0 {
+		return false, nil
+	}
+	return true, []Match{bestOf(matches)}
+}
+
+func (e termExpr) Terms() []Term { return []Term{e.term} }
+
+type andExpr struct{ left, right Expr }
+
+func (e andExpr) Eval(doc *document, fields map[string]bool) (bool, []Match) {
+	leftMatch, leftMatches := e.left.Eval(doc, fields)
+	if !leftMatch {
+		return false, nil
+	}
+	rightMatch, rightMatches := e.right.Eval(doc, fields)
+	if !rightMatch {
+		return false, nil
+	}
+	return true, append(leftMatches, rightMatches...)
+}
+
+func (e andExpr) Terms() []Term { return append(e.left.Terms(), e.right.Terms()...) }
+
+type orExpr struct{ left, right Expr }
+
+func (e orExpr) Eval(doc *document, fields map[string]bool) (bool, []Match) {
+	leftMatch, leftMatches := e.left.Eval(doc, fields)
+	rightMatch, rightMatches := e.right.Eval(doc, fields)
+	return leftMatch || rightMatch, append(leftMatches, rightMatches...)
+}
+
+func (e orExpr) Terms() []Term { return append(e.left.Terms(), e.right.Terms()...) }
+
+type notExpr struct{ expr Expr }
+
+func (e notExpr) Eval(doc *document, fields map[string]bool) (bool, []Match) {
+	match, _ := e.expr.Eval(doc, fields)
+	return !match, nil
+}
+
+func (e notExpr) Terms() []Term { return e.expr.Terms() }
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind     tokenKind
+	term     Term
+	position int // 1-based position of the token in the query
+}
+
+// ParseBooleanQuery parses a query using the AND, OR and NOT operators and parentheses.
+// Operators are case-sensitive, terms follow the syntax of ParseQuery.
+// NOT binds tighter than AND, which binds tighter than OR. Terms next to each other
+// without an operator are combined with AND: "rust NOT bindings" is "rust AND NOT bindings".
+func ParseBooleanQuery(query string) (Expr, error) {
+	tokens, err := lexBooleanQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &booleanParser{query: query, tokens: tokens}
+	if len(tokens) == 0 {
+		return nil, &QueryError{Query: query, Position: 1, Message: "empty query"}
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %s", p.describe(p.tokens[p.pos]))
+	}
+	return expr, nil
+}
+
+func lexBooleanQuery(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	isEnd := func(r rune) bool { return unicode.IsSpace(r) || r == '(' || r == ')' }
+	i := 0
+	for i < len(runes) {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '(':
+			tokens = append(tokens, token{kind: tokenOpen, position: i + 1})
+			i++
+		case runes[i] == ')':
+			tokens = append(tokens, token{kind: tokenClose, position: i + 1})
+			i++
+		default:
+			term, next, err := readTerm(query, runes, i, isEnd)
+			if err != nil {
+				return nil, err
+			}
+			kind := tokenTerm
+			if term.Field == "" && !term.Exclude && runes[i] != '"' {
+				switch term.Value {
+				case "AND":
+					kind = tokenAnd
+				case "OR":
+					kind = tokenOr
+				case "NOT":
+					kind = tokenNot
+				}
+			}
+			tokens = append(tokens, token{kind: kind, term: term, position: i + 1})
+			i = next
+		}
+	}
+	return tokens, nil
+}
+
+type booleanParser struct {
+	query  string
+	tokens []token
+	pos    int
+}
+
+func (p *booleanParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *booleanParser) errorf(format string, args ...any) error {
+	position := len([]rune(p.query)) + 1
+	if p.pos < len(p.tokens) {
+		position = p.tokens[p.pos].position
+	}
+	return &QueryError{Query: p.query, Position: position, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *booleanParser) describe(t token) string {
+	switch t.kind {
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenOpen:
+		return `"("`
+	case tokenClose:
+		return `")"`
+	default:
+		return fmt.Sprintf("term %q", t.term.Value)
+	}
+}
+
+// parseOr: and (OR and)*
+func (p *booleanParser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokenOr {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left: left, right: right}
+	}
+}
+
+// parseAnd: not ((AND)? not)*
+func (p *booleanParser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokenOr || t.kind == tokenClose {
+			return left, nil
+		}
+		if t.kind == tokenAnd {
+			p.pos++
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left: left, right: right}
+	}
+}
+
+// parseNot: NOT not | primary
+func (p *booleanParser) parseNot() (Expr, error) {
+	t, ok := p.peek()
+	if ok && t.kind == tokenNot {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary: term | ( or )
+func (p *booleanParser) parsePrimary() (Expr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, p.errorf("unexpected end of query, expected a term")
+	}
+	switch t.kind {
+	case tokenTerm:
+		p.pos++
+		return termExpr{term: t.term}, nil
+	case tokenOpen:
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.kind != tokenClose {
+			if !ok {
+				return nil, &QueryError{Query: p.query, Position: t.position, Message: `unclosed "("`}
+			}
+			return nil, p.errorf(`expected ")" but found %s`, p.describe(closing))
+		}
+		p.pos++
+		return expr, nil
+	default:
+		return nil, p.errorf("unexpected %s, expected a term", p.describe(t))
+	}
+}
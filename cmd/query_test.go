@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []Term
+		wantErr string
+	}{
+		{
+			name:  "EmptyQuery",
+			query: "  ",
+			want:  nil,
+		},
+		{
+			name:  "BareTerms",
+			query: "rust  parser",
+			want:  []Term{{Value: "rust"}, {Value: "parser"}},
+		},
+		{
+			name:  "FieldPrefixes",
+			query: "name:cli topic:golang parser",
+			want:  []Term{{Value: "cli", Field: "name"}, {Value: "golang", Field: "topics"}, {Value: "parser"}},
+		},
+		{
+			name:  "FieldPrefixAliasesAndCase",
+			query: "DESC:fast Owner:hashicorp topics:go",
+			want:  []Term{{Value: "fast", Field: "description"}, {Value: "hashicorp", Field: "owner"}, {Value: "go", Field: "topics"}},
+		},
+		{
+			name:  "QuotedValues",
+			query: `name:"go cli" "static site"`,
+			want:  []Term{{Value: "go cli", Field: "name"}, {Value: "static site"}},
+		},
+		{
+			name:  "QuotedTermIsLiteral",
+			query: `"http://example.com"`,
+			want:  []Term{{Value: "http://example.com"}},
+		},
+		{
+			name:  "Exclusions",
+			query: `http -client -topic:deprecated -"rest sdk"`,
+			want:  []Term{{Value: "http"}, {Value: "client", Exclude: true}, {Value: "deprecated", Field: "topics", Exclude: true}, {Value: "rest sdk", Exclude: true}},
+		},
+		{
+			name:  "LoneMinus",
+			query: "c - d",
+			want:  []Term{{Value: "c"}, {Value: "-"}, {Value: "d"}},
+		},
+		{
+			name:  "ColonWithoutLetters",
+			query: "c++:17",
+			want:  []Term{{Value: "c++:17"}},
+		},
+		{
+			name:    "UnknownField",
+			query:   "cli language:parser",
+			wantErr: "unknown field \"language\" (valid fields are: name, owner, description, topic, homepage, readme) at position 5 in the query\n\tcli language:parser\n\t    ^",
+		},
+		{
+			name:    "UnterminatedQuote",
+			query:   `name:"go cli`,
+			wantErr: "unterminated quote at position 6 in the query",
+		},
+		{
+			name:    "EmptyValue",
+			query:   "cli name:",
+			wantErr: "empty search term at position 5 in the query",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.query)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsBooleanQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "rust parser", want: false},
+		{query: "rust AND parser", want: true},
+		{query: "rust OR parser", want: true},
+		{query: "rust NOT parser", want: true},
+		{query: "rust (parser)", want: true},
+		{query: "rust and parser", want: false},
+		{query: `rust "AND" parser`, want: false},
+		{query: `"rust (parser)"`, want: false},
+		{query: "c(x)y", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsBooleanQuery(tt.query))
+		})
+	}
+}
+
+func TestNewQuery(t *testing.T) {
+	query, err := NewQuery("name:cli -deprecated")
+	assert.NoError(t, err)
+	assert.Nil(t, query.Expr)
+	assert.Equal(t, []Term{{Value: "cli", Field: "name"}, {Value: "deprecated", Exclude: true}}, query.Terms)
+
+	query, err = NewQuery("rust AND (cli OR tui)")
+	assert.NoError(t, err)
+	assert.NotNil(t, query.Expr)
+	assert.Equal(t, []Term{{Value: "rust"}, {Value: "cli"}, {Value: "tui"}}, query.Terms)
+	assert.Equal(t, "rust AND (cli OR tui)", query.Text)
+
+	_, err = NewQuery(`name:"go cli`)
+	assert.Error(t, err)
+	_, err = NewQuery("rust AND")
+	assert.Error(t, err)
+}
+
+func TestParseBooleanQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    Expr
+		wantErr string
+	}{
+		{
+			name:  "And",
+			query: "rust AND parser",
+			want:  andExpr{termExpr{Term{Value: "rust"}}, termExpr{Term{Value: "parser"}}},
+		},
+		{
+			name:  "ImplicitAnd",
+			query: "rust NOT bindings",
+			want:  andExpr{termExpr{Term{Value: "rust"}}, notExpr{termExpr{Term{Value: "bindings"}}}},
+		},
+		{
+			name:  "Precedence",
+			query: "a OR b AND NOT c",
+			want:  orExpr{termExpr{Term{Value: "a"}}, andExpr{termExpr{Term{Value: "b"}}, notExpr{termExpr{Term{Value: "c"}}}}},
+		},
+		{
+			name:  "Parentheses",
+			query: "rust AND (parser OR lexer) NOT bindings",
+			want: andExpr{
+				andExpr{termExpr{Term{Value: "rust"}}, orExpr{termExpr{Term{Value: "parser"}}, termExpr{Term{Value: "lexer"}}}},
+				notExpr{termExpr{Term{Value: "bindings"}}},
+			},
+		},
+		{
+			name:  "FieldPrefixAndQuotes",
+			query: `(topic:go OR "AND") -cli`,
+			want:  andExpr{orExpr{termExpr{Term{Value: "go", Field: "topics"}}, termExpr{Term{Value: "AND"}}}, termExpr{Term{Value: "cli", Exclude: true}}},
+		},
+		{
+			name:    "UnclosedParenthesis",
+			query:   "rust AND (parser OR lexer",
+			wantErr: "unclosed \"(\" at position 10 in the query\n\trust AND (parser OR lexer\n\t         ^",
+		},
+		{
+			name:    "UnexpectedClosingParenthesis",
+			query:   "rust AND parser)",
+			wantErr: "unexpected \")\" at position 16 in the query\n\trust AND parser)\n\t               ^",
+		},
+		{
+			name:    "MissingOperand",
+			query:   "rust AND",
+			wantErr: "unexpected end of query, expected a term at position 9 in the query",
+		},
+		{
+			name:    "DoubleOperator",
+			query:   "rust OR AND parser",
+			wantErr: "unexpected AND, expected a term at position 9 in the query",
+		},
+		{
+			name:    "EmptyParentheses",
+			query:   "rust ()",
+			wantErr: "unexpected \")\", expected a term at position 7 in the query",
+		},
+		{
+			name:    "UnknownField",
+			query:   "rust AND language:parser",
+			wantErr: "unknown field \"language\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBooleanQuery(tt.query)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/Link-/gh-stars/lib/pq"
+)
+
+// PickRandom returns one of the results picked uniformly at random, see --random, and
+// false when there are no results. The results are sorted by rank first, so the same
+// seed always picks the same result among the same results.
+func PickRandom(results pq.PriorityQueue, random *rand.Rand) (*pq.Item, bool) {
+	if results.Len() == 0 {
+		return nil, false
+	}
+	sorted := make([]*pq.Item, 0, results.Len())
+	for results.Len() > 0 {
+		sorted = append(sorted, heap.Pop(&results).(*pq.Item))
+	}
+	return sorted[random.Intn(len(sorted))], true
+}
+
+// RenderRandom renders the result picked by --random as a table of a single row, or as a
+// JSON object with --json
+func RenderRandom(item *pq.Item, renderTarget io.Writer) error {
+	if !jsonOutput {
+		results := pq.PriorityQueue{&pq.Item{Value: item.Value, Priority: item.Priority}}
+		heap.Init(&results)
+		return RenderTable(results, 1, renderTarget)
+	}
+	InfoLogger.Println("Rendering the random result in JSON format")
+	jsonOutput, err := json.MarshalIndent(item.Value.(Result), "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(renderTarget, "%s", jsonOutput)
+	return nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickRandom(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+
+	_, ok := PickRandom(pq.PriorityQueue{}, rand.New(rand.NewSource(1)))
+	assert.False(t, ok)
+
+	// The same seed picks the same repo, however the results were built
+	pick := func(seed int64) string {
+		found, err := SearchAll(testData, []string{"go", "kubernetes", "window"})
+		assert.NoError(t, err)
+		item, ok := PickRandom(found, rand.New(rand.NewSource(seed)))
+		assert.True(t, ok)
+		return item.Value.(Result).Full_name
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		assert.Equal(t, pick(seed), pick(seed))
+	}
+
+	// Every repo gets picked, without --find as well
+	picked := make(map[string]bool)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		found, err := ListAll(testData)
+		assert.NoError(t, err)
+		item, _ := PickRandom(found, random)
+		picked[item.Value.(Result).Full_name] = true
+	}
+	assert.Len(t, picked, 5)
+}
+
+func TestRenderRandom(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	defer func() { jsonOutput = false }()
+
+	found, err := Search(testData, "gatekeeper")
+	assert.NoError(t, err)
+	item, ok := PickRandom(found, rand.New(rand.NewSource(1)))
+	assert.True(t, ok)
+
+	// A table of a single row
+	var table bytes.Buffer
+	assert.NoError(t, RenderRandom(item, &table))
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "open-policy-agent/gatekeeper")
+
+	// A JSON object rather than an array
+	jsonOutput = true
+	var output bytes.Buffer
+	assert.NoError(t, RenderRandom(item, &output))
+	var result Result
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &result))
+	assert.Equal(t, "open-policy-agent/gatekeeper", result.Full_name)
+}
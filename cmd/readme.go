@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	README_SCORE                 = WEIGHT_SCORE // Score of an exact match in a README, the lowest weight a field can have
+	README_WORKERS               = 8            // Number of READMEs fetched at the same time
+	README_MAX_LENGTH            = 64 * 1024    // READMEs are truncated to this many bytes in the cache
+	DEFAULT_README_LIMIT         = 300          // Up to this many starred repos, every README is fetched, see --readme-limit
+	README_MIN_DESCRIPTION_WORDS = 5            // Above --readme-limit, only the READMEs of repos with a shorter description are fetched
+)
+
+// errRateLimited is returned when GitHub refuses to serve more READMEs for now
+var errRateLimited = errors.New("api rate limit reached")
+
+// splitReadme returns the distinct words of a README, ignoring the markdown punctuation
+func splitReadme(readme string) []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, word := range strings.FieldsFunc(readme, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// GetReadmes returns the READMEs of the starred repos indexed by full name, from the
+// README cache next to the cache of the starred repos. The READMEs missing from the
+// cache are fetched from the API and added to it: all of them when there are at most
+// readmeLimit starred repos, otherwise only those of the repos with a short description,
+// which are the ones the README helps find. Repos without a README have an empty one.
+//
+// When the API rate limit is reached, the READMEs fetched so far are cached and the
+// others are fetched on the next run.
+func GetReadmes(repos []Repo, cacheKey [32]byte) (map[string]string, error) {
+	// Without a cache file, the READMEs are fetched without being cached
+	path, err := GetReadmePath(cacheKey)
+	if err != nil && !errors.Is(err, errCacheBypassed) {
+		return nil, err
+	}
+
+	readmes := make(map[string]string)
+	if data, err := os.ReadFile(path); path != "" && err == nil && len(data) > 0 {
+		InfoLogger.Println("Reading the READMEs from the cache:", path)
+		if err := json.Unmarshal(data, &readmes); err != nil {
+			return nil, fmt.Errorf("not able to read the README cache %s: %w", path, err)
+		}
+	}
+
+	var pending []string
+	for _, repo := range repos {
+		if _, ok := readmes[repo.Full_name]; ok {
+			continue
+		}
+		if len(repos) <= readmeLimit || len(strings.Fields(repo.Description)) < README_MIN_DESCRIPTION_WORDS {
+			pending = append(pending, repo.Full_name)
+		}
+	}
+	if len(pending) == 0 {
+		return readmes, nil
+	}
+
+	fetched, err := fetchReadmes(pending)
+	for name, readme := range fetched {
+		readmes[name] = readme
+	}
+	if len(fetched) > 0 && path != "" {
+		InfoLogger.Printf("Writing %d fetched READMEs to the cache: %s", len(fetched), path)
+		data, err := json.Marshal(readmes)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveCache(path, data); err != nil {
+			return nil, err
+		}
+	}
+	if errors.Is(err, errRateLimited) {
+		WarnLogger.Printf("The GitHub API rate limit was reached, %d READMEs will be fetched on the next run", len(pending)-len(fetched))
+	} else if err != nil {
+		WarnLogger.Printf("Not able to fetch %d READMEs, they will be fetched on the next run: %v", len(pending)-len(fetched), err)
+	}
+	return readmes, nil
+}
+
+// fetchReadmes fetches the READMEs of the repos with README_WORKERS concurrent API calls,
+// reporting the progress on stderr. It returns the READMEs fetched, along with
+// errRateLimited if the rate limit was reached or the last error encountered.
+func fetchReadmes(names []string) (map[string]string, error) {
+	InfoLogger.Printf("Fetching the READMEs of %d repos", len(names))
+	progress := WarnLogger.Writer()
+	jobs := make(chan string)
+	readmes := make(map[string]string, len(names))
+	var lastErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	done := 0
+	for i := 0; i < README_WORKERS; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				mu.Lock()
+				// Every other call would fail as well, skip them
+				stop := errors.Is(lastErr, errRateLimited)
+				mu.Unlock()
+				if stop {
+					continue
+				}
+
+				readme, err := fetchReadme(name)
+				mu.Lock()
+				done++
+				if err != nil {
+					if !errors.Is(lastErr, errRateLimited) {
+						lastErr = err
+					}
+					InfoLogger.Printf("Not able to fetch the README of %s: %v", name, err)
+				} else {
+					readmes[name] = readme
+				}
+				fmt.Fprintf(progress, "\rFetching the READMEs: %d/%d", done, len(names))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Fetch in a stable order, so an interrupted run resumes where it stopped
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+	fmt.Fprintln(progress)
+	return readmes, lastErr
+}
+
+// fetchReadme returns the README of the repo as text, truncated to README_MAX_LENGTH.
+// Repos without a README have an empty one.
+func fetchReadme(fullName string) (string, error) {
+	args := []string{"api", "-H", "Accept: application/vnd.github.raw", fmt.Sprintf("repos/%s/readme", fullName)}
+	stdOut, stdErr, err := ghClient.Exec(args...)
+	if err != nil {
+		message := stdErr.String() + err.Error()
+		switch {
+		case strings.Contains(message, "HTTP 404"):
+			return "", nil
+		case strings.Contains(message, "HTTP 429"), strings.Contains(message, "rate limit"):
+			return "", errRateLimited
+		}
+		return "", err
+	}
+
+	readme := stdOut.String()
+	if len(readme) > README_MAX_LENGTH {
+		readme = strings.ToValidUTF8(readme[:README_MAX_LENGTH], "")
+	}
+	return readme, nil
+}
+
+// GetReadmePath returns the path of the README cache, next to the cache file.
+//
+// Example: ~/.cache/gh-stars/stars_link-_2d06a89b2687.readme.json for ~/.cache/gh-stars/stars_link-_2d06a89b2687.json.gz
+func GetReadmePath(cacheKey [32]byte) (string, error) {
+	path, err := GetCachePath(cacheKey)
+	if err != nil {
+		return "", fmt.Errorf("not able to locate the cache: %w", err)
+	}
+	return trimCacheExt(path) + ".readme.json", nil
+}
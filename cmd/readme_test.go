@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockReadmeGithub serves the READMEs by full name, repos without one get a 404.
+// Once rateLimit calls were made, every call fails like when the API rate limit is reached.
+type mockReadmeGithub struct {
+	readmes   map[string]string
+	rateLimit int
+	mu        sync.Mutex
+	calls     []string
+}
+
+func (m *mockReadmeGithub) Exec(args ...string) (bytes.Buffer, bytes.Buffer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := strings.TrimSuffix(strings.TrimPrefix(args[len(args)-1], "repos/"), "/readme")
+	if m.rateLimit > 0 && len(m.calls) >= m.rateLimit {
+		return bytes.Buffer{}, *bytes.NewBufferString("gh: API rate limit exceeded for user ID 1. (HTTP 403)"), errors.New("exit status 1")
+	}
+	m.calls = append(m.calls, name)
+	readme, ok := m.readmes[name]
+	if !ok {
+		return bytes.Buffer{}, *bytes.NewBufferString("gh: Not Found (HTTP 404)"), errors.New("exit status 1")
+	}
+	return *bytes.NewBufferString(readme), bytes.Buffer{}, nil
+}
+
+func TestSplitReadme(t *testing.T) {
+	readme := "# fuzzy-search\n\n[![Build](https://ci/badge.svg)](https://ci)\n\nA **fuzzy** search, with fuzzy_matching."
+	assert.Equal(t, []string{"fuzzy-search", "Build", "https", "ci", "badge", "svg", "A", "fuzzy", "search", "with", "fuzzy_matching"}, splitReadme(readme))
+	assert.Nil(t, splitReadme(""))
+}
+
+func TestGetReadmes(t *testing.T) {
+	setup([]string{})
+	repos := loadRepos(t, "testdata/5_repos.json")
+	readmeTexts := map[string]string{
+		"ianyh/Amethyst":               "# Amethyst\nTiling window manager",
+		"katiem0/gh-export-secrets":    "# gh-export-secrets",
+		"open-policy-agent/gatekeeper": "# Gatekeeper\nAdmission webhook",
+		"lithammer/fuzzysearch":        "# Fuzzy Search\nLevenshtein distance",
+	}
+	readmeLimit = DEFAULT_README_LIMIT
+	defer func() {
+		cacheFile = ""
+		ghClient = &MockGithub{}
+		readmeLimit = 0
+	}()
+
+	t.Run("FetchedOnceThenCached", func(t *testing.T) {
+		cacheFile = filepath.Join(t.TempDir(), "stars.json")
+		github := &mockReadmeGithub{readmes: readmeTexts}
+		ghClient = github
+		got, err := GetReadmes(repos, [32]byte{})
+		assert.NoError(t, err)
+		// The repo without a README is cached so it isn't fetched again
+		want := map[string]string{"karpathy/nanoGPT": ""}
+		for name, readme := range readmeTexts {
+			want[name] = readme
+		}
+		assert.Equal(t, want, got)
+		assert.Len(t, github.calls, 5)
+		assert.FileExists(t, filepath.Join(filepath.Dir(cacheFile), "stars.readme.json"))
+
+		github = &mockReadmeGithub{readmes: readmeTexts}
+		ghClient = github
+		got, err = GetReadmes(repos, [32]byte{})
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Empty(t, github.calls)
+	})
+
+	t.Run("RateLimitReached", func(t *testing.T) {
+		cacheFile = filepath.Join(t.TempDir(), "stars.json")
+		github := &mockReadmeGithub{readmes: readmeTexts, rateLimit: 2}
+		ghClient = github
+		got, err := GetReadmes(repos, [32]byte{})
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+
+		// The next run only fetches the READMEs that are missing
+		github = &mockReadmeGithub{readmes: readmeTexts}
+		ghClient = github
+		got, err = GetReadmes(repos, [32]byte{})
+		assert.NoError(t, err)
+		assert.Len(t, got, 5)
+		assert.Len(t, github.calls, 3)
+	})
+
+	t.Run("AboveTheLimit", func(t *testing.T) {
+		cacheFile = filepath.Join(t.TempDir(), "stars.json")
+		readmeLimit = 2
+		defer func() { readmeLimit = DEFAULT_README_LIMIT }()
+		repos := []Repo{
+			{Full_name: "someone/empty"},
+			{Full_name: "someone/short", Description: "A tiny lib"},
+			{Full_name: "someone/long", Description: "A long description of what the repo does"},
+		}
+		github := &mockReadmeGithub{readmes: map[string]string{"someone/short": "# Short"}}
+		ghClient = github
+		got, err := GetReadmes(repos, [32]byte{})
+		assert.NoError(t, err)
+		// Only the repos with a short description are fetched
+		assert.Equal(t, map[string]string{"someone/empty": "", "someone/short": "# Short"}, got)
+		assert.ElementsMatch(t, []string{"someone/empty", "someone/short"}, github.calls)
+	})
+
+	t.Run("CorruptedCache", func(t *testing.T) {
+		cacheFile = filepath.Join(t.TempDir(), "stars.json")
+		assert.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(cacheFile), "stars.readme.json"), []byte("corrupted"), 0644))
+		ghClient = &mockReadmeGithub{readmes: readmeTexts}
+		_, err := GetReadmes(repos, [32]byte{})
+		assert.ErrorContains(t, err, "not able to read the README cache")
+	})
+}
+
+func TestFetchReadme(t *testing.T) {
+	setup([]string{})
+	defer func() { ghClient = &MockGithub{} }()
+	ghClient = &mockReadmeGithub{readmes: map[string]string{"someone/long": strings.Repeat("é", README_MAX_LENGTH)}}
+
+	readme, err := fetchReadme("someone/long")
+	assert.NoError(t, err)
+	// Truncated without splitting a character
+	assert.Len(t, readme, README_MAX_LENGTH)
+	assert.True(t, strings.HasSuffix(readme, "é"))
+
+	readme, err = fetchReadme("someone/missing")
+	assert.NoError(t, err)
+	assert.Empty(t, readme)
+
+	// The rate limit is reached after a first call
+	ghClient = &mockReadmeGithub{rateLimit: 1, calls: []string{"someone/other"}}
+	_, err = fetchReadme("someone/long")
+	assert.ErrorIs(t, err, errRateLimited)
+}
+
+func TestSearchReadme(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/5_repos.json")
+	readmes = map[string]string{
+		"karpathy/nanoGPT":      "# nanoGPT\nThe simplest repository for training transformers with PyTorch.",
+		"lithammer/fuzzysearch": "# Fuzzy Search\nUses the Levenshtein distance, like transformers do not.",
+	}
+	defer func() {
+		readmes = nil
+		weights = map[string]int{}
+	}()
+
+	// Only the READMEs mention the term, with the lowest score
+	found, err := Search(testData, "pytorch")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, found.Len())
+	item := heap.Pop(&found).(*pq.Item)
+	assert.Equal(t, "karpathy/nanoGPT", item.Value.(Result).Full_name)
+	assert.Equal(t, README_SCORE, item.Priority)
+	assert.Equal(t, []Match{{Field: "readme", Needle: "pytorch", Word: "PyTorch", Start: 65, End: 72, Score: README_SCORE}}, item.Value.(Result).Matches)
+
+	// A README match adds to the matches in the other fields
+	found, err = Search(testData, "fuzzy levenshtein")
+	assert.NoError(t, err)
+	item = heap.Pop(&found).(*pq.Item)
+	assert.Equal(t, "lithammer/fuzzysearch", item.Value.(Result).Full_name)
+	assert.Equal(t, []string{"description:fuzzy", "readme:Levenshtein"}, matchStrings(item.Value.(Result).Matches))
+
+	// Exclusions look at the README too
+	found, err = Search(testData, "pytorch -readme:transformers")
+	assert.NoError(t, err)
+	assert.Zero(t, found.Len())
+
+	// The README is weighed like any other field
+	weights = map[string]int{"readme": 0}
+	found, err = Search(testData, "pytorch")
+	assert.NoError(t, err)
+	assert.Zero(t, found.Len())
+}
+
+// matchStrings returns the matches as they are rendered in the Matched column
+func matchStrings(matches []Match) []string {
+	var matched []string
+	for _, match := range matches {
+		matched = append(matched, match.String())
+	}
+	return matched
+}
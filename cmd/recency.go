@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"container/heap"
+	"math"
+	"time"
+
+	"github.com/Link-/gh-stars/lib/pq"
+)
+
+const (
+	STALE_YEARS  = 5   // Years without a push after which a repo gets the lowest recency factor, see recencyFactor
+	STALE_FACTOR = 0.5 // Recency factor of the repos not pushed to for STALE_YEARS or more
+)
+
+// recencyFactor returns what the rank of a repo last pushed to at pushedAt is multiplied
+// by with --boost-recent. A repo pushed to within the last year keeps its rank, then the
+// factor decreases by the same step for every full year without a push, down to
+// STALE_FACTOR after STALE_YEARS. Repos of the same age get the same factor, and a repo
+// without a push date keeps its rank.
+func recencyFactor(pushedAt time.Time, now time.Time) float64 {
+	if pushedAt.IsZero() || !pushedAt.Before(now) {
+		return 1
+	}
+	years := int(now.Sub(pushedAt).Hours() / (24 * 365.25))
+	if years > STALE_YEARS {
+		years = STALE_YEARS
+	}
+	return 1 - (1-STALE_FACTOR)*float64(years)/STALE_YEARS
+}
+
+// BoostRecent multiplies the rank of every result by the recency factor of its repo, see
+// recencyFactor, so the repos still maintained come before the abandoned ones matching
+// as well
+func BoostRecent(results pq.PriorityQueue, now time.Time) pq.PriorityQueue {
+	var boosted = make(pq.PriorityQueue, 0, results.Len())
+	for _, item := range results {
+		result := item.Value.(Result)
+		factor := recencyFactor(result.PushedAt(), now)
+		if result.Explanation != nil {
+			result.Explanation.Recency = factor
+		}
+		boosted = append(boosted, &pq.Item{
+			Value:    result,
+			Priority: int(math.Round(float64(item.Priority) * factor)),
+		})
+	}
+	heap.Init(&boosted)
+	return boosted
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+
+	"github.com/Link-/gh-stars/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecencyFactor(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		pushedAt time.Time
+		want     float64
+	}{
+		{name: "NoPushDate", pushedAt: time.Time{}, want: 1},
+		{name: "PushedToday", pushedAt: now, want: 1},
+		{name: "InTheFuture", pushedAt: now.AddDate(0, 1, 0), want: 1},
+		{name: "WithinAYear", pushedAt: now.AddDate(0, -11, 0), want: 1},
+		{name: "OneYear", pushedAt: now.AddDate(-1, 0, -1), want: 0.9},
+		{name: "ThreeYears", pushedAt: now.AddDate(-3, -6, 0), want: 0.7},
+		{name: "FiveYears", pushedAt: now.AddDate(-5, 0, -1), want: STALE_FACTOR},
+		{name: "TenYears", pushedAt: now.AddDate(-10, 0, 0), want: STALE_FACTOR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, recencyFactor(tt.pushedAt, now), 1e-9)
+		})
+	}
+}
+
+func TestBoostRecent(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	repo := func(name string, pushedAt time.Time, stars int) Repo {
+		return Repo{Full_name: name, Pushed_at: pushedAt.Format(time.RFC3339), Stars: stars}
+	}
+	type ranked struct {
+		repo Repo
+		rank int
+	}
+	queue := func(repos ...ranked) pq.PriorityQueue {
+		var results = make(pq.PriorityQueue, 0)
+		heap.Init(&results)
+		for _, r := range repos {
+			heap.Push(&results, &pq.Item{Value: Result{Repo: r.repo, Explanation: &Explanation{}}, Priority: r.rank})
+		}
+		return results
+	}
+	pop := func(results pq.PriorityQueue) ([]string, []int) {
+		names, ranks := []string{}, []int{}
+		for results.Len() > 0 {
+			item := heap.Pop(&results).(*pq.Item)
+			names = append(names, item.Value.(Result).Full_name)
+			ranks = append(ranks, item.Priority)
+		}
+		return names, ranks
+	}
+
+	// The abandoned repo has more stars, it comes first without the boost
+	maintained := repo("acme/maintained", now.AddDate(0, -2, 0), 10)
+	abandoned := repo("acme/abandoned", now.AddDate(-6, 0, 0), 1000)
+	names, ranks := pop(BoostRecent(queue(ranked{maintained, 1000}, ranked{abandoned, 1000}), now))
+	assert.Equal(t, []string{"acme/maintained", "acme/abandoned"}, names)
+	assert.Equal(t, []int{1000, 500}, ranks)
+
+	// Repos of similar ages keep their order
+	older := repo("acme/older", now.AddDate(-2, -1, 0), 10)
+	newer := repo("acme/newer", now.AddDate(-2, -10, 0), 10)
+	names, ranks = pop(BoostRecent(queue(ranked{older, 1000}, ranked{newer, 990}), now))
+	assert.Equal(t, []string{"acme/older", "acme/newer"}, names)
+	assert.Equal(t, []int{800, 792}, ranks)
+
+	// The factor is part of the explanation
+	boosted := BoostRecent(queue(ranked{abandoned, 1000}), now)
+	assert.Equal(t, STALE_FACTOR, boosted[0].Value.(Result).Explanation.Recency)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package cmd
+
+import "os"
+
+// renameFile renames a file, replacing the one at the new path. A file open for reading is
+// replaced all the same, the readers keep reading the previous one.
+func renameFile(from, to string) error {
+	return os.Rename(from, to)
+}
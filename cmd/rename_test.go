@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameFile(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "stars.json.123.tmp"), filepath.Join(dir, "stars.json")
+
+	// Over a file that doesn't exist
+	assert.NoError(t, os.WriteFile(from, []byte("[1]"), 0644))
+	assert.NoError(t, renameFile(from, to))
+	assert.NoFileExists(t, from)
+
+	// Over an existing file
+	assert.NoError(t, os.WriteFile(from, []byte("[2]"), 0644))
+	assert.NoError(t, renameFile(from, to))
+	data, err := os.ReadFile(to)
+	assert.NoError(t, err)
+	assert.Equal(t, "[2]", string(data))
+
+	// Over a file open for reading, like a search reading the cache file, which lets go of it
+	reader, err := os.Open(to)
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		reader.Close()
+	}()
+	assert.NoError(t, os.WriteFile(from, []byte("[3]"), 0644))
+	assert.NoError(t, renameFile(from, to))
+	data, err = os.ReadFile(to)
+	assert.NoError(t, err)
+	assert.Equal(t, "[3]", string(data))
+}
+
+func TestWriteCacheFileOverOpenFile(t *testing.T) {
+	setup([]string{})
+	path := filepath.Join(t.TempDir(), "cache", "stars_2d06a89b2687.json.gz")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, writeCacheFile(path, []byte("[1]")))
+
+	reader, err := os.Open(path)
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		reader.Close()
+	}()
+	assert.NoError(t, writeCacheFile(path, []byte("[2]")))
+	data, err := readCacheFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "[2]", string(data))
+	// The temporary file is gone
+	assert.Equal(t, []string{"stars_2d06a89b2687.json.gz"}, cacheFileNames(t, filepath.Dir(path)))
+}
+
+func TestMoveFile(t *testing.T) {
+	legacyDir, dir := t.TempDir(), filepath.Join(t.TempDir(), "gh-stars")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	from, to := filepath.Join(legacyDir, "stars_2d06a89b2687.json"), filepath.Join(dir, "stars_link-_2d06a89b2687.json")
+	written := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.WriteFile(from, []byte("[]"), 0644))
+	assert.NoError(t, os.Chtimes(from, written, written))
+
+	assert.NoError(t, moveFile(from, to))
+	assert.NoFileExists(t, from)
+	stat, err := os.Stat(to)
+	assert.NoError(t, err)
+	assert.True(t, stat.ModTime().Equal(written), "the cache file keeps its age")
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// renameFile renames a file, replacing the one at the new path. Windows doesn't replace a
+// file another process has open, like a search reading the cache file, nor one an antivirus
+// scans: the rename is retried until they let go of it, for a few seconds at most.
+func renameFile(from, to string) error {
+	delay := 10 * time.Millisecond
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err := os.Rename(from, to)
+		if err == nil || !isSharingError(err) || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(delay)
+		if delay < 200*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+// isSharingError reports whether the file couldn't be replaced because another process
+// has it open
+func isSharingError(err error) bool {
+	return errors.Is(err, windows.ERROR_ACCESS_DENIED) || errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
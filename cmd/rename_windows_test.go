@@ -0,0 +1,47 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameFileSharingViolation(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "stars.json.123.tmp"), filepath.Join(dir, "stars.json")
+	assert.NoError(t, os.WriteFile(from, []byte("[1]"), 0644))
+	assert.NoError(t, os.WriteFile(to, []byte("[]"), 0644))
+
+	// A file that is never let go of isn't replaced, the error tells why
+	reader, err := os.Open(to)
+	assert.NoError(t, err)
+	defer reader.Close()
+	err = renameFile(from, to)
+	assert.Error(t, err)
+	assert.True(t, isSharingError(err), err)
+	assert.FileExists(t, from)
+}
+
+func TestWindowsPaths(t *testing.T) {
+	home, temp := t.TempDir(), t.TempDir()
+	setHomeDir(t, home)
+	setTempDir(t, temp)
+
+	// %TEMP% holds the cache files of older versions
+	assert.Equal(t, temp, legacyCacheDir())
+
+	// ~\ is the home directory too
+	path, err := expandHome(`~\.cache\stars.json`)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".cache", "stars.json"), path)
+
+	// The user cache directory is %LocalAppData%
+	t.Setenv(CACHE_DIR_ENV, "")
+	dir, err := cacheDir()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "AppData", "Local", "gh-stars"), dir)
+}
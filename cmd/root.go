@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"container/heap"
 	"crypto/sha256"
@@ -10,20 +11,56 @@ import (
 	"io"
 	"log"
 	"math"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/Link-/gh-stars/lib/damerau"
 	"github.com/Link-/gh-stars/lib/pq"
 	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/auth"
 	"github.com/cli/go-gh/pkg/tableprinter"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/spf13/cobra"
+	"golang.org/x/text/unicode/norm"
 )
 
 const VERSION = "0.1.1"
-const MAX_FUZZY_DISTANCE = 2 // Maximum Levenshtein distance for fuzzy search. Higher values are more permissive
+const DEFAULT_FUZZY_DISTANCE = 2 // Default Damerau-Levenshtein distance for fuzzy search. Higher values are more permissive
+const MAX_FUZZY_DISTANCE = 5     // Maximum Damerau-Levenshtein distance accepted by --fuzzy-distance
+const DEFAULT_FUZZY_RATIO = 0.3  // Default edit distance relative to the length of the longest word. Short words are effectively exact
+const SHORT_NEEDLE_LENGTH = 3    // Search terms this short are matched exactly, fuzzy matching them mostly returns noise
+const MIN_STEM_LENGTH = 3        // Suffixes are only stripped when at least this many characters remain, see stem
+
+// Score of an exact match in each field. Fuzzy matches score proportionally less, see rankScore
+const (
+	NAME_SCORE        = 1000
+	OWNER_SCORE       = 525
+	DESCRIPTION_SCORE = 250
+	TOPIC_SCORE       = 25
+)
+
+const WEIGHT_SCORE = TOPIC_SCORE // Score of a field with a weight of 1, see --weights. The default weights are name=40, owner=21, description=10, topics=1, homepage=1, readme=1
+
+const HOMEPAGE_SCORE = WEIGHT_SCORE // Score of an exact match in the homepage URL, which often repeats the name
+
+const PROXIMITY_SCORE = 200 // Bonus when the search terms are next to each other in the description, see proximityBonus
+
+const PHRASE_RATIO = 1.2 // Score of a phrase found in the description relative to an exact match on one of its words, see phraseMatch
+
+const DEFAULT_CACHE_TTL = 24 * time.Hour // Age of the cache file above which the repos are fetched again, see --cache-ttl
+
+const DEFAULT_CACHE_MAX_AGE = 30 * 24 * time.Hour // Age of the previous cache files of a user above which they are deleted, see --cache-max-age
+
+const EXACT_TOPIC_SCORE = 800 // Score of a topic equal to the search term, above the description and close to the name, see topicScore
 
 type Repo struct {
 	Name      string `json:"name"`
@@ -36,8 +73,252 @@ type Repo struct {
 	}
 	Description string   `json:"description"`
 	Fork        bool     `json:"fork"`
+	Archived    bool     `json:"archived"`             // Read-only, the owner stopped maintaining the repo
+	Is_template bool     `json:"is_template"`          // Template to generate new repos from
+	Mirror_url  string   `json:"mirror_url,omitempty"` // URL of the repo mirrored, null for the repos that aren't mirrors
+	Mirror      bool     `json:"mirror"`               // Set from Mirror_url by DecodeRepos, for the JSON output
 	Stars       int      `json:"stargazers_count"`
+	Open_issues int      `json:"open_issues_count"` // Open issues and pull requests, GitHub counts both
 	Topics      []string `json:"topics"`
+	Homepage    string   `json:"homepage"`
+	Pushed_at   string   `json:"pushed_at,omitempty"`
+	Starred_at  string   `json:"starred_at,omitempty"` // When the user starred the repo, only for --source stars, see unwrapStars
+	Language    string   `json:"language,omitempty"`   // Main language detected by GitHub, null for the repos without code
+	License     *License `json:"license,omitempty"`    // License detected by GitHub, null for the repos without one
+}
+
+// A License is the license of a repo detected by GitHub
+type License struct {
+	Spdx_id string `json:"spdx_id"` // SPDX identifier, e.g. MIT, or NOASSERTION for a license GitHub doesn't know
+	Name    string `json:"name"`
+}
+
+// SpdxId returns the SPDX identifier of the license of the repo, empty without a license
+func (r Repo) SpdxId() string {
+	if r.License == nil {
+		return ""
+	}
+	return r.License.Spdx_id
+}
+
+// PushedAt returns the date of the last push to the repo, the zero time when it is
+// missing from the cache or not a valid date
+func (r Repo) PushedAt() time.Time {
+	pushedAt, err := time.Parse(time.RFC3339, r.Pushed_at)
+	if err != nil {
+		return time.Time{}
+	}
+	return pushedAt
+}
+
+// StarredAt returns when the user starred the repo, the zero time when it is missing from
+// the cache or not a valid date
+func (r Repo) StarredAt() time.Time {
+	starredAt, err := time.Parse(time.RFC3339, r.Starred_at)
+	if err != nil {
+		return time.Time{}
+	}
+	return starredAt
+}
+
+// A Word is a word of a text and its position among the words of the text
+type Word struct {
+	Text     string
+	Position int
+	Offset   int // Byte offset of the word in the text
+}
+
+// tokenize splits the text into words on whitespace, keeping track of their position
+func tokenize(text string) []Word {
+	words := []Word{}
+	start := -1
+	for i, r := range text {
+		if !unicode.IsSpace(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, Word{Text: text[start:i], Position: len(words), Offset: start})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, Word{Text: text[start:], Position: len(words), Offset: start})
+	}
+	return words
+}
+
+// A document is a repo prepared for matching. Its name and description are split
+// into words once, instead of once per search term, and every word is normalized once
+// so the needles are compared to it as is, see matchNormalized.
+type document struct {
+	Repo
+	nameWords        []string
+	nameOffsets      []int    // Byte offset of every word in the name, see splitNameOffsets
+	ownerWords       []string // The owner login and the full repository path
+	descriptionWords []Word
+	descriptionText  string // Normalized description with single spaces, see phraseMatch
+	homepageWords    []string
+	readmeWords      []string // Distinct words of the README, see --include-readme
+
+	// The normalized words of each field, in the same order as the words above
+	normalizedName        []string
+	normalizedOwner       []string
+	normalizedDescription []string
+	normalizedTopics      []string
+	normalizedHomepage    []string
+	normalizedReadme      []string
+}
+
+func newDocument(repo Repo) *document {
+	doc := &document{
+		Repo:             repo,
+		ownerWords:       []string{repo.Owner.Login, repo.Full_name},
+		descriptionWords: tokenize(repo.Description),
+		descriptionText:  strings.Join(strings.Fields(normalize(repo.Description)), " "),
+		homepageWords:    splitHomepage(repo.Homepage),
+		readmeWords:      splitReadme(readmes[repo.Full_name]),
+		normalizedTopics: normalizeWords(repo.Topics),
+	}
+	doc.nameWords, doc.nameOffsets = splitNameOffsets(repo.Name)
+	doc.normalizedName = normalizeWords(doc.nameWords)
+	doc.normalizedOwner = normalizeWords(doc.ownerWords)
+	doc.normalizedDescription = make([]string, len(doc.descriptionWords))
+	for i, word := range doc.descriptionWords {
+		doc.normalizedDescription[i] = normalize(word.Text)
+	}
+	doc.normalizedHomepage = normalizeWords(doc.homepageWords)
+	doc.normalizedReadme = normalizeWords(doc.readmeWords)
+	return doc
+}
+
+// normalizeWords returns the words normalized, see normalize
+func normalizeWords(words []string) []string {
+	normalized := make([]string, len(words))
+	for i, word := range words {
+		normalized[i] = normalize(word)
+	}
+	return normalized
+}
+
+// Before breaks ties between repos with the same rank: the most starred repo comes
+// first, then the repos are sorted by full name for a deterministic order
+func (r Repo) Before(other any) bool {
+	o, ok := other.(Repo)
+	if !ok {
+		return false
+	}
+	if r.Stars != o.Stars {
+		return r.Stars > o.Stars
+	}
+	return r.Full_name < o.Full_name
+}
+
+// A Match is a word of a repo field that matched a search term
+type Match struct {
+	Field    string  `json:"field"`           // name, owner, description, topics, homepage or readme
+	Needle   string  `json:"needle"`          // The search term
+	Word     string  `json:"word"`            // The word of the field that matched the search term
+	Alias    string  `json:"alias,omitempty"` // The alias of the search term that matched the word, see --aliases
+	Distance int     `json:"distance"`        // Number of edits between the search term and the word
+	Start    int     `json:"start"`           // Byte offset of the word in the field, in the topic for topics
+	End      int     `json:"end"`             // Byte offset right after the word, field[Start:End] is the word
+	IDF      float64 `json:"-"`               // Weight of the word among the descriptions, see Frequencies
+	Score    int     `json:"-"`
+}
+
+func (m Match) String() string {
+	if m.Alias != "" {
+		return fmt.Sprintf("%s:%s (%s as %s)", m.Field, m.Word, m.Needle, m.Alias)
+	}
+	if normalize(m.Word) == normalize(m.Needle) {
+		return fmt.Sprintf("%s:%s", m.Field, m.Word)
+	}
+	return fmt.Sprintf("%s:%s (%s)", m.Field, m.Word, m.Needle)
+}
+
+// A Result is a repo returned by a search, along with the best match of every search term.
+// Listing every starred repo returns results without matches.
+type Result struct {
+	Repo
+	Matches     []Match      `json:"matched,omitempty"`
+	Explanation *Explanation `json:"explain,omitempty"` // Only set with --explain
+	Forks       []Result     `json:"forks,omitempty"`   // Forks of the repo found as well, see CollapseForks
+	rank        int          // Rank of a collapsed fork, the rank of the other results is their priority
+}
+
+// An Explanation details how the rank of a result was computed, see --explain
+type Explanation struct {
+	Matches   []Contribution `json:"matches"`
+	Proximity int            `json:"proximity"`         // Bonus for the search terms next to each other in the description
+	Recency   float64        `json:"recency,omitempty"` // Factor the rank was multiplied by, see --boost-recent
+}
+
+// A Contribution is a match found while ranking a result. Only the best match of every
+// search term adds its score to the rank, the other matches add 0.
+type Contribution struct {
+	Field    string  `json:"field"`
+	Needle   string  `json:"needle"`
+	Word     string  `json:"word"`
+	Alias    string  `json:"alias,omitempty"`
+	Distance int     `json:"distance"`      // Number of edits between the search term and the word
+	Weight   int     `json:"weight"`        // Weight of the field, see --weights
+	IDF      float64 `json:"idf,omitempty"` // Weight of the word among the descriptions, see Frequencies
+	Score    int     `json:"score"`
+	Added    int     `json:"added"` // What the match added to the rank
+}
+
+// add records the matches of a search term, best being the one added to the rank
+func (e *Explanation) add(matches []Match, best Match) {
+	added := false
+	for _, match := range matches {
+		contribution := Contribution{
+			Field:    match.Field,
+			Needle:   match.Needle,
+			Word:     match.Word,
+			Alias:    match.Alias,
+			Distance: match.Distance,
+			Weight:   fieldScores[match.Field] / WEIGHT_SCORE,
+			IDF:      math.Round(match.IDF*100) / 100,
+			Score:    match.Score,
+		}
+		if !added && match == best {
+			contribution.Added = match.Score
+			added = true
+		}
+		e.Matches = append(e.Matches, contribution)
+	}
+}
+
+// Lines returns the explanation as text, one line per match
+func (e *Explanation) Lines() []string {
+	var lines []string
+	for _, c := range e.Matches {
+		match := Match{Field: c.Field, Needle: c.Needle, Word: c.Word, Alias: c.Alias}
+		idf := ""
+		if c.IDF > 0 {
+			idf = fmt.Sprintf(", idf %.2f", c.IDF)
+		}
+		lines = append(lines, fmt.Sprintf("  %s distance %d, weight %d%s, score %d, adds %d", match, c.Distance, c.Weight, idf, c.Score, c.Added))
+	}
+	if e.Proximity > 0 {
+		lines = append(lines, fmt.Sprintf("  proximity bonus adds %d", e.Proximity))
+	}
+	if e.Recency > 0 {
+		lines = append(lines, fmt.Sprintf("  recency multiplies the rank by %.2f", e.Recency))
+	}
+	return lines
+}
+
+// Before orders the results with the same rank like their repos, see Repo.Before
+func (r Result) Before(other any) bool {
+	o, ok := other.(Result)
+	if !ok {
+		return false
+	}
+	return r.Repo.Before(o.Repo)
 }
 
 type githubInterface interface {
@@ -52,20 +333,130 @@ func (g *github) Exec(args ...string) (bytes.Buffer, bytes.Buffer, error) {
 
 var (
 	user          string
-	find          string
+	finds         []string
 	cacheFile     string
+	noCache       bool
+	cacheReadOnly bool
+	refresh       bool
+	cacheTTL      time.Duration
+	staleOK       bool
+	cacheMaxAge   time.Duration
+	cacheMaxSize  sizeFlag
 	limit         int
 	tableMaxWidth int
 	version       bool
 	jsonOutput    bool
+	matchAll      bool
+	searchIn      []string
+	fuzzyDistance int
+	fuzzyRatio    float64
+	absoluteDist  bool
+	adaptiveDist  = true // Unset by --fuzzy-ratio, see adaptiveDistance
+	prefixMatch   bool
+	minRank       int
+	explain       bool
+	aliasesFile   string
+	weights       map[string]int
+	includeReadme bool
+	readmeLimit   int
+	showHomepage  bool
+	showLicense   bool
+	interactive   bool
+	gists         bool
+	source        string
+	starListName  string
+	ignoreFile    string
+	inputFile     string
+	excludes      []string
+	filterText    string
+	onlyOwners    []string
+	owners        []string // Same as onlyOwners, see --owner
+	excludeOwners []string
+	languages     []string
+	minStars      countFlag
+	maxStars      countFlag
+	minOpenIssues countFlag
+	maxOpenIssues countFlag
+	noArchived    bool
+	archivedOnly  bool
+	allTopics     []string
+	anyTopics     []string
+	pushedAfter   dateFlag
+	pushedBefore  dateFlag
+	starredAfter  dateFlag
+	starredBefore dateFlag
+	licenses      []string
+	visibility    string
+	hasTopics     bool
+	hasDesc       bool
+	noForks       bool
+	noTemplates   bool
+	noMirrors     bool
+	forksOnly     bool
+	noResultCache bool
+	perOwner      int
+	showForks     bool
+	boostRecent   bool
+	randomPick    bool
+	raw           bool
+	seed          int64
 	debug         bool
-
-	ghClient    githubInterface
-	client      *http.Client
+	quiet         bool
+
+	// listAll is set when no search term is provided, every starred repo is rendered
+	listAll bool
+	// fieldScores is the score of an exact match in each field, see --weights
+	fieldScores = defaultFieldScores()
+	// aliases are the other terms searched for a term, see --aliases
+	aliases map[string][]string
+	// indexPath is the path of the search index of the cache, see Index
+	indexPath string
+	// frequencies weigh the description matches by how rare the word is, see Frequencies
+	frequencies Frequencies
+	// readmes are the READMEs of the starred repos by full name, see --include-readme
+	readmes map[string]string
+	// starList is the full names of the repos in the star list, see --list
+	starList map[string]bool
+	// ignorePatterns are the repos never returned, see --ignore-file
+	ignorePatterns []string
+
+	ghClient githubInterface
+	client   *http.Client
+	// host is the GitHub host gh is logged in to, or GH_HOST, see apiURL
+	host        string
 	InfoLogger  *log.Logger
+	WarnLogger  *log.Logger
 	ErrorLogger *log.Logger
 )
 
+// A repoSource is a list of repositories of the user that can be searched, see --source
+type repoSource struct {
+	endpoint string // API endpoint listing the repositories, relative to users/<handle>
+	accept   string // Media type of the list, when it isn't the default one
+	empty    string // Hint when the user has no repositories in the list
+	plural   string // The repositories of the list in the hints
+}
+
+// Lists of repositories that can be searched, see the --source flag
+var sources = map[string]repoSource{
+	"stars":    {endpoint: "starred", accept: STAR_MEDIA_TYPE, empty: "%s has not starred any repository", plural: "starred repositories"},
+	"watching": {endpoint: "subscriptions", empty: "%s is not watching any repository", plural: "watched repositories"},
+	"owned":    {endpoint: "repos", empty: "%s doesn't own any repository", plural: "owned repositories"},
+}
+
+// sourceNames returns the names of the sources in alphabetical order
+func sourceNames() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fields that can be searched, see the --in flag
+var searchableFields = []string{"name", "owner", "description", "topics", "homepage", "readme"}
+
 var rootCmd = &cobra.Command{
 	Use:   "gh stars",
 	Short: "gh stars: Search starred repositories on GitHub",
@@ -79,9 +470,20 @@ var rootCmd = &cobra.Command{
 			logWriter = os.Stdout
 		}
 		InfoLogger = log.New(logWriter, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+		// Notices are meant for the user, push them to stderr unless quiet mode is enabled
+		warnWriter := io.Writer(os.Stderr)
+		if quiet {
+			warnWriter = io.Discard
+		}
+		WarnLogger = log.New(warnWriter, "WARN: ", 0)
 		ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
-		// Initialize the HTTP client
+		// Initialize the HTTP client, authenticated as the user gh is logged in as when it is so
+		// the repos are fetched within their rate limit
 		client = &http.Client{}
+		if authenticated, err := gh.HTTPClient(nil); err == nil {
+			client = authenticated
+		}
+		host, _ = auth.DefaultHost()
 		// Initialize the GitHub client
 		ghClient = &github{}
 	},
@@ -92,32 +494,302 @@ var rootCmd = &cobra.Command{
 		}
 		InfoLogger.Println("Debug mode is enabled")
 		InfoLogger.Println("Parameters provided ", strings.Join(os.Args[1:], " "))
-		if user == "" || find == "" {
-			ErrorLogger.Fatal("The --user, -u and --find, -f flags are required. See --help for more information")
+		// Search the stars of the authenticated user by default, the repos of --input are
+		// nobody's in particular
+		if user == "" && inputFile == "" {
+			login, err := AuthenticatedUser()
+			if err != nil {
+				InfoLogger.Println("Not able to get the authenticated user", err)
+				ErrorLogger.Fatal("The --user, -u flag is required when gh is not logged in, run gh auth login to search your own stars without it. See --help for more information")
+			}
+			user = login
+			InfoLogger.Println("No --user provided, using the authenticated user:", user)
+		}
+		// Empty queries are ignored, there is nothing to match against
+		var queries []string
+		for _, query := range finds {
+			if strings.TrimSpace(query) != "" {
+				queries = append(queries, query)
+			}
+		}
+		// With --find -, the queries are read from stdin and searched one by one
+		batch := false
+		for _, query := range queries {
+			if query == "-" {
+				batch = true
+			}
+		}
+		if batch {
+			if len(queries) > 1 {
+				ErrorLogger.Fatal("--find - reads every query from stdin, it can't be combined with other --find queries")
+			}
+			if interactive {
+				ErrorLogger.Fatal("--find - reads the queries from stdin, it can't be combined with --interactive")
+			}
+			var err error
+			if queries, err = ReadQueries(os.Stdin); err != nil {
+				ErrorLogger.Fatal("Not able to read the queries from stdin", err)
+			}
+			if len(queries) == 0 {
+				ErrorLogger.Fatal("No query was read from stdin, provide one query per line")
+			}
+		}
+		listAll = len(queries) == 0
+		// Asking for a specific distance only makes sense with absolute distances
+		if cmd.Flags().Changed("fuzzy-distance") {
+			absoluteDist = true
+		}
+		// A ratio replaces the default tolerance based on the length of the search terms
+		if cmd.Flags().Changed("fuzzy-ratio") {
+			adaptiveDist = false
+		}
+		if err := validateSearchOptions(); err != nil {
+			ErrorLogger.Fatal(err)
+		}
+		for _, query := range queries {
+			if _, err := NewQuery(query); err != nil {
+				ErrorLogger.Fatal(err)
+			}
+		}
+		// A single result is picked among the results of a single search
+		if randomPick && (interactive || batch) {
+			ErrorLogger.Fatal("--random picks one of the results of a search, it can't be combined with --interactive or --find -")
 		}
+		if cmd.Flags().Changed("seed") {
+			if !randomPick {
+				ErrorLogger.Fatal("--seed only applies to --random")
+			}
+		} else {
+			seed = time.Now().UnixNano()
+		}
+		// --raw prints every repo as it was fetched, there is nothing to search nor to render
+		if raw && (!listAll || interactive || gists || randomPick) {
+			ErrorLogger.Fatal("--raw prints every repo as it was fetched, it can't be combined with --find, --interactive, --gists or --random")
+		}
+		// Nothing is fetched with --input, the repos are read from the file
+		if inputFile != "" && (refresh || gists || starListName != "" || includeReadme) {
+			ErrorLogger.Fatal("--input reads the repos from a file, it can't be combined with --refresh, --gists, --list or --include-readme")
+		}
+		// The gists are searched on their own, without the repos
+		if gists {
+			if interactive || batch || randomPick || cmd.Flags().Changed("source") || starListName != "" {
+				ErrorLogger.Fatal("--gists can't be combined with --interactive, --find -, --random, --source or --list")
+			}
+			runGists(queries)
+			return
+		}
+		// Fail before any network call when the picker can't be shown
+		if interactive {
+			if len(queries) > 1 {
+				ErrorLogger.Fatal("--interactive starts with a single query, --find can't be repeated")
+			}
+			if err := checkInteractive(); err != nil {
+				ErrorLogger.Fatal(err)
+			}
+		}
+
+		// Read the repos of --input, without any cache key nor network call
+		var key [32]byte
+		var starred bytes.Buffer
+		var err error
+		if inputFile != "" {
+			if starred, err = ReadInputFile(inputFile); err != nil {
+				ErrorLogger.Fatal(fmt.Sprintf("Not able to read the repos of --input %s: ", inputFile), err)
+			}
+			InfoLogger.Println("Reading the repos from the input file:", inputFile)
+		} else {
+			// Generate the cache key from the Link header
+			if key, err = GenerateCacheKey(user); err != nil {
+				ErrorLogger.Fatal("Not able to generate a cache key", err)
+			}
 
-		// Generate the cache key from the Link header
-		key, err := GenerateCacheKey(user)
+			// Pull the starred repos from the cache or from the API if the cache is empty, always
+			// from the API with --refresh
+			if refresh {
+				starred, err = RefetchStarredRepos(user, key)
+			} else {
+				starred, err = GetStarredRepos(user, key)
+			}
+			if err != nil {
+				ErrorLogger.Fatal("Not able to get starred repos", err)
+			}
+		}
+		// Print the repos as they were fetched or cached, every field of them, without decoding
+		if raw {
+			if err := RenderRaw(starred, os.Stdout); err != nil {
+				fatalAfterRefresh("Not able to print the repos", err)
+			}
+			return
+		}
+		// The repos are decoded once, every search below runs against them
+		repos, err := DecodeRepos(starred)
 		if err != nil {
-			ErrorLogger.Fatal("Not able to generate a cache key", err)
+			fatalAfterRefresh("Not able to read starred repos", err)
 		}
-
-		// Pull the starred repos from the cache or from the API if the cache is empty
-		starred, err := GetStarredRepos(user, key)
+		// The caches written before the star dates were fetched have none, fetch them now
+		if (starredAfter.set() || starredBefore.set()) && !hasStarDates(repos) && inputFile == "" {
+			InfoLogger.Println("The cache has no star dates, fetching the starred repos again")
+			if starred, err = RefetchStarredRepos(user, key); err != nil {
+				fatalAfterRefresh("Not able to get starred repos", err)
+			}
+			if repos, err = DecodeRepos(starred); err != nil {
+				fatalAfterRefresh("Not able to read starred repos", err)
+			}
+		}
+		// Drop the repos of the ignore file, see activeFilters
+		ignorePatterns, err = loadIgnorePatterns(ignoreFile)
 		if err != nil {
-			ErrorLogger.Fatal("Not able to get starred repos", err)
+			fatalAfterRefresh("Not able to read the ignore file", err)
+		}
+		// Only keep the repos of the star list, see activeFilters
+		if starListName != "" {
+			starList, err = readStarList(starListName, key)
+			if err != nil {
+				fatalAfterRefresh("Not able to read the star list", err)
+			}
+		}
+		// The search index is optional, the repos are all scanned without it
+		if inputFile == "" {
+			indexPath, err = GetIndexPath(key)
+			if err != nil {
+				InfoLogger.Println("Not able to locate the search index", err)
+			}
+		}
+		// The READMEs are optional as well, the other fields are searched without them
+		if includeReadme && (!listAll || interactive) {
+			readmes, err = GetReadmes(repos, key)
+			if err != nil {
+				WarnLogger.Println("Not able to get the READMEs, searching without them:", err)
+			}
+		}
+		// Searches stop early when only the results shown are needed, after the filters are read
+		if !batch && !interactive && !listAll {
+			if searchLimit = earlyExitLimit(repos); searchLimit > 0 {
+				InfoLogger.Printf("Only keeping the %d best results of the search", searchLimit)
+			}
 		}
 
-		// Fuzzy and ranked searched for the search term(s)
-		found, err := Search(starred, find)
-		if err != nil {
-			ErrorLogger.Fatal("Not able to search starred repos", err)
+		if interactive {
+			// A single query at most, checked along with the flags
+			initialQuery := ""
+			if len(queries) == 1 {
+				initialQuery = queries[0]
+			}
+			if err := Interactive(repos, initialQuery); err != nil {
+				fatalAfterRefresh("Not able to run the interactive mode", err)
+			}
+			return
+		}
+
+		if batch {
+			s, err := newSearcher(starred.Bytes(), repos)
+			if err != nil {
+				fatalAfterRefresh("Not able to search starred repos", err)
+			}
+			results, err := s.batch(queries)
+			if err != nil {
+				fatalAfterRefresh("Not able to search starred repos", err)
+			}
+			filters := activeFilters()
+			for i, query := range queries {
+				if boostRecent {
+					results[i] = BoostRecent(results[i], time.Now())
+				}
+				results[i], _ = ApplyFilters(results[i], filters)
+				if minRank > 0 {
+					var suppressed int
+					results[i], suppressed = FilterMinRank(results[i], minRank)
+					if suppressed > 0 {
+						WarnLogger.Printf("%d results for %q with a rank below %d were suppressed", suppressed, query, minRank)
+					}
+				}
+				results[i] = groupForks(results[i], key)
+				if perOwner > 0 {
+					results[i], _ = LimitPerOwner(results[i], perOwner)
+				}
+				if results[i].Len() == 0 {
+					WarnLogger.Print(NoResultsHint(repos, query))
+				}
+			}
+			if err := RenderBatch(queries, results, limit, os.Stdout); err != nil {
+				fatalAfterRefresh("Not able to render the results", err)
+			}
+			return
+		}
+
+		var found pq.PriorityQueue
+		if listAll {
+			// No search term, list every starred repo
+			found = listRepos(repos)
+		} else {
+			// Fuzzy and ranked searched for the search term(s). The READMEs are fetched
+			// a few at a time, the results of a previous search may miss some of them
+			if noResultCache || len(readmes) > 0 || inputFile != "" {
+				var s *searcher
+				if s, err = newSearcher(starred.Bytes(), repos); err == nil {
+					found, err = s.all(queries)
+				}
+			} else {
+				found, err = SearchMemoized(starred, repos, key, queries)
+			}
+			if err != nil {
+				fatalAfterRefresh("Not able to search starred repos", err)
+			}
+			// The boost isn't part of the result cache, the repos keep getting older
+			if boostRecent {
+				found = BoostRecent(found, time.Now())
+			}
+		}
+		// Keep the repos matching the filters, with or without a search term
+		found, filtered := ApplyFilters(found, activeFilters())
+		if filtered > 0 {
+			InfoLogger.Printf("%d repos were left out by the filters and the ignore file", filtered)
+		}
+		if !listAll {
+			// Drop the weak matches before the limit is applied
+			if minRank > 0 {
+				var suppressed int
+				found, suppressed = FilterMinRank(found, minRank)
+				if suppressed > 0 {
+					WarnLogger.Printf("%d results with a rank below %d were suppressed", suppressed, minRank)
+				}
+			}
+		}
+
+		if found.Len() == 0 {
+			WarnLogger.Print(NoResultsHint(repos, queries...))
+		}
+
+		// Group the forks under their upstream, the rank of the group is the best of them
+		found = groupForks(found, key)
+		// Keep the best results of every owner before the limit is applied
+		if perOwner > 0 {
+			var dropped map[string]int
+			found, dropped = LimitPerOwner(found, perOwner)
+			for owner, count := range dropped {
+				InfoLogger.Printf("%d results of %s were left out by --per-owner %d", count, owner, perOwner)
+			}
+		}
+
+		// Pick one of the results, after the filters and --min-rank
+		if randomPick {
+			if item, ok := PickRandom(found, rand.New(rand.NewSource(seed))); ok {
+				InfoLogger.Printf("Picked a result at random with the seed %d", seed)
+				if err := RenderRandom(item, os.Stdout); err != nil {
+					fatalAfterRefresh("Not able to render the result", err)
+				}
+				return
+			}
 		}
 
 		if err := Render(found, limit, os.Stdout); err != nil {
-			ErrorLogger.Fatal("Not able to render the table", err)
+			fatalAfterRefresh("Not able to render the table", err)
 		}
 	},
+	// The cache file refreshed in the background is written before exiting, see --stale-ok
+	PostRun: func(cmd *cobra.Command, args []string) {
+		waitForBackgroundRefresh()
+	},
 	Version: VERSION,
 }
 
@@ -130,134 +802,1524 @@ func Render(results pq.PriorityQueue, limit int, renderTarget io.Writer) error {
 	}
 }
 
-func RenderTable(results pq.PriorityQueue, limit int, renderTarget io.Writer) error {
-	InfoLogger.Println("Rendering the results in table format")
-
-	if results.Len() > limit {
-		InfoLogger.Printf("Results: %d are higher than the limit: %d \n", results.Len(), limit)
+// RenderRaw prints the repos exactly as GetStarredRepos returns them, see --raw: the JSON
+// array of every page of the repos, with every field GitHub returned
+func RenderRaw(starred bytes.Buffer, renderTarget io.Writer) error {
+	InfoLogger.Printf("Printing the %d bytes of the repos as they were fetched", starred.Len())
+	_, err := renderTarget.Write(starred.Bytes())
+	return err
+}
+
+func RenderTable(results pq.PriorityQueue, limit int, renderTarget io.Writer) error {
+	InfoLogger.Println("Rendering the results in table format")
+
+	if results.Len() > limit {
+		InfoLogger.Printf("Results: %d are higher than the limit: %d \n", results.Len(), limit)
+	}
+
+	renderLimit := RenderLimit(results.Len(), limit)
+
+	tp := tableprinter.New(renderTarget, true, tableMaxWidth)
+	headerRow := []string{"Name", "URL"}
+	if showHomepage {
+		headerRow = append(headerRow, "Homepage")
+	}
+	headerRow = append(headerRow, "Description", "Stars")
+	if showLicense {
+		headerRow = append(headerRow, "License")
+	}
+	// The rank and the matches are meaningless when every repo is listed
+	if !listAll {
+		headerRow = append(headerRow, "Rank", "Matched")
+	}
+	for _, item := range headerRow {
+		tp.AddField(item)
+	}
+	tp.EndRow()
+	for i := 0; i < renderLimit; i++ {
+		item := heap.Pop(&results).(*pq.Item)
+		result := item.Value.(Result)
+		name := result.Full_name
+		if len(result.Forks) > 0 && !showForks {
+			name += " " + forksLabel(len(result.Forks))
+		}
+		addRow(tp, name, result, item.Priority, len(headerRow))
+		// With --show-forks the collapsed forks are listed below their upstream
+		if showForks {
+			for _, fork := range result.Forks {
+				addRow(tp, "  fork: "+fork.Full_name, fork, fork.rank, len(headerRow))
+			}
+		}
+	}
+	err := tp.Render()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// addRow adds the row of a result to the table, followed by its explanation, see RenderTable
+func addRow(tp tableprinter.TablePrinter, name string, result Result, rank int, columns int) {
+	tp.AddField(name)
+	tp.AddField(result.Url)
+	if showHomepage {
+		tp.AddField(result.Homepage)
+	}
+	tp.AddField(result.Description)
+	tp.AddField(fmt.Sprintf("%d", result.Stars))
+	if showLicense {
+		tp.AddField(result.SpdxId())
+	}
+	if !listAll {
+		tp.AddField(fmt.Sprintf("%d", rank))
+		var matched []string
+		for _, match := range result.Matches {
+			matched = append(matched, match.String())
+		}
+		tp.AddField(strings.Join(matched, ", "))
+	}
+	tp.EndRow()
+	// The explanation goes below the row, in the Matched column
+	if result.Explanation != nil {
+		for _, line := range result.Explanation.Lines() {
+			for column := 0; column < columns-1; column++ {
+				tp.AddField("")
+			}
+			tp.AddField(line)
+			tp.EndRow()
+		}
+	}
+}
+
+// RenderJsonOutput renders the results in JSON format
+func RenderJsonOutput(results pq.PriorityQueue, limit int, renderTarget io.Writer) error {
+	InfoLogger.Println("Rendering the results in JSON format")
+
+	jsonOutput, err := json.MarshalIndent(topResults(results, limit), "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(renderTarget, "%s", jsonOutput)
+	return nil
+}
+
+// topResults pops the results to render from the queue, within the limit
+func topResults(results pq.PriorityQueue, limit int) []Result {
+	if results.Len() > limit {
+		InfoLogger.Printf("Results: %d are higher than the limit: %d \n", results.Len(), limit)
+	}
+
+	renderLimit := RenderLimit(results.Len(), limit)
+
+	// No results are rendered as an empty array rather than null
+	repos := []Result{}
+	for i := 0; i < renderLimit; i++ {
+		item := heap.Pop(&results).(*pq.Item)
+		repos = append(repos, item.Value.(Result))
+	}
+	return repos
+}
+
+// A QueryResults is the results of one of the queries read from stdin, see RenderBatch
+type QueryResults struct {
+	Query   string   `json:"query"`
+	Results []Result `json:"results"`
+}
+
+// RenderBatch renders the results of each query, in the order of the queries. The table of
+// each query follows a header with the query, the JSON output is an array with an object
+// per query. The limit applies to every query.
+func RenderBatch(queries []string, results []pq.PriorityQueue, limit int, renderTarget io.Writer) error {
+	if jsonOutput {
+		InfoLogger.Println("Rendering the results of every query in JSON format")
+		batch := make([]QueryResults, len(queries))
+		for i, query := range queries {
+			batch[i] = QueryResults{Query: query, Results: topResults(results[i], limit)}
+		}
+		jsonOutput, err := json.MarshalIndent(batch, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(renderTarget, "%s", jsonOutput)
+		return nil
+	}
+
+	for i, query := range queries {
+		if i > 0 {
+			fmt.Fprintln(renderTarget)
+		}
+		fmt.Fprintf(renderTarget, "Query: %s\n", query)
+		if err := RenderTable(results[i], limit, renderTarget); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadQueries returns the queries read from the reader, one per line. Blank lines are skipped.
+func ReadQueries(reader io.Reader) ([]string, error) {
+	var queries []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if query := strings.TrimSpace(scanner.Text()); query != "" {
+			queries = append(queries, query)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// RenderLimit returns the limit to be used for rendering the results
+// If the limit is -1, then return the total number of results
+// Otherwise return the minimum of the limit and the total number of results
+func RenderLimit(resultsCount int, limit int) int {
+	if limit <= -1 {
+		return resultsCount
+	} else {
+		return int(math.Min(float64(limit), float64(resultsCount)))
+	}
+}
+
+// FilterMinRank returns the results with a rank of at least minRank and the number
+// of results that were dropped
+func FilterMinRank(results pq.PriorityQueue, minRank int) (pq.PriorityQueue, int) {
+	var kept = make(pq.PriorityQueue, 0, results.Len())
+	for _, item := range results {
+		if item.Priority >= minRank {
+			kept = append(kept, &pq.Item{Value: item.Value, Priority: item.Priority})
+		}
+	}
+	heap.Init(&kept)
+	return kept, results.Len() - kept.Len()
+}
+
+// LimitPerOwner returns the perOwner best results of every owner, and the number of
+// results dropped for each owner by lowercase login. Logins are compared ignoring the case.
+func LimitPerOwner(results pq.PriorityQueue, perOwner int) (pq.PriorityQueue, map[string]int) {
+	dropped := make(map[string]int)
+	sorted := append(pq.PriorityQueue{}, results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted.Less(i, j) })
+
+	counts := make(map[string]int)
+	var kept = make(pq.PriorityQueue, 0, results.Len())
+	for _, item := range sorted {
+		owner := strings.ToLower(item.Value.(Result).Owner.Login)
+		if counts[owner] >= perOwner {
+			dropped[owner]++
+			continue
+		}
+		counts[owner]++
+		kept = append(kept, &pq.Item{Value: item.Value, Priority: item.Priority})
+	}
+	heap.Init(&kept)
+	return kept, dropped
+}
+
+// NoResultsHint explains why nothing was found: either the user hasn't starred any
+// repository, or the search was too strict for the starred repos. In the latter case
+// it suggests the options that make the search more permissive.
+func NoResultsHint(repos []Repo, queries ...string) string {
+	if len(repos) == 0 {
+		return fmt.Sprintf(sources[source].empty, user)
+	}
+	if len(queries) == 0 {
+		return fmt.Sprintf("None of the %d %s were kept", len(repos), sources[source].plural)
+	}
+
+	var matching string
+	var suggestions []string
+	switch {
+	case prefixMatch:
+		matching = "prefix matching"
+		suggestions = append(suggestions, "without --prefix")
+	case absoluteDist:
+		matching = fmt.Sprintf("a fuzzy distance of %d", fuzzyDistance)
+		if fuzzyDistance < MAX_FUZZY_DISTANCE {
+			suggestions = append(suggestions, fmt.Sprintf("with --fuzzy-distance %d", fuzzyDistance+1))
+		}
+	case adaptiveDist:
+		matching = "the default fuzzy matching"
+		suggestions = append(suggestions, "with --fuzzy-distance 3")
+	default:
+		matching = fmt.Sprintf("a fuzzy ratio of %v", fuzzyRatio)
+		suggestions = append(suggestions, "with --fuzzy-distance 3")
+	}
+	if matchAll {
+		suggestions = append(suggestions, "without --match-all")
+	}
+	if len(searchIn) > 0 {
+		suggestions = append(suggestions, "without --in")
+	}
+	if minRank > 0 {
+		suggestions = append(suggestions, "without --min-rank")
+	}
+
+	hint := fmt.Sprintf("No results for %s in %d %s with %s", quoteQueries(queries), len(repos), sources[source].plural, matching)
+	if len(suggestions) > 0 {
+		hint += fmt.Sprintf(", try again %s", strings.Join(suggestions, " or "))
+	}
+	return hint
+}
+
+// quoteQueries quotes every query and joins them with or, e.g. "a" or "b"
+func quoteQueries(queries []string) string {
+	quoted := make([]string, len(queries))
+	for i, query := range queries {
+		quoted[i] = fmt.Sprintf("%q", query)
+	}
+	return strings.Join(quoted, " or ")
+}
+
+// ListAll returns every starred repo with a rank of 0, the repos are
+// sorted by stars then by full name
+func ListAll(starredRepos bytes.Buffer) (pq.PriorityQueue, error) {
+	repos, err := DecodeRepos(starredRepos)
+	if err != nil {
+		return nil, err
+	}
+	return listRepos(repos), nil
+}
+
+// listRepos returns every repo with a rank of 0, see ListAll
+func listRepos(repos []Repo) pq.PriorityQueue {
+	var found = make(pq.PriorityQueue, 0)
+	heap.Init(&found)
+	for _, repo := range repos {
+		heap.Push(&found, &pq.Item{
+			Value:    Result{Repo: repo},
+			Priority: 0,
+		})
+	}
+	return found
+}
+
+// DecodeRepos returns the starred repos of the cache, see GetStarredRepos
+func DecodeRepos(starredRepos bytes.Buffer) ([]Repo, error) {
+	var repos []Repo
+	if err := json.Unmarshal(starredRepos.Bytes(), &repos); err != nil {
+		return nil, err
+	}
+	for i := range repos {
+		repos[i].Mirror = repos[i].Mirror_url != ""
+	}
+	return repos, nil
+}
+
+// Search decodes the starred repos and parses the query, then ranks the repos matching it,
+// see SearchRepos
+func Search(starredRepos bytes.Buffer, find string) (pq.PriorityQueue, error) {
+	repos, err := DecodeRepos(starredRepos)
+	if err != nil {
+		return nil, err
+	}
+	query, err := NewQuery(find)
+	if err != nil {
+		return nil, err
+	}
+	return SearchRepos(repos, query)
+}
+
+// SearchRepos finds the query in the repos
+// Returns a priority queue with the results sorted by rank (the higher the rank, the more accurate the match)
+//
+// By default a repo is pushed for every match of every needle (OR semantics). When matchAll is
+// set, a repo only qualifies if every needle matches at least one of its fields and it is pushed
+// once with the sum of the best score of each needle (AND semantics).
+//
+// Needles prefixed with a minus (e.g. -client) are exclusions: any repo whose name, description
+// or topics contain the term is dropped after the positive matching, so ranks are unaffected.
+// A query made only of exclusions returns no results since there is nothing to match against.
+//
+// Queries using the AND, OR and NOT operators or parentheses are evaluated per repo, see
+// ParseBooleanQuery. A repo is pushed once with the sum of the scores of the terms that matched.
+//
+// Every repo is scanned, the search index is only used by SearchAll and SearchBatch.
+func SearchRepos(repos []Repo, query Query) (pq.PriorityQueue, error) {
+	if err := validateSearchOptions(); err != nil {
+		return nil, err
+	}
+	return newCorpus(repos).search(query, nil)
+}
+
+// SearchAll runs every query against the starred repos, see SearchRepos, and returns the
+// repos matching any of them. A repo matching several queries is returned once, with the
+// rank and the matches of the query it ranks best for.
+func SearchAll(starredRepos bytes.Buffer, queries []string) (pq.PriorityQueue, error) {
+	repos, err := DecodeRepos(starredRepos)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSearcher(starredRepos.Bytes(), repos)
+	if err != nil {
+		return nil, err
+	}
+	return s.all(queries)
+}
+
+// SearchBatch runs every query against the starred repos, see SearchRepos, and returns the
+// results of each query in the same order
+func SearchBatch(starredRepos bytes.Buffer, queries []string) ([]pq.PriorityQueue, error) {
+	repos, err := DecodeRepos(starredRepos)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSearcher(starredRepos.Bytes(), repos)
+	if err != nil {
+		return nil, err
+	}
+	return s.batch(queries)
+}
+
+// A searcher runs several queries against the same starred repos, which are prepared for
+// matching once, and indexed on the first query the index helps with
+type searcher struct {
+	cache       []byte // The cache the repos were decoded from, the index is only valid for it
+	corpus      *corpus
+	index       *Index
+	indexLoaded bool
+}
+
+func newSearcher(cache []byte, repos []Repo) (*searcher, error) {
+	if err := validateSearchOptions(); err != nil {
+		return nil, err
+	}
+	return &searcher{cache: cache, corpus: newCorpus(repos)}, nil
+}
+
+// all returns the repos matching any of the queries, see SearchAll
+func (s *searcher) all(queries []string) (pq.PriorityQueue, error) {
+	best := make(map[string]*pq.Item)
+	for _, query := range queries {
+		found, err := s.search(query)
+		if err != nil {
+			return nil, err
+		}
+		if len(queries) == 1 {
+			return found, nil
+		}
+		for _, item := range found {
+			name := item.Value.(Result).Full_name
+			if current, ok := best[name]; !ok || item.Priority > current.Priority {
+				best[name] = item
+			}
+		}
+	}
+
+	var merged = make(pq.PriorityQueue, 0, len(best))
+	for _, item := range best {
+		merged = append(merged, &pq.Item{Value: item.Value, Priority: item.Priority})
+	}
+	heap.Init(&merged)
+	return merged, nil
+}
+
+// batch returns the results of every query, see SearchBatch
+func (s *searcher) batch(queries []string) ([]pq.PriorityQueue, error) {
+	results := make([]pq.PriorityQueue, len(queries))
+	for i, query := range queries {
+		var err error
+		if results[i], err = s.search(query); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (s *searcher) search(text string) (pq.PriorityQueue, error) {
+	query, err := NewQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	// The index only helps exact and prefix matching of plain queries, and doesn't cover the READMEs
+	var index *Index
+	if indexPath != "" && indexable() && query.Expr == nil && !hasWildcard(text) && len(readmes) == 0 {
+		if !s.indexLoaded {
+			s.index, err = LoadIndex(indexPath, s.cache, s.corpus.repos)
+			if err != nil {
+				InfoLogger.Println("Not able to use the search index, scanning every repo:", err)
+				s.index = nil
+			}
+			s.indexLoaded = true
+		}
+		index = s.index
+	}
+	return s.corpus.search(query, index)
+}
+
+// A corpus is a set of repos prepared for matching. The document of a repo is built the
+// first time the repo is scored and reused by the next queries, and the frequencies of the
+// words are only counted once.
+type corpus struct {
+	repos       []Repo
+	docs        []*document
+	frequencies *Frequencies
+}
+
+func newCorpus(repos []Repo) *corpus {
+	return &corpus{repos: repos, docs: make([]*document, len(repos))}
+}
+
+// document returns the document of the repo at position i
+func (c *corpus) document(i int) *document {
+	if c.docs[i] == nil {
+		c.docs[i] = newDocument(c.repos[i])
+	}
+	return c.docs[i]
+}
+
+// search ranks the repos matching the query. When an index is provided, only the repos it
+// returns for the needles are scored.
+func (c *corpus) search(query Query, index *Index) (pq.PriorityQueue, error) {
+	var found = make(pq.PriorityQueue, 0)
+	heap.Init(&found)
+
+	fields, err := parseSearchFields(searchIn)
+	if err != nil {
+		return nil, err
+	}
+	fieldScores, err = parseWeights(weights)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err = loadAliases(aliasesFile)
+	if err != nil {
+		return nil, err
+	}
+	// A weight of 0 is the same as leaving the field out of --in
+	for field, score := range fieldScores {
+		if score == 0 {
+			delete(fields, field)
+		}
+	}
+
+	// Boolean queries are evaluated as a whole for every repo
+	expr := query.Expr
+	needles, excluded := splitTerms(query.Terms)
+	if short := shortNeedles(needles); len(short) > 0 && !prefixMatch {
+		WarnLogger.Printf("Fuzzy matching is disabled for search terms of %d characters or less: %s", SHORT_NEEDLE_LENGTH, strings.Join(short, ", "))
+	}
+	// The frequencies are counted on every starred repo, not only on the candidates
+	if index != nil {
+		frequencies = index.Frequencies
+	} else {
+		if c.frequencies == nil {
+			counted := NewFrequencies(c.repos)
+			c.frequencies = &counted
+		}
+		frequencies = *c.frequencies
+	}
+	var candidates []int
+	if index != nil {
+		var values []string
+		for _, needle := range needles {
+			values = append(values, needle.Value)
+		}
+		candidates = index.Candidates(values)
+		InfoLogger.Printf("The search index returned %d of the %d repos", len(candidates), len(c.repos))
+	} else {
+		candidates = make([]int, len(c.repos))
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	// Exact and prefix searches only keep the results shown, see earlyExitLimit
+	var bounded *boundedResults
+	maxScore := 0
+	if searchLimit > 0 && expr == nil && indexable() {
+		bounded = newBoundedResults(searchLimit)
+		maxScore = maxNeedleScore(fields)
+	}
+
+	for _, i := range candidates {
+		doc := c.document(i)
+		repo := doc.Repo
+		if expr != nil {
+			if match, matches := expr.Eval(doc, fields); match {
+				result := Result{Repo: repo, Matches: matches}
+				if explain {
+					result.Explanation = &Explanation{}
+					for _, match := range matches {
+						result.Explanation.add([]Match{match}, match)
+					}
+				}
+				heap.Push(&found, &pq.Item{
+					Value:    result,
+					Priority: totalScore(matches),
+				})
+			}
+			continue
+		}
+
+		// Every needle adds the score of its best match to the rank of the repo, so repos
+		// matching more needles come first
+		var matches []Match
+		var explanation *Explanation
+		if explain {
+			explanation = &Explanation{}
+		}
+		skipped := false
+		for n, needle := range needles {
+			// Stop scoring the repo once even the best matches of the needles left can't
+			// make it beat the worst result kept
+			if bounded != nil {
+				if worst, ok := bounded.worst(); ok && totalScore(matches)+(len(needles)-n)*maxScore+PROXIMITY_SCORE < worst {
+					skipped = true
+					break
+				}
+			}
+			needleMatches := scoreTerm(doc, needle.Value, termFields(needle, fields))
+			if len(needleMatches) == 0 {
+				if matchAll {
+					break
+				}
+				continue
+			}
+			best := bestOf(needleMatches)
+			matches = append(matches, best)
+			if explanation != nil {
+				explanation.add(needleMatches, best)
+			}
+		}
+
+		if skipped || len(matches) == 0 || (matchAll && len(matches) < len(needles)) || isExcluded(repo, excluded) {
+			continue
+		}
+		bonus := proximityBonus(doc, needles, fields)
+		if explanation != nil {
+			explanation.Proximity = bonus
+		}
+		item := &pq.Item{
+			Value:    Result{Repo: repo, Matches: matches, Explanation: explanation},
+			Priority: totalScore(matches) + bonus,
+		}
+		if bounded != nil {
+			bounded.push(item)
+			continue
+		}
+		heap.Push(&found, item)
+	}
+
+	if bounded != nil {
+		return bounded.queue(), nil
+	}
+	return found, nil
+}
+
+// splitTerms splits the query terms into the needles to match and the terms to exclude
+func splitTerms(terms []Term) ([]Term, []Term) {
+	var needles, excluded []Term
+	for _, term := range terms {
+		if term.Exclude {
+			excluded = append(excluded, term)
+			continue
+		}
+		needles = append(needles, term)
+	}
+	return needles, excluded
+}
+
+// termFields returns the fields to search for the term. A term scoped to a field
+// with a prefix only searches that field, regardless of --in, unless its weight is 0
+func termFields(term Term, fields map[string]bool) map[string]bool {
+	if term.Field == "" {
+		return fields
+	}
+	return map[string]bool{term.Field: fieldScores[term.Field] > 0}
+}
+
+// shortNeedles returns the needles too short to be fuzzy matched
+func shortNeedles(needles []Term) []string {
+	var short []string
+	for _, needle := range needles {
+		if utf8.RuneCountInString(needle.Value) <= SHORT_NEEDLE_LENGTH && !hasWildcard(needle.Value) {
+			short = append(short, needle.Value)
+		}
+	}
+	return short
+}
+
+// isExcluded reports whether any of the fields of the repo contain one of the excluded
+// terms. This is a case-insensitive substring match, independent of the fuzzy search.
+// Terms without a field prefix look at the name, description and topics.
+func isExcluded(repo Repo, excluded []Term) bool {
+	for _, term := range excluded {
+		value := normalize(term.Value)
+		var candidates []string
+		switch term.Field {
+		case "name":
+			candidates = []string{repo.Name}
+		case "owner":
+			candidates = []string{repo.Owner.Login}
+		case "description":
+			candidates = []string{repo.Description}
+		case "topics":
+			candidates = repo.Topics
+		case "homepage":
+			candidates = []string{repo.Homepage}
+		case "readme":
+			candidates = []string{readmes[repo.Full_name]}
+		default:
+			candidates = append([]string{repo.Name, repo.Description}, repo.Topics...)
+		}
+		for _, candidate := range candidates {
+			if strings.Contains(normalize(candidate), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scoreNeedle returns every match of the needle in the repo fields.
+// A match on the repository name, the owner login or the full repository path
+// short-circuits the description and topics. Fields not in the set are skipped.
+// Needles with wildcards are handled by scoreWildcard.
+func scoreNeedle(doc *document, needle string, fields map[string]bool) []Match {
+	if hasWildcard(needle) {
+		return scoreWildcard(doc.Repo, needle, fields)
+	}
+	term := needle
+	needle = unescapeWildcards(needle)
+	normalized := normalize(needle)
+
+	var matches []Match
+
+	// Handle the repository name
+	if fields["name"] {
+		if match, ok := bestMatch("name", term, needle, normalized, doc.nameWords, doc.normalizedName); ok {
+			match.Start, match.End = wordSpan(doc.nameWords, doc.nameOffsets, match.Word)
+			return append(matches, match)
+		}
+	}
+	// Handle the owner login and the full repository path (owner/name)
+	if fields["owner"] {
+		if match, ok := bestMatch("owner", term, needle, normalized, doc.ownerWords, doc.normalizedOwner); ok {
+			// The login starts the full repository path, which is the text of the field
+			match.Start, match.End = 0, len(match.Word)
+			return append(matches, match)
+		}
+	}
+	// Handle the repository description
+	if fields["description"] {
+		for i, word := range doc.descriptionWords {
+			if distance, ok := matchNormalized(normalized, doc.normalizedDescription[i]); ok {
+				// Words found in many descriptions tell little about the repo
+				idf := frequencies.Weight(word.Text)
+				matches = append(matches, Match{
+					Field:    "description",
+					Needle:   term,
+					Word:     word.Text,
+					Distance: distance,
+					Start:    word.Offset,
+					End:      word.Offset + len(word.Text),
+					IDF:      idf,
+					Score:    rankScore(int(math.Round(float64(fieldScores["description"])*idf)), needle, word.Text, distance),
+				})
+			}
+		}
+		// Needles of several words are also looked for as a whole
+		if isPhrase(needle) {
+			if match, ok := phraseMatch(doc, term, needle); ok {
+				matches = append(matches, match)
+			}
+		}
+	}
+	// Handle the topics
+	if fields["topics"] {
+		for i, topic := range doc.Topics {
+			if distance, ok := matchNormalized(normalized, doc.normalizedTopics[i]); ok {
+				matches = append(matches, Match{
+					Field:    "topics",
+					Needle:   term,
+					Word:     topic,
+					Distance: distance,
+					End:      len(topic), // Every topic is a field of its own
+					Score:    topicScore(needle, normalized, topic, doc.normalizedTopics[i], distance),
+				})
+			}
+		}
+	}
+	// Handle the words of the homepage URL
+	if fields["homepage"] {
+		if match, ok := bestMatch("homepage", term, needle, normalized, doc.homepageWords, doc.normalizedHomepage); ok {
+			match.Start, match.End = textSpan(doc.Homepage, match.Word)
+			matches = append(matches, match)
+		}
+	}
+	// Handle the README, a long text where only the closest word is kept
+	if fields["readme"] {
+		if match, ok := bestMatch("readme", term, needle, normalized, doc.readmeWords, doc.normalizedReadme); ok {
+			match.Start, match.End = textSpan(readmes[doc.Full_name], match.Word)
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// topicScore returns the score of a topic matching the needle. Topics are labels picked by
+// the maintainers, a topic equal to the needle, ignoring the case, tells a lot about the
+// repo and scores EXACT_TOPIC_SCORE, unless --weights sets the weight of the topics, which
+// replaces it like the other multipliers. Any other match, stemmed or fuzzy, keeps the
+// score of the topics field.
+func topicScore(needle string, normalized string, topic string, normalizedTopic string, distance int) int {
+	if normalized == normalizedTopic && !topicsWeighted() {
+		return EXACT_TOPIC_SCORE
+	}
+	return rankScore(fieldScores["topics"], needle, topic, distance)
+}
+
+// topicsWeighted reports whether --weights sets the weight of the topics, see parseWeights
+func topicsWeighted() bool {
+	for field := range weights {
+		if strings.EqualFold(strings.TrimSpace(field), "topics") {
+			return true
+		}
+	}
+	return false
+}
+
+// isPhrase reports whether the needle is made of several words, separated by spaces or
+// by punctuation as in "type-safe"
+func isPhrase(needle string) bool {
+	return len(strings.FieldsFunc(needle, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})) > 1
+}
+
+// phraseMatch looks for the phrase in the description as a whole, ignoring the case and
+// the spacing: "go cli" is found in "A Go CLI framework" but not in "Argo client". The
+// phrase is matched exactly and scores PHRASE_RATIO of the description score. The words
+// of the phrase may also match on their own, but only the best match of a needle adds to
+// the rank, so the phrase isn't counted twice.
+func phraseMatch(doc *document, term string, phrase string) (Match, bool) {
+	phrase = strings.Join(strings.Fields(normalize(phrase)), " ")
+	text := doc.descriptionText
+	for start := 0; start < len(text); {
+		i := strings.Index(text[start:], phrase)
+		if i < 0 {
+			break
+		}
+		i += start
+		end := i + len(phrase)
+		// Only whole words, the phrase must not start or end inside a word
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			start, stop := doc.descriptionOffset(i), doc.descriptionOffset(end)
+			return Match{
+				Field:  "description",
+				Needle: term,
+				Word:   text[i:end],
+				Start:  start,
+				End:    stop,
+				Score:  int(math.Round(float64(fieldScores["description"]) * PHRASE_RATIO)),
+			}, true
+		}
+		start = i + 1
+	}
+	return Match{}, false
+}
+
+// descriptionOffset returns the byte offset in the description of the byte at the offset in
+// descriptionText, whose words are normalized and separated by single spaces
+func (d *document) descriptionOffset(offset int) int {
+	word := strings.Count(d.descriptionText[:offset], " ")
+	if word >= len(d.descriptionWords) {
+		return len(d.Description)
+	}
+	// The offset in the normalized word, the text is only split on spaces
+	inWord := offset - strings.LastIndex(d.descriptionText[:offset], " ") - 1
+	return d.descriptionWords[word].Offset + originalOffset(d.descriptionWords[word].Text, inWord)
+}
+
+// originalOffset returns the byte offset in the word of the byte at the offset in the word
+// normalized, normalizing removes the accents and decomposes the ligatures
+func originalOffset(word string, normalizedOffset int) int {
+	length := 0
+	for i, r := range word {
+		if length >= normalizedOffset {
+			return i
+		}
+		length += len(normalize(string(r)))
+	}
+	return len(word)
+}
+
+// wordSpan returns the byte offsets of the word in the text of its field, from the offsets
+// of the words of the field. A word found several times is the first one.
+func wordSpan(words []string, offsets []int, word string) (int, int) {
+	for i, w := range words {
+		if w == word {
+			return offsets[i], offsets[i] + len(word)
+		}
+	}
+	return 0, 0
+}
+
+// textSpan returns the byte offsets of the first occurrence of the word in the text that
+// isn't part of a longer word, or of its first occurrence if there are none
+func textSpan(text string, word string) (int, int) {
+	first := strings.Index(text, word)
+	if first < 0 {
+		return 0, 0
+	}
+	for start := first; start >= 0; {
+		end := start + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return start, end
+		}
+		next := strings.Index(text[start+1:], word)
+		if next < 0 {
+			break
+		}
+		start += 1 + next
+	}
+	return first, first + len(word)
+}
+
+// isWordRune reports whether the rune is part of a word, utf8.RuneError at the ends of
+// a text is not
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// scoreTerm returns the matches of the needle and of its aliases, see --aliases.
+// A match on an alias scores ALIAS_RATIO of the same match on the needle itself.
+func scoreTerm(doc *document, needle string, fields map[string]bool) []Match {
+	matches := scoreNeedle(doc, needle, fields)
+	if hasWildcard(needle) {
+		return matches
+	}
+	needleAliases := aliases[normalize(unescapeWildcards(needle))]
+	// A match on an alias can't beat a match on the needle scoring the most a match on an
+	// alias can, only the explanation lists the other matches
+	if len(needleAliases) > 0 && len(matches) > 0 && !explain &&
+		bestOf(matches).Score >= int(math.Round(float64(maxNeedleScore(fields))*ALIAS_RATIO)) {
+		return matches
+	}
+	for _, alias := range needleAliases {
+		for _, match := range scoreNeedle(doc, alias, fields) {
+			match.Needle = needle
+			match.Alias = alias
+			match.Score = int(math.Round(float64(match.Score) * ALIAS_RATIO))
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// bestOf returns the match with the highest score, the first one on a tie
+func bestOf(matches []Match) Match {
+	best := matches[0]
+	for _, match := range matches[1:] {
+		if match.Score > best.Score {
+			best = match
+		}
+	}
+	return best
+}
+
+// totalScore returns the sum of the scores of the matches
+func totalScore(matches []Match) int {
+	total := 0
+	for _, match := range matches {
+		total += match.Score
+	}
+	return total
+}
+
+// proximityBonus rewards repos whose description has the needles close to each other.
+// It looks for the smallest window of words containing a match for every needle found in
+// the description: the bonus is PROXIMITY_SCORE when they are adjacent, and decreases with
+// every other word in the window. Needles missing from the description are ignored, and
+// there is no bonus unless at least two of them are found.
+func proximityBonus(doc *document, needles []Term, fields map[string]bool) int {
+	type match struct {
+		position int
+		needle   int
+	}
+	var matches []match
+	found := 0
+	for i, needle := range needles {
+		if !termFields(needle, fields)["description"] || hasWildcard(needle.Value) {
+			continue
+		}
+		value := normalize(unescapeWildcards(needle.Value))
+		matched := false
+		for j, word := range doc.descriptionWords {
+			if _, ok := matchNormalized(value, doc.normalizedDescription[j]); ok {
+				matches = append(matches, match{position: word.Position, needle: i})
+				matched = true
+			}
+		}
+		if matched {
+			found++
+		}
+	}
+	if found < 2 {
+		return 0
+	}
+
+	// Matches are collected needle by needle, sort them by position to slide the window
+	sort.Slice(matches, func(i, j int) bool { return matches[i].position < matches[j].position })
+	counts := make(map[int]int)
+	covered := 0
+	window := -1
+	start := 0
+	for _, m := range matches {
+		if counts[m.needle] == 0 {
+			covered++
+		}
+		counts[m.needle]++
+		for covered == found {
+			if size := m.position - matches[start].position + 1; window < 0 || size < window {
+				window = size
+			}
+			counts[matches[start].needle]--
+			if counts[matches[start].needle] == 0 {
+				covered--
+			}
+			start++
+		}
+	}
+
+	// Several needles can match the same word, the window is never smaller than that
+	gaps := window - found
+	if gaps < 0 {
+		gaps = 0
+	}
+	return PROXIMITY_SCORE / (1 + gaps)
+}
+
+// scoreWildcard matches a needle containing wildcards against the whole repository
+// name and each topic, bypassing the fuzzy matching. A match gets the full score of the field.
+func scoreWildcard(repo Repo, needle string, fields map[string]bool) []Match {
+	var matches []Match
+	if fields["name"] && globMatch(needle, repo.Name) {
+		return append(matches, Match{Field: "name", Needle: needle, Word: repo.Name, End: len(repo.Name), Score: fieldScores["name"]})
+	}
+	if fields["topics"] {
+		for _, topic := range repo.Topics {
+			if globMatch(needle, topic) {
+				matches = append(matches, Match{Field: "topics", Needle: needle, Word: topic, End: len(topic), Score: fieldScores["topics"]})
+			}
+		}
+	}
+	return matches
+}
+
+// hasWildcard reports whether the needle contains a * or ? that isn't escaped with a backslash
+func hasWildcard(needle string) bool {
+	escaped := false
+	for _, r := range needle {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*' || r == '?':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeWildcards removes the backslashes escaping a literal *, ? or backslash
+func unescapeWildcards(needle string) string {
+	if !strings.Contains(needle, "\\") {
+		return needle
+	}
+	var unescaped strings.Builder
+	runes := []rune(needle)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("*?\\", runes[i+1]) {
+			i++
+		}
+		unescaped.WriteRune(runes[i])
+	}
+	return unescaped.String()
+}
+
+// globMatch reports whether the whole word matches the pattern, case-insensitively.
+// * matches any sequence of characters, ? matches a single character and a
+// backslash escapes the character following it.
+func globMatch(pattern string, word string) bool {
+	p := []rune(normalize(pattern))
+	w := []rune(normalize(word))
+	pi, wi := 0, 0
+	// Position of the last * in the pattern and of the word when it was reached,
+	// used to backtrack when the rest of the pattern doesn't match
+	star, starWord := -1, 0
+	for wi < len(w) {
+		if pi < len(p) {
+			switch {
+			case p[pi] == '*':
+				star, starWord = pi, wi
+				pi++
+				continue
+			case p[pi] == '?':
+				pi++
+				wi++
+				continue
+			case p[pi] == '\\' && pi+1 < len(p):
+				if p[pi+1] == w[wi] {
+					pi += 2
+					wi++
+					continue
+				}
+			case p[pi] == w[wi]:
+				pi++
+				wi++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		starWord++
+		pi, wi = star+1, starWord
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// splitName splits a repository name into words on -, _ and . as well as on camelCase
+// boundaries. Acronyms are kept together: HTTPServer becomes HTTP and Server.
+// Each segment is also kept whole so it keeps matching as a single word.
+func splitName(name string) []string {
+	words, _ := splitNameOffsets(name)
+	return words
+}
+
+// splitNameOffsets splits the name like splitName, along with the byte offset of every
+// word in the name
+func splitNameOffsets(name string) ([]string, []int) {
+	var words []string
+	var offsets []int
+	addSegment := func(start int, end int) {
+		segment := name[start:end]
+		words = append(words, segment)
+		offsets = append(offsets, start)
+		if parts := splitCamelCase(segment); len(parts) > 1 {
+			for _, part := range parts {
+				words = append(words, part)
+				offsets = append(offsets, start)
+				start += len(part)
+			}
+		}
+	}
+	start := -1
+	for i, r := range name {
+		if r != '-' && r != '_' && r != '.' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			addSegment(start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		addSegment(start, len(name))
+	}
+	return words, offsets
+}
+
+// splitHomepage splits the homepage URL into the labels of its hostname and the words of
+// its path: https://fastapi.tiangolo.com/tutorial/ becomes fastapi, tiangolo and tutorial.
+// The www label and the top-level domain tell nothing about the repo and are left out.
+// A homepage that isn't a URL has no words.
+func splitHomepage(homepage string) []string {
+	homepage = strings.TrimSpace(homepage)
+	if homepage == "" {
+		return nil
+	}
+	// Homepages are often set without a scheme, e.g. example.com/docs
+	if !strings.Contains(homepage, "://") {
+		homepage = "https://" + homepage
+	}
+	parsed, err := url.Parse(homepage)
+	if err != nil || parsed.Hostname() == "" {
+		return nil
+	}
+
+	var words []string
+	labels := strings.Split(parsed.Hostname(), ".")
+	if len(labels) > 1 {
+		labels = labels[:len(labels)-1]
+	}
+	for _, label := range labels {
+		if label != "" && label != "www" {
+			words = append(words, label)
+		}
+	}
+	for _, segment := range strings.Split(parsed.Path, "/") {
+		words = append(words, splitName(segment)...)
+	}
+	return words
+}
+
+// splitCamelCase splits a word on lowercase to uppercase boundaries, and before the
+// last uppercase letter of an acronym followed by a lowercase letter
+func splitCamelCase(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+		acronymEnd := unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
+
+// bestMatch returns the closest word of the field matching the needle, and false if none
+// of the words match. The term is the needle as it was typed in the query, normalized is
+// the needle normalized and normalizedWords are the words normalized, in the same order.
+func bestMatch(field string, term string, needle string, normalized string, words []string, normalizedWords []string) (Match, bool) {
+	best := Match{Field: field, Needle: term}
+	for i, word := range words {
+		if word == "" {
+			continue
+		}
+		if distance, ok := matchNormalized(normalized, normalizedWords[i]); ok {
+			if score := rankScore(fieldScores[field], needle, word, distance); score > best.Score {
+				best.Word = word
+				best.Distance = distance
+				best.Score = score
+			}
+		}
+	}
+	return best, best.Score > 0
+}
+
+// rankScore scales the score of a field by how close the match is. An exact match gets
+// the full score of the field, and the score decreases with every edit down to half of it,
+// so the name > owner > description > topics ordering is kept for reasonable distances.
+func rankScore(fieldScore int, needle string, word string, distance int) int {
+	similarity := 1.0
+	if longest := longestLength(needle, word); longest > 0 {
+		similarity = math.Max(0, 1-float64(distance)/float64(longest))
+	}
+	return int(math.Round(float64(fieldScore) * (1 + similarity) / 2))
+}
+
+// matchWord returns the distance between the needle and the word and whether the word
+// matches the needle. With --prefix, the word matches if it starts with the needle and
+// the distance is the number of characters not covered by it, otherwise see fuzzyMatch.
+// Both are normalized first, so case and accents on Latin letters are ignored.
+func matchWord(needle string, word string) (int, bool) {
+	return matchNormalized(normalize(needle), normalize(word))
+}
+
+// matchNormalized is matchWord for a needle and a word that are already normalized, so
+// the words of a repo are normalized once rather than once per needle, see document
+func matchNormalized(needle string, word string) (int, bool) {
+	if prefixMatch {
+		if !strings.HasPrefix(word, needle) {
+			return -1, false
+		}
+		return utf8.RuneCountInString(word) - utf8.RuneCountInString(needle), true
+	}
+	return fuzzyMatch(needle, word)
+}
+
+// fuzzyMatch returns the Damerau-Levenshtein distance between the needle and the word and
+// whether it is close enough to be considered a match. Transposing two adjacent letters
+// counts as a single edit.
+// By default the number of edits tolerated grows with the length of the needle, see
+// adaptiveDistance. With --fuzzy-ratio the distance is normalized by the length of the
+// longest of the two instead, and with --absolute-distance the raw distance is compared
+// to --fuzzy-distance.
+// Needles of SHORT_NEEDLE_LENGTH characters or less only match whole words, the others are
+// compared without their plural or -ing and -ed suffix, see stem.
+// The needle and the word are expected to be normalized, see normalize.
+func fuzzyMatch(needle string, word string) (int, bool) {
+	length := utf8.RuneCountInString(needle)
+	if length <= SHORT_NEEDLE_LENGTH {
+		if needle == word {
+			return 0, true
+		}
+		return -1, false
+	}
+
+	// Plurals and simple suffixes don't count as edits
+	needle, word = stem(needle), stem(word)
+	distance := damerau.Distance(needle, word)
+	if distance < 0 {
+		return distance, false
+	}
+	if absoluteDist {
+		return distance, distance <= fuzzyDistance
+	}
+	if adaptiveDist {
+		return distance, distance <= adaptiveDistance(length)
+	}
+
+	longest := longestLength(needle, word)
+	if longest == 0 {
+		return distance, true
+	}
+	return distance, float64(distance)/float64(longest) <= fuzzyRatio
+}
+
+// adaptiveDistance returns the number of edits tolerated by default for a needle of that
+// many characters: none up to SHORT_NEEDLE_LENGTH, so "git" is only matched exactly, then
+// 1 up to 6 characters, 2 up to 10 and 3 above, so "kubernetes" survives a couple of typos
+func adaptiveDistance(length int) int {
+	switch {
+	case length <= SHORT_NEEDLE_LENGTH:
+		return 0
+	case length <= 6:
+		return 1
+	case length <= 10:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// normalize prepares a text for comparison: it is lowercased, compatibility characters
+// are decomposed (the "ﬁ" ligature becomes "fi") and accents are removed from Latin
+// letters, so "Résumé" becomes "resume". Marks on other scripts are kept because they
+// change the letter, e.g. the Cyrillic "й" is not an "и".
+func normalize(text string) string {
+	ascii := true
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return strings.ToLower(text)
+	}
+
+	var b strings.Builder
+	latin := false // Whether the last letter, the one a mark applies to, is Latin
+	for _, r := range norm.NFKD.String(text) {
+		if unicode.Is(unicode.Mn, r) {
+			if latin {
+				continue
+			}
+		} else {
+			latin = unicode.Is(unicode.Latin, r)
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(norm.NFC.String(b.String()))
+}
+
+// stem strips the plural and the -ing and -ed suffixes of an English word, so "parsers"
+// becomes "parser" and "templating" becomes "templat". The suffix is kept when the stem
+// would be shorter than MIN_STEM_LENGTH: "ring" isn't "r" and "bus" isn't "bu".
+// The -es suffix is only stripped after s, x, z, ch and sh, "matches" becomes "match"
+// but "templates" becomes "template".
+func stem(word string) string {
+	strip := func(suffix string) (string, bool) {
+		if !strings.HasSuffix(word, suffix) {
+			return word, false
+		}
+		stem := strings.TrimSuffix(word, suffix)
+		if utf8.RuneCountInString(stem) < MIN_STEM_LENGTH {
+			return word, false
+		}
+		return stem, true
+	}
+
+	for _, suffix := range []string{"ing", "ed"} {
+		if stem, ok := strip(suffix); ok {
+			return stem
+		}
+	}
+	if strings.HasSuffix(word, "es") {
+		base := strings.TrimSuffix(word, "es")
+		for _, ending := range []string{"s", "x", "z", "ch", "sh"} {
+			if strings.HasSuffix(base, ending) {
+				if stem, ok := strip("es"); ok {
+					return stem
+				}
+				return word
+			}
+		}
+	}
+	if !strings.HasSuffix(word, "ss") {
+		if stem, ok := strip("s"); ok {
+			return stem
+		}
+	}
+	return word
+}
+
+// longestLength returns the number of characters of the longest of the two strings
+func longestLength(a string, b string) int {
+	longest := utf8.RuneCountInString(a)
+	if length := utf8.RuneCountInString(b); length > longest {
+		longest = length
 	}
+	return longest
+}
 
-	renderLimit := RenderLimit(results.Len(), limit)
-
-	tp := tableprinter.New(renderTarget, true, tableMaxWidth)
-	headerRow := []string{"Name", "URL", "Description", "Stars", "Rank"}
-	for _, item := range headerRow {
-		tp.AddField(item)
+// validateSearchOptions checks the flags that tune the search before any network call is made
+func validateSearchOptions() error {
+	if _, err := parseSearchFields(searchIn); err != nil {
+		return err
 	}
-	tp.EndRow()
-	for i := 0; i < renderLimit; i++ {
-		item := heap.Pop(&results).(*pq.Item)
-		tp.AddField(item.Value.(Repo).Full_name)
-		tp.AddField(item.Value.(Repo).Url)
-		tp.AddField(item.Value.(Repo).Description)
-		tp.AddField(fmt.Sprintf("%d", item.Value.(Repo).Stars))
-		tp.AddField(fmt.Sprintf("%d", item.Priority))
-		tp.EndRow()
+	if fuzzyDistance < 0 || fuzzyDistance > MAX_FUZZY_DISTANCE {
+		return fmt.Errorf("--fuzzy-distance must be between 0 and %d, got: %d", MAX_FUZZY_DISTANCE, fuzzyDistance)
 	}
-	err := tp.Render()
-	if err != nil {
+	if fuzzyRatio < 0 || fuzzyRatio >= 1 {
+		return fmt.Errorf("--fuzzy-ratio must be between 0 and 1, got: %v", fuzzyRatio)
+	}
+	if _, err := parseWeights(weights); err != nil {
+		return err
+	}
+	if _, err := loadAliases(aliasesFile); err != nil {
+		return fmt.Errorf("not able to load the aliases: %w", err)
+	}
+	if cacheTTL < 0 {
+		return fmt.Errorf("--cache-ttl must be positive, got: %s", cacheTTL)
+	}
+	if cacheMaxAge < 0 {
+		return fmt.Errorf("--cache-max-age must be positive, got: %s", cacheMaxAge)
+	}
+	if _, err := cacheSizeBudget(); err != nil {
 		return err
 	}
+	if minRank < 0 {
+		return fmt.Errorf("--min-rank must be positive, got: %d", minRank)
+	}
+	if perOwner < 0 {
+		return fmt.Errorf("--per-owner must be positive, got: %d", perOwner)
+	}
+	if minStars.set && maxStars.set && minStars.value > maxStars.value {
+		return fmt.Errorf("--min-stars %d is above --max-stars %d, no repository can have both", minStars.value, maxStars.value)
+	}
+	if minOpenIssues.set && maxOpenIssues.set && minOpenIssues.value > maxOpenIssues.value {
+		return fmt.Errorf("--min-open-issues %d is above --max-open-issues %d, no repository can have both", minOpenIssues.value, maxOpenIssues.value)
+	}
+	if pushedAfter.set() && pushedBefore.set() && !pushedAfter.time.Before(pushedBefore.time) {
+		return fmt.Errorf("--pushed-after %s is not before --pushed-before %s, no repository can have both", pushedAfter.text, pushedBefore.text)
+	}
+	if starredAfter.set() && starredBefore.set() && !starredAfter.time.Before(starredBefore.time) {
+		return fmt.Errorf("--starred-after %s is not before --starred-before %s, no repository can have both", starredAfter.text, starredBefore.text)
+	}
+	if (starredAfter.set() || starredBefore.set()) && source != "stars" {
+		return fmt.Errorf("--starred-after and --starred-before only work with --source stars, the %s have no star date", sources[source].plural)
+	}
+	if noArchived && archivedOnly {
+		return fmt.Errorf("--archived-only can't be combined with --no-archived, no repository is both archived and not")
+	}
+	if noForks && forksOnly {
+		return fmt.Errorf("--forks-only can't be combined with --no-forks, no repository is both a fork and not one")
+	}
+	for _, pattern := range excludes {
+		if _, err := path.Match(strings.ToLower(strings.TrimSpace(pattern)), ""); err != nil {
+			return fmt.Errorf("--exclude %q is not a valid pattern: %w", pattern, err)
+		}
+	}
+	if filterText != "" {
+		if _, err := ParseFilter(filterText); err != nil {
+			return fmt.Errorf("--filter: %w", err)
+		}
+	}
+	if _, ok := visibilities[strings.ToLower(visibility)]; !ok {
+		return fmt.Errorf("--visibility must be one of public, private or all, got: %q", visibility)
+	}
+	if _, ok := sources[source]; !ok {
+		return fmt.Errorf("--source must be one of %s, got: %q", strings.Join(sourceNames(), ", "), source)
+	}
 	return nil
 }
 
-// RenderJsonOutput renders the results in JSON format
-func RenderJsonOutput(results pq.PriorityQueue, limit int, renderTarget io.Writer) error {
-	InfoLogger.Println("Rendering the results in JSON format")
-
-	if results.Len() > limit {
-		InfoLogger.Printf("Results: %d are higher than the limit: %d \n", results.Len(), limit)
+// parseSearchFields validates the fields provided with --in and returns them as a set.
+// All the searchable fields are returned when none are provided.
+func parseSearchFields(in []string) (map[string]bool, error) {
+	fields := make(map[string]bool)
+	if len(in) == 0 {
+		for _, field := range searchableFields {
+			fields[field] = true
+		}
+		return fields, nil
 	}
 
-	renderLimit := RenderLimit(results.Len(), limit)
-
-	var repos []Repo
-	for i := 0; i < renderLimit; i++ {
-		item := heap.Pop(&results).(*pq.Item)
-		repos = append(repos, item.Value.(Repo))
+	for _, field := range in {
+		field = strings.ToLower(strings.TrimSpace(field))
+		known := false
+		for _, searchable := range searchableFields {
+			if field == searchable {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q provided to --in, valid fields are: %s", field, strings.Join(searchableFields, ", "))
+		}
+		fields[field] = true
 	}
+	return fields, nil
+}
 
-	jsonOutput, err := json.MarshalIndent(repos, "", "    ")
-	if err != nil {
-		return err
+// defaultFieldScores returns the score of an exact match in each field when no weights are provided
+func defaultFieldScores() map[string]int {
+	return map[string]int{
+		"name":        NAME_SCORE,
+		"owner":       OWNER_SCORE,
+		"description": DESCRIPTION_SCORE,
+		"topics":      TOPIC_SCORE,
+		"homepage":    HOMEPAGE_SCORE,
+		"readme":      README_SCORE,
 	}
-
-	fmt.Fprintf(renderTarget, "%s", jsonOutput)
-	return nil
 }
 
-// RenderLimit returns the limit to be used for rendering the results
-// If the limit is -1, then return the total number of results
-// Otherwise return the minimum of the limit and the total number of results
-func RenderLimit(resultsCount int, limit int) int {
-	if limit <= -1 {
-		return resultsCount
-	} else {
-		return int(math.Min(float64(limit), float64(resultsCount)))
+// parseWeights validates the weights provided with --weights and returns the score of an
+// exact match in each field. A weight is worth WEIGHT_SCORE, the fields without a weight
+// keep their default score.
+func parseWeights(weights map[string]int) (map[string]int, error) {
+	scores := defaultFieldScores()
+	for field, weight := range weights {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if _, ok := scores[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q provided to --weights, valid fields are: %s", field, strings.Join(searchableFields, ", "))
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("the weight of %s must be positive, got: %d", field, weight)
+		}
+		scores[field] = weight * WEIGHT_SCORE
+	}
+	for _, score := range scores {
+		if score > 0 {
+			return scores, nil
+		}
 	}
+	return nil, errors.New("at least one field must have a weight higher than 0")
 }
 
-// Find the search term in the starred repos
-// Returns a priority queue with the results sorted by rank (the higher the rank, the more accurate the match)
-func Search(starredRepos bytes.Buffer, find string) (pq.PriorityQueue, error) {
-	var found = make(pq.PriorityQueue, 0)
-	heap.Init(&found)
-
-	var repos []Repo
-	needles := strings.Fields(find)
-	err := json.Unmarshal(starredRepos.Bytes(), &repos)
+// AuthenticatedUser returns the login of the user gh is logged in as
+func AuthenticatedUser() (string, error) {
+	stdOut, stdErr, err := ghClient.Exec("api", "user", "--jq", ".login")
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("%w %s", err, stdErr.String())
 	}
-
-	for _, repo := range repos {
-		for _, needle := range needles {
-			// Handle the repository name
-			// Split the repository on - and _
-			repoNameWords := strings.FieldsFunc(repo.Name, func(r rune) bool {
-				return r == '-' || r == '_'
-			})
-			match := false
-			for _, word := range repoNameWords {
-				rank := fuzzy.LevenshteinDistance(needle, word)
-				if rank >= 0 && rank <= MAX_FUZZY_DISTANCE {
-					heap.Push(&found, &pq.Item{
-						Value:    repo,
-						Priority: (rank/(rank+1) + 10) * 100,
-					})
-					match = true
-					break
-				}
-			}
-			if match {
-				continue
-			}
-			// Handle the repository description
-			descriptionWords := strings.Fields(repo.Description)
-			for _, word := range descriptionWords {
-				rank := fuzzy.LevenshteinDistance(needle, word)
-				if rank >= 0 && rank <= MAX_FUZZY_DISTANCE {
-					heap.Push(&found, &pq.Item{
-						Value:    repo,
-						Priority: (rank/(rank+1) + 5) * 50,
-					})
-				}
-				continue
-			}
-			// Handle the topics
-			for _, topic := range repo.Topics {
-				rank := fuzzy.LevenshteinDistance(needle, topic)
-				if rank >= 0 && rank <= MAX_FUZZY_DISTANCE {
-					heap.Push(&found, &pq.Item{
-						Value:    repo,
-						Priority: (rank/(rank+1) + 1) * 25,
-					})
-				}
-			}
-		}
+	login := strings.TrimSpace(stdOut.String())
+	if login == "" {
+		return "", errors.New("gh returned an empty login")
 	}
-
-	return found, nil
+	return login, nil
 }
 
 // Every API call to GitHub returns a header Link. This header contains
@@ -268,142 +2330,428 @@ func Search(starredRepos bytes.Buffer, find string) (pq.PriorityQueue, error) {
 // the number of items they have starred. When the user adds or removes an item,
 // the cache key will change.
 //
-// Caveat:
-// if the user has starred an item then unstarred another item, the cache key
-// will not change! This is an acceptable tradeoff for the simplicity of the
-// implementation.
+// The single item of the page is the newest one, its id and the date it was starred
+// are part of the cache key too: the cache key changes when the user has starred an
+// item then unstarred another one, even though the number of items didn't change.
+//
+// The same user can exist on github.com and on a GitHub Enterprise host, the host is part
+// of the cache key but for github.com, whose cache keys are kept as they were.
 func GenerateCacheKey(user string) ([32]byte, error) {
+	cacheKey, _, err := generateCacheKey(user)
+	return cacheKey, err
+}
+
+// generateCacheKey returns the cache key of the repos of the user, see GenerateCacheKey, along
+// with their number: the number of pages the Link header lists one repo per page
+func generateCacheKey(user string) ([32]byte, int, error) {
 	if user == "" {
-		return [32]byte{}, fmt.Errorf("user cannot be empty, the implementation is faulty")
+		return [32]byte{}, 0, fmt.Errorf("user cannot be empty, the implementation is faulty")
 	}
 
-	InfoLogger.Println("Attempting to fetch the total number of starred repos for user", user)
-	url := fmt.Sprintf("https://api.github.com/users/%v/starred?page=1&per_page=1", user)
+	InfoLogger.Printf("Attempting to fetch the total number of repos in the %s of user %s", source, user)
+	url := apiURL(fmt.Sprintf("users/%v/%s?page=1&per_page=1", user, sources[source].endpoint))
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return [32]byte{}, err
+		return [32]byte{}, 0, err
 	}
 
-	req.Header.Set("Accept", "application/vnd.github+json")
+	accept := sources[source].accept
+	if accept == "" {
+		accept = "application/vnd.github+json"
+	}
+	req.Header.Set("Accept", accept)
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return [32]byte{}, err
+		return [32]byte{}, 0, err
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusForbidden:
-		return [32]byte{}, fmt.Errorf("api rate limit reached. used: %v, remaining: %v, reset time: %v", resp.Header.Get("X-RateLimit-Used"), resp.Header.Get("X-RateLimit-Remaining"), resp.Header.Get("X-RateLimit-Reset"))
-	case http.StatusNotFound:
-		return [32]byte{}, fmt.Errorf("user not found or you're not authorized to access this data")
-	case http.StatusOK:
-		break
-	default:
-		return [32]byte{}, fmt.Errorf("unexpected http status code: %d", resp.StatusCode)
+	if err := apiError(resp); err != nil {
+		return [32]byte{}, 0, err
+	}
+
+	// The newest repo is listed as is, or wrapped along with its star date, see unwrapStars
+	var newest []struct {
+		Id         int64  `json:"id"`
+		Starred_at string `json:"starred_at"`
+		Repo       struct {
+			Id int64 `json:"id"`
+		} `json:"repo"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&newest); err != nil {
+		return [32]byte{}, 0, fmt.Errorf("not able to read the newest of the %s: %w", sources[source].plural, err)
+	}
+	content := ""
+	for _, repo := range newest {
+		content = fmt.Sprintf("%d %d %s", repo.Id, repo.Repo.Id, repo.Starred_at)
 	}
 
 	header := resp.Header.Get("Link")
-	cacheKey := sha256.Sum256([]byte(header))
+	count, err := pageCount(header, len(newest))
+	if err != nil {
+		return [32]byte{}, 0, err
+	}
+	if !isDefaultHost(host) {
+		header = host + "\n" + header
+	}
+	cacheKey := sha256.Sum256([]byte(header + "\n" + content))
 	InfoLogger.Println("CacheKey generated:", fmt.Sprintf("%x", cacheKey))
-	return cacheKey, nil
+	return cacheKey, count, nil
 }
 
-// GetCachePath returns the path to the cache file to use for storing starred repos. If
-// the cache file path was not provided as input, it will create a new one.
-// The cache file created uses the first 6 bytes of the cache key to generate a unique
-// filename.
+// GetCachePath returns the path to the cache file to use for storing starred repos, see
+// CachePath. The cache file isn't created, it only exists once the repos were written to it,
+// see writeCacheFile, but its directory is, unless the cache is read-only, see
+// --cache-read-only.
 //
-// Example: <tmpdir>/stars_2d06a89b2687.json
+// With --no-cache there is no cache file, errCacheBypassed is returned. The missing
+// directories of a --cache-file are created, see createCacheFileDir.
 func GetCachePath(cacheKey [32]byte) (string, error) {
+	if noCache {
+		return "", errCacheBypassed
+	}
+	path, err := CachePath(cacheKey)
+	if err != nil {
+		return "", err
+	}
+	// Nothing is created nor moved in a read-only cache, the cache file is read if it exists
+	if cacheReadOnly {
+		return path, nil
+	}
+	if cacheFile == "" {
+		if err := prepareCacheFile(path, cacheFileName("", cacheKey)); err != nil {
+			return "", err
+		}
+	} else if err := createCacheFileDir(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// CachePath returns the path to the cache file of the cache key, without creating it. If
+// the cache file path was provided as input, its leading ~ is the home directory, see
+// expandHome. Otherwise, the --user and the first 6 bytes of the
+// cache key are used to generate a unique filename in the cache directory, see cacheDir
+// and cacheFileName. The cache file is gzip-compressed, see writeCacheFile.
+//
+// Example: ~/.cache/gh-stars/stars_link-_2d06a89b2687.json.gz
+func CachePath(cacheKey [32]byte) (string, error) {
 	// We check if cacheFile is provided as input by the user
 	if cacheFile != "" {
 		InfoLogger.Println("Cache file provided as input:", cacheFile)
-		return cacheFile, nil
+		return expandHome(cacheFile)
 	}
 
 	if cacheKey == [32]byte{} {
 		return "", fmt.Errorf("cachekey cannot be empty, the implementation is faulty")
 	}
 
-	// cacheFile format: <tmpdir>/stars_2d06a89b2687.json
-	// each byte is 2 hex characters
-	path := filepath.Join(os.TempDir(), fmt.Sprintf("stars_%x.json", cacheKey[:6]))
-	if cacheFileExists := fileExists(path); !cacheFileExists {
-		InfoLogger.Println("Cache file doesn't exist, creating a new one at:", path)
-		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
-		if err != nil {
-			return "", err
-		}
-		defer file.Close()
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
 	}
-	return path, nil
+	return filepath.Join(dir, cacheFileName(user, cacheKey)), nil
+}
+
+// cacheFileName returns the name of the cache file of the repos of the user with the cache
+// key: the --source, so the lists of repos never share a cache, the user, see
+// cacheUserName, and the first 6 bytes of the cache key, each byte is 2 hex characters.
+// Older versions named it without the user, which is still the name of the cache file of
+// an unknown user.
+//
+// Example: stars_link-_2d06a89b2687.json.gz, stars_2d06a89b2687.json.gz for older versions
+func cacheFileName(user string, cacheKey [32]byte) string {
+	if user == "" {
+		return fmt.Sprintf("%s_%x.json.gz", source, cacheKey[:6])
+	}
+	return fmt.Sprintf("%s_%s_%x.json.gz", source, cacheUserName(user), cacheKey[:6])
+}
+
+// cacheUserName returns the user as it's written in the names of the cache files: logins
+// are case insensitive, and anything but a letter, a digit or a hyphen, which a GitHub
+// login never has but the logins of some GitHub Enterprise Server instances do, is
+// replaced with a hyphen. The underscores separating the parts of the names stay unique.
+//
+// Example: link- for Link-, john-doe for John_Doe
+func cacheUserName(user string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(user))
 }
 
+// errCacheBypassed is returned by GetCachePath with --no-cache, the paths of the caches
+// next to the cache file wrap it
+var errCacheBypassed = errors.New("the cache is bypassed with --no-cache")
+
 // GetStarredRepos returns the starred repos for the given user.
 // If the cache file exists and is not empty, it will read from the cache file.
 // If the cache file does not exist or is empty, it will make an API call to GitHub
-// to fetch the starred repos for the given user.
+// to fetch the starred repos for the given user, and write them to the cache file in an
+// envelope, see cacheEnvelope. The cache file written by an older version is migrated first,
+// see migrateCache, or fetched again when it can't be, it is still read when they can't.
+// A cache file fetched or checked with GitHub longer ago than --cache-ttl is checked again,
+// with the validators of the first page written along with it, see cacheValidator, and
+// updated when the repos changed since, or read while it is refreshed in the background
+// with --stale-ok, see refreshInBackground. It is still read when the repos can't be fetched.
+// With --no-cache, the repos are fetched without reading or writing any file. With
+// --cache-read-only, the cache file is read but never written, see skipCacheWrite.
 func GetStarredRepos(user string, cacheKey [32]byte) (bytes.Buffer, error) {
+	if noCache {
+		InfoLogger.Printf("The cache is bypassed with --no-cache. Fetching the repos in the %s of: %s", source, user)
+		starred, _, err := fetchRepoPages(user, cacheValidator{})
+		return starred, err
+	}
 	path, err := GetCachePath(cacheKey)
 	if err != nil {
 		return bytes.Buffer{}, err
 	}
 
-	size, err := fileSize(path)
-	if err != nil {
+	// A compressed cache file is empty when it decompresses to nothing, whatever its size
+	cache, err := readCache(path)
+	if errors.Is(err, os.ErrNotExist) {
+		InfoLogger.Println("The cache file doesn't exist yet:", path)
+		cache = cacheEnvelope{}
+	} else if errors.Is(err, errCacheCorrupted) {
+		WarnLogger.Printf("The cache file %s is corrupted, moving it to %s.corrupted and fetching the repos again: %v", path, path, err)
+		if err := sidelineCache(path); err != nil {
+			WarnLogger.Printf("Not able to move the corrupted cache file aside: %v", err)
+		}
+		cache = cacheEnvelope{}
+	} else if err != nil {
 		return bytes.Buffer{}, err
 	}
+	if !cache.belongsTo(user) {
+		InfoLogger.Printf("The cache file %s holds the %s of %s on %s, fetching the repos again", path, cache.Source, cache.User, cache.Host)
+		cache = cacheEnvelope{}
+	}
+	var outdated []byte
+	if len(cache.Repos) > 0 && cache.Version < CACHE_VERSION {
+		migrated := upgradeCache(path, user, cache)
+		if len(migrated.Repos) == 0 {
+			outdated = cache.Repos
+		}
+		cache = migrated
+	}
+	cached := []byte(cache.Repos)
+
+	// Once the cache file expired, ask GitHub whether the repos changed since, with the
+	// validators of their first page written along with it, otherwise since they were
+	// fetched. It is updated when they did, and read when they can't be fetched. With
+	// --stale-ok, it is read at once while GitHub is asked in the background.
+	if age, err := cache.age(path); len(cached) > 0 && err == nil && cacheTTL > 0 && age > cacheTTL {
+		InfoLogger.Printf("The cache file was checked %s ago, more than --cache-ttl %s", age.Round(time.Second), cacheTTL)
+		validator, err := readCacheValidator(path)
+		if err != nil {
+			validator = cache.fetchedValidator()
+		}
+		if staleOK && !cacheReadOnly {
+			refreshInBackground(user, path, cached, validator, age)
+			return *bytes.NewBuffer(cached), nil
+		}
+		starred, err := revalidateCache(user, path, cached, validator)
+		if err == nil {
+			return starred, nil
+		}
+		WarnLogger.Printf("Not able to fetch the repos again, reading the cache file checked %s ago: %v", age.Round(time.Minute), err)
+	}
 
 	// Read from cache file if it exists and is not empty
-	if size > 0 {
+	if len(cached) > 0 {
 		InfoLogger.Println("Cache file exists and is not empty, reading from the cache file:", path)
-		file, err := os.Open(path)
+		return *bytes.NewBuffer(cached), nil
+	}
+
+	// Cache file is empty, make an API call to GitHub and cache the results. Another process
+	// may have done so while this one waited for the lock, the cache is read again then
+	unlock, err := lockCacheFile(path)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	defer unlock()
+	if cache, err := readCache(path); err == nil && len(cache.Repos) > 0 && cache.Version >= CACHE_VERSION && cache.belongsTo(user) {
+		InfoLogger.Println("The cache file was written while waiting for its lock, reading from the cache file:", path)
+		return *bytes.NewBuffer(cache.Repos), nil
+	}
+	InfoLogger.Printf("Cache is empty. Fetching the repos in the %s of: %s", source, user)
+	resultBuffer, validator, err := fetchRepoPages(user, cacheValidator{})
+	if err != nil && len(outdated) > 0 {
+		WarnLogger.Printf("Not able to fetch the repos again, reading the cache file of an older version: %v", err)
+		return *bytes.NewBuffer(outdated), nil
+	}
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+
+	// Write the repos to the cache file, along with their validators
+	InfoLogger.Println("Writing the fetched repos to cache.")
+	if err := writeCache(path, user, resultBuffer, validator); err != nil {
+		return bytes.Buffer{}, err
+	}
+
+	return resultBuffer, nil
+}
+
+const REPOS_PER_PAGE = 100 // Number of repos fetched with every request, the most the API lists
+
+// errNotModified is returned by fetchRepoPage when the first page of the repos didn't change
+// since they were cached, see cacheValidator
+var errNotModified = errors.New("the repos didn't change since they were cached")
+
+// isDefaultHost reports whether the host is github.com, or unknown
+func isDefaultHost(host string) bool {
+	return host == "" || strings.EqualFold(host, "github.com")
+}
+
+// apiURL returns the URL of the path of the REST API of the host, see
+// https://docs.github.com/en/enterprise-server/rest/overview/resources-in-the-rest-api
+//
+// Example: https://api.github.com/users/link-/starred, or
+// https://github.example.com/api/v3/users/link-/starred for GH_HOST=github.example.com
+func apiURL(path string) string {
+	if isDefaultHost(host) {
+		return "https://api.github.com/" + path
+	}
+	return fmt.Sprintf("https://%s/api/v3/%s", strings.ToLower(host), path)
+}
+
+// firstPage returns the URL of the first page of the repos of the --source of the user
+func firstPage(user string, perPage int) string {
+	return apiURL(fmt.Sprintf("users/%v/%s?per_page=%d", user, sources[source].endpoint, perPage))
+}
+
+// fetchRepoPages fetches every page of the repos of the --source of the user, following the
+// next page of the Link header, and returns them as a single list along with the validators
+// of the first page. With the validators of a cache file, the first page is requested
+// conditionally: errNotModified is returned when it didn't change, a response that doesn't
+// count against the rate limit.
+func fetchRepoPages(user string, cached cacheValidator) (bytes.Buffer, cacheValidator, error) {
+	repos := []json.RawMessage{}
+	var validator cacheValidator
+	next := firstPage(user, REPOS_PER_PAGE)
+	for page := 1; next != ""; page++ {
+		conditions := cacheValidator{}
+		if page == 1 {
+			conditions = cached
+		}
+		items, link, pageValidator, err := fetchRepoPage(next, conditions)
 		if err != nil {
-			return bytes.Buffer{}, err
+			return bytes.Buffer{}, cacheValidator{}, err
 		}
-		defer file.Close()
+		if page == 1 {
+			validator = pageValidator
+		}
+		repos = append(repos, items...)
+		next = pageLink(link, "next")
+	}
 
-		var data bytes.Buffer
-		_, err = io.Copy(&data, file)
-		if err != nil {
-			return bytes.Buffer{}, err
+	data, err := json.Marshal(repos)
+	if err != nil {
+		return bytes.Buffer{}, cacheValidator{}, err
+	}
+	if sources[source].accept == STAR_MEDIA_TYPE {
+		if data, err = unwrapStars(data); err != nil {
+			return bytes.Buffer{}, cacheValidator{}, fmt.Errorf("not able to read the star dates: %w", err)
 		}
-		return data, nil
 	}
+	return *bytes.NewBuffer(data), validator, nil
+}
 
-	// Cache file is empty, make an API call to GitHub and cache the results
-	InfoLogger.Println("Cache is empty. Fetching the starred repos for:", user)
-	args := []string{"api", "--paginate", fmt.Sprintf("users/%v/starred", user)}
-	stdOut, _, err := ghClient.Exec(args...)
+// fetchRepoPage fetches a page of the repos, conditionally when there are validators, and
+// returns its repos as listed by the API along with its Link header and its validators
+func fetchRepoPage(pageURL string, conditions cacheValidator) ([]json.RawMessage, string, cacheValidator, error) {
+	accept := sources[source].accept
+	if accept == "" {
+		accept = "application/vnd.github+json"
+	}
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
 	if err != nil {
-		return bytes.Buffer{}, err
+		return nil, "", cacheValidator{}, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	conditions.setConditions(req)
+
+	InfoLogger.Println("Fetching a page of the repos:", pageURL)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", cacheValidator{}, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, "", cacheValidator{}, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", conditions, errNotModified
+	}
+	if err := apiError(resp); err != nil {
+		return nil, "", cacheValidator{}, err
 	}
 
-	// TODO: This is extremely nasty, and needs to be refactored once this PR
-	// is merged: https://github.com/cli/cli/pull/7190
-	// This resolves the problem of gh api --paginate returning concatenated slices instead of
-	// a single slice of all the results
-	result := stdOut.String()
-	jsonResult := strings.Replace(result, "][", ",", -1)
-	resultBuffer := bytes.NewBufferString(jsonResult)
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, "", cacheValidator{}, fmt.Errorf("not able to read the %s: %w", sources[source].plural, err)
+	}
+	validator := cacheValidator{Etag: resp.Header.Get("ETag"), Last_modified: resp.Header.Get("Last-Modified")}
+	return items, resp.Header.Get("Link"), validator, nil
+}
 
-	// Write stdOut to the cache file
-	InfoLogger.Println("Writing the fetched repos to cache.")
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
+// countRepos returns the number of repos in the --source of the user: the number of the
+// last page when they are listed one per page, see GenerateCacheKey
+func countRepos(user string) (int, error) {
+	items, link, _, err := fetchRepoPage(firstPage(user, 1), cacheValidator{})
 	if err != nil {
-		return bytes.Buffer{}, err
+		return 0, err
 	}
-	defer file.Close()
+	return pageCount(link, len(items))
+}
 
-	_, err = file.Write(resultBuffer.Bytes())
+// pageCount returns the number of the last page of the Link header, the number of items
+// when they are listed one per page, or the items of the page when it is the only one
+func pageCount(link string, items int) (int, error) {
+	last := pageLink(link, "last")
+	if last == "" {
+		return items, nil
+	}
+	lastURL, err := url.Parse(last)
 	if err != nil {
-		return bytes.Buffer{}, err
+		return 0, err
 	}
+	return strconv.Atoi(lastURL.Query().Get("page"))
+}
+
+// pageLink returns the URL of the page of the Link header with the given relation, empty
+// when there is none, e.g. no next page on the last page
+//
+// Example: <https://api.github.com/user/1/starred?page=2>; rel="next", <...>; rel="last"
+func pageLink(link string, rel string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, relation, ok := strings.Cut(part, ";")
+		if ok && strings.TrimSpace(relation) == `rel="`+rel+`"` {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
 
-	return *resultBuffer, nil
+// apiError returns the error of a response of the API, nil when it succeeded
+func apiError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return fmt.Errorf("api rate limit reached. used: %v, remaining: %v, reset time: %v", resp.Header.Get("X-RateLimit-Used"), resp.Header.Get("X-RateLimit-Remaining"), resp.Header.Get("X-RateLimit-Reset"))
+	case http.StatusNotFound:
+		return fmt.Errorf("user not found or you're not authorized to access this data")
+	default:
+		return fmt.Errorf("unexpected http status code: %d", resp.StatusCode)
+	}
 }
 
 // Checks if a file exists at the given path
@@ -412,20 +2760,13 @@ func fileExists(filePath string) bool {
 	return !errors.Is(err, os.ErrNotExist)
 }
 
-// Returns the size of the file at the given path.
-// Returns -1 if the file does not exist.
-func fileSize(filePath string) (int64, error) {
-	file, err := os.Open(filePath)
+// Returns how long ago the file at the given path was last written
+func fileAge(filePath string) (time.Duration, error) {
+	stat, err := os.Stat(filePath)
 	if err != nil {
-		return -1, err
+		return 0, err
 	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return -1, err
-	}
-	return stat.Size(), nil
+	return time.Since(stat.ModTime()), nil
 }
 
 func init() {
@@ -433,28 +2774,217 @@ func init() {
 	//   -h, --help
 	//     Show this message and exit.
 	//   -u, --user <handle>
-	//     Any GitHub handle. Example: link-
+	//     Any GitHub handle. Example: link-. Default is the user gh is logged in as
+	//   --input <file path>
+	//     Search the repositories of a JSON file instead of fetching the starred ones: the array of repositories of the API
+	//     or a cache file of gh stars. Nothing is fetched nor cached, --user isn't needed
 	//   -c, --cache-file <file path>
-	//     File you want to store the cache in. File should exist and be writable. If not provided, the tool will generate one in $TMPDIR
+	//     File you want to store the cache in, written once the repositories are fetched. Its missing directories are created, ~ is the home directory. If not provided, the tool will generate one in
+	//     $GH_STARS_CACHE_DIR or the user cache directory, e.g. ~/.cache/gh-stars
+	//   --no-cache
+	//     Fetch the repositories from the API without reading or writing any cache file
+	//   --cache-read-only
+	//     Read the cache files without ever writing, creating or deleting one
+	//   --refresh
+	//     Fetch the repositories from the API again and overwrite the cache file with them
+	//   --cache-ttl <duration>
+	//     Fetch the repositories again when the cache file is older than this, 0 never does. Default is 24h
+	//   --stale-ok
+	//     Search the cache file older than --cache-ttl at once, refreshing it in the background before exiting
+	//   --cache-max-age <duration>
+	//     Delete the previous cache files of the user when they are older than this, 0 never does. Default is 720h
+	//   --cache-max-size <size>
+	//     Delete the least recently used cache files when the cache directory takes more than this, e.g. 50MB, 0 never does.
+	//     Default is $GH_STARS_CACHE_MAX_SIZE or 200MB
 	//   -f, --find <keyword>
-	//     The keyword you want to search for. Example: es6
+	//     The keyword you want to search for. Example: es6. If not provided, every starred repository is listed
+	//     Repeat it to return the repositories matching any of the queries, use - to read one query per line from stdin
 	//   -l, --limit <number>
 	//     Limit the search results to the specified number. Default is 10
 	//	 -w, --table-max-width <number>
 	//	   The maximum width of the table that displays results if in table mode, default: 350
 	//   -j, --json
 	//     Prints the output in JSON format
+	//   --raw
+	//     Prints the repositories as they were fetched or cached, every field GitHub returns, without searching them
+	//   --match-all
+	//     Only return repositories that match all the search terms
+	//   --in <fields>
+	//     Comma separated list of fields to search in: name, owner, description, topics, homepage, readme. Default is all
+	//   --prefix
+	//     Match the words starting with the search terms instead of fuzzy matching
+	//   --fuzzy-ratio <number>
+	//     Maximum number of edits relative to the word length for a word to match a search term. Default is 1 edit
+	//     for 4 to 6 characters, 2 up to 10 and 3 above
+	//   --fuzzy-distance <number>
+	//     Maximum number of edits for a word to match a search term, 0 means exact. Implies --absolute-distance. Default is 2
+	//   --weights <field=weight,...>
+	//     Weight of the matches in each field, 0 skips the field. Default is name=40,owner=21,description=10,topics=1,homepage=1,readme=1
+	//   --aliases <file path>
+	//     File of alias=expansion pairs searched along with the built-in aliases like k8s=kubernetes
+	//   --homepage
+	//     Show the homepage of the repositories in a Homepage column
+	//   --include-readme
+	//     Also search the READMEs, fetched once and cached next to the cache file
+	//   --readme-limit <number>
+	//     Fetch every README up to this many starred repos, above it only those of the repos with a short description. Default is 300
+	//   --source <stars|watching|owned>
+	//     The repositories to search: the starred ones, the watched ones or the ones the user owns. Default is stars
+	//   --list <name>
+	//     Only search the repositories of one of the user's star lists. Example: tools
+	//   --ignore-file <file path>
+	//     File of owner/name or glob patterns of repositories never returned. Default is ~/.config/gh-stars/ignore
+	//   --exclude <patterns>
+	//     Comma separated list of owner/name or glob patterns of repositories left out of this search. Example: torvalds/linux,google/*
+	//   --only-owner <logins>
+	//     Comma separated list of owners, only their repositories are returned. Example: hashicorp,grafana
+	//   --owner <logins>
+	//     Same as --only-owner. Example: rust-lang
+	//   --exclude-owner <logins>
+	//     Comma separated list of owners whose repositories are never returned
+	//   --language <languages>
+	//     Comma separated list of languages, only the repositories written in one of them are returned. Example: go,rust
+	//   --min-stars <number>
+	//     Only return the repositories with this many stars or more
+	//   --max-stars <number>
+	//     Only return the repositories with this many stars or less
+	//   --min-open-issues <number>
+	//     Only return the repositories with this many open issues or more
+	//   --max-open-issues <number>
+	//     Only return the repositories with this many open issues or less
+	//   --no-archived
+	//     Never return the archived repositories
+	//   --archived-only
+	//     Only return the archived repositories, can't be combined with --no-archived
+	//   --no-templates
+	//     Never return the template repositories
+	//   --no-mirrors
+	//     Never return the mirrors of repositories hosted elsewhere
+	//   --topic <topics>
+	//     Comma separated list of topics, only the repositories with every one of them are returned. Example: kubernetes,operator
+	//   --topic-any <topics>
+	//     Comma separated list of topics, only the repositories with at least one of them are returned
+	//   --pushed-after <date>
+	//     Only return the repositories pushed to since the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago
+	//   --pushed-before <date>
+	//     Only return the repositories last pushed to before the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago
+	//   --starred-after <date>
+	//     Only return the repositories starred since the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago
+	//   --starred-before <date>
+	//     Only return the repositories starred before the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago
+	//   --filter <expression>
+	//     Only return the repositories the expression is true for. Example: 'stars > 1000 && language == "Go" && !fork'
+	//   --license <SPDX ids>
+	//     Comma separated list of SPDX license identifiers, only the repositories under one of them are returned. Example: MIT,Apache-2.0
+	//   --show-license
+	//     Show the SPDX identifier of the license of the repositories in a License column
+	//   --visibility <public|private|all>
+	//     Only return the public or the private repositories, default: all
+	//   --has-topics
+	//     Only return the repositories with at least one topic
+	//   --has-description
+	//     Never return the repositories without a description
+	//   --no-forks
+	//     Never return the forks
+	//   --forks-only
+	//     Only return the forks, can't be combined with --no-forks
+	//   --no-result-cache
+	//     Search again instead of reading the results of the same search from the result cache
+	//   --show-forks
+	//     List the forks found along with their upstream below it instead of counting them next to its name
+	//   --boost-recent
+	//     Rank the repositories pushed to recently above the ones matching as well but abandoned for years
+	//   --random
+	//     Print one of the results picked at random, or a random starred repository without --find
+	//   --seed <number>
+	//     Seed of --random, the same seed picks the same repository among the same results
+	//   -i, --interactive
+	//     Pick a repository in a full-screen list filtered as you type, its URL is printed on Enter
+	//   --gists
+	//     Search the starred gists of the authenticated user by description and filenames instead of the repositories
+	//   --explain
+	//     Show how the rank of every result was computed
+	//   --min-rank <number>
+	//     Drop the results with a lower rank, applied before --limit. Default is 0
+	//   --per-owner <number>
+	//     Only keep this many of the best results of every owner, applied before --limit. Default is 0, every result is kept
+	//   --absolute-distance
+	//     Use the absolute number of edits set by --fuzzy-distance instead of --fuzzy-ratio (deprecated)
 	//   -v, --version
 	//     Print current version
+	//   -q, --quiet
+	//     Suppresses the notices printed to stderr
 	//   -d, --debug
 	//     Outputs debugging log
-	rootCmd.Flags().StringVarP(&user, "user", "u", "", "GitHub handle of the user you want to search their stars (required)")
-	rootCmd.Flags().StringVarP(&find, "find", "f", "", "The keyword you want to search for (required)")
-	rootCmd.Flags().StringVarP(&cacheFile, "cache-file", "c", "", "File you want to store the cache file in. If not provided, the tool will generate one in $TMPDIR")
+	rootCmd.Flags().StringVarP(&user, "user", "u", "", "GitHub handle of the user you want to search their stars, default: the user gh is logged in as")
+	rootCmd.Flags().StringArrayVarP(&finds, "find", "f", []string{}, "The keyword you want to search for, repeat it to return the repositories matching any of the queries, - reads one query per line from stdin. If not provided, every starred repository is listed")
+	rootCmd.Flags().StringVar(&inputFile, "input", "", "Search the repositories of a JSON file instead of fetching the starred ones: the array of repositories of the API or a cache file of gh stars")
+	rootCmd.Flags().StringVarP(&cacheFile, "cache-file", "c", "", "File you want to store the cache file in, its missing directories are created. If not provided, the tool will generate one in $GH_STARS_CACHE_DIR or the user cache directory, e.g. ~/.cache/gh-stars")
+	rootCmd.Flags().BoolVar(&refresh, "refresh", false, "Fetch the repositories from the API again and overwrite the cache file with them, default: false")
+	rootCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", DEFAULT_CACHE_TTL, "Fetch the repositories again when the cache file is older than this, 0 never does, default: 24h")
+	rootCmd.Flags().BoolVar(&staleOK, "stale-ok", false, "Search the cache file older than --cache-ttl at once, refreshing it in the background before exiting, default: false")
+	rootCmd.Flags().DurationVar(&cacheMaxAge, "cache-max-age", DEFAULT_CACHE_MAX_AGE, "Delete the previous cache files of the user when they are older than this, 0 never does, default: 720h")
+	rootCmd.Flags().Var(&cacheMaxSize, "cache-max-size", "Delete the least recently used cache files when the cache directory takes more than this, e.g. 50MB, 0 never does, default: $GH_STARS_CACHE_MAX_SIZE or 200MB")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Fetch the repositories from the API without reading or writing any cache file, default: false")
+	rootCmd.Flags().BoolVar(&cacheReadOnly, "cache-read-only", false, "Read the cache files without ever writing, creating or deleting one, default: false")
 	rootCmd.Flags().IntVarP(&limit, "limit", "l", 10, "Limit the search results to the specified number, default: 10")
 	rootCmd.Flags().IntVarP(&tableMaxWidth, "table-max-width", "w", 350, "The maximum width of the table that displays results if in table mode, default: 350")
 	rootCmd.Flags().BoolVarP(&version, "version", "v", false, "Print current version")
 	rootCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "Prints the output in JSON format, default: false")
+	rootCmd.Flags().BoolVar(&raw, "raw", false, "Prints the repositories as they were fetched or cached, every field GitHub returns, without searching them, default: false")
+	rootCmd.Flags().BoolVar(&matchAll, "match-all", false, "Only return repositories that match all the search terms, default: false")
+	rootCmd.Flags().StringSliceVar(&searchIn, "in", []string{}, "Comma separated list of fields to search in: name, owner, description, topics, homepage, readme, default: all")
+	rootCmd.Flags().BoolVar(&prefixMatch, "prefix", false, "Match the words starting with the search terms instead of fuzzy matching, default: false")
+	rootCmd.Flags().Float64Var(&fuzzyRatio, "fuzzy-ratio", DEFAULT_FUZZY_RATIO, fmt.Sprintf("Maximum number of edits relative to the word length for a word to match a search term, e.g. %v, default: 1 edit for 4 to 6 characters, 2 up to 10 and 3 above", DEFAULT_FUZZY_RATIO))
+	rootCmd.Flags().IntVar(&fuzzyDistance, "fuzzy-distance", DEFAULT_FUZZY_DISTANCE, fmt.Sprintf("Maximum number of edits for a word to match a search term, between 0 (exact) and %d. Implies --absolute-distance, default: %d", MAX_FUZZY_DISTANCE, DEFAULT_FUZZY_DISTANCE))
+	rootCmd.Flags().StringToIntVar(&weights, "weights", map[string]int{}, "Weight of the matches in each field, 0 skips the field, default: name=40,owner=21,description=10,topics=1,homepage=1,readme=1")
+	rootCmd.Flags().StringVar(&aliasesFile, "aliases", "", "File of alias=expansion pairs searched along with the built-in aliases like k8s=kubernetes")
+	rootCmd.Flags().BoolVar(&showHomepage, "homepage", false, "Show the homepage of the repositories in a Homepage column, default: false")
+	rootCmd.Flags().BoolVar(&includeReadme, "include-readme", false, "Also search the READMEs, fetched once and cached next to the cache file, default: false")
+	rootCmd.Flags().IntVar(&readmeLimit, "readme-limit", DEFAULT_README_LIMIT, fmt.Sprintf("Fetch every README up to this many starred repos, above it only those of the repos with a short description, default: %d", DEFAULT_README_LIMIT))
+	rootCmd.Flags().StringVar(&source, "source", "stars", "The repositories to search: stars, watching or owned, default: stars")
+	rootCmd.Flags().StringVar(&starListName, "list", "", "Only search the repositories of one of the user's star lists, by name")
+	rootCmd.Flags().StringVar(&ignoreFile, "ignore-file", "", "File of owner/name or glob patterns of repositories never returned, default: ~/.config/gh-stars/ignore")
+	rootCmd.Flags().StringSliceVar(&excludes, "exclude", []string{}, "Comma separated list of owner/name or glob patterns of repositories left out of this search, like the ignore file")
+	rootCmd.Flags().StringSliceVar(&onlyOwners, "only-owner", []string{}, "Comma separated list of owners, only their repositories are returned")
+	rootCmd.Flags().StringSliceVar(&owners, "owner", []string{}, "Same as --only-owner, comma separated list of owners, only their repositories are returned")
+	rootCmd.Flags().StringSliceVar(&excludeOwners, "exclude-owner", []string{}, "Comma separated list of owners whose repositories are never returned")
+	rootCmd.Flags().StringSliceVar(&languages, "language", []string{}, "Comma separated list of languages, only the repositories written in one of them are returned, none for the ones without a language")
+	rootCmd.Flags().Var(&minStars, "min-stars", "Only return the repositories with this many stars or more")
+	rootCmd.Flags().Var(&maxStars, "max-stars", "Only return the repositories with this many stars or less")
+	rootCmd.Flags().Var(&minOpenIssues, "min-open-issues", "Only return the repositories with this many open issues or more")
+	rootCmd.Flags().Var(&maxOpenIssues, "max-open-issues", "Only return the repositories with this many open issues or less")
+	rootCmd.Flags().BoolVar(&noTemplates, "no-templates", false, "Never return the template repositories, default: false")
+	rootCmd.Flags().BoolVar(&noMirrors, "no-mirrors", false, "Never return the mirrors of repositories hosted elsewhere, default: false")
+	rootCmd.Flags().BoolVar(&noArchived, "no-archived", false, "Never return the archived repositories, default: false")
+	rootCmd.Flags().BoolVar(&archivedOnly, "archived-only", false, "Only return the archived repositories, can't be combined with --no-archived, default: false")
+	rootCmd.Flags().StringSliceVar(&allTopics, "topic", []string{}, "Comma separated list of topics, only the repositories with every one of them are returned")
+	rootCmd.Flags().StringSliceVar(&anyTopics, "topic-any", []string{}, "Comma separated list of topics, only the repositories with at least one of them are returned")
+	rootCmd.Flags().Var(&pushedAfter, "pushed-after", "Only return the repositories pushed to since the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago")
+	rootCmd.Flags().Var(&pushedBefore, "pushed-before", "Only return the repositories last pushed to before the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago")
+	rootCmd.Flags().Var(&starredAfter, "starred-after", "Only return the repositories starred since the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago")
+	rootCmd.Flags().StringVar(&filterText, "filter", "", "Only return the repositories the expression is true for, e.g. 'stars > 1000 && language == \"Go\" && !fork'")
+	rootCmd.Flags().Var(&starredBefore, "starred-before", "Only return the repositories starred before the date, e.g. 2023-01-01, or 30d, 2w, 6mo or 1y ago")
+	rootCmd.Flags().StringSliceVar(&licenses, "license", []string{}, "Comma separated list of SPDX license identifiers, only the repositories under one of them are returned, none for the ones without a license")
+	rootCmd.Flags().BoolVar(&showLicense, "show-license", false, "Show the SPDX identifier of the license of the repositories in a License column, default: false")
+	rootCmd.Flags().StringVar(&visibility, "visibility", "all", "Only return the public or the private repositories: public, private or all, default: all")
+	rootCmd.Flags().BoolVar(&hasTopics, "has-topics", false, "Only return the repositories with at least one topic, default: false")
+	rootCmd.Flags().BoolVar(&hasDesc, "has-description", false, "Never return the repositories without a description, default: false")
+	rootCmd.Flags().BoolVar(&noForks, "no-forks", false, "Never return the forks, default: false")
+	rootCmd.Flags().BoolVar(&forksOnly, "forks-only", false, "Only return the forks, can't be combined with --no-forks, default: false")
+	rootCmd.Flags().BoolVar(&noResultCache, "no-result-cache", false, "Search again instead of reading the results of the same search from the result cache, default: false")
+	rootCmd.Flags().BoolVar(&showForks, "show-forks", false, "List the forks found along with their upstream below it instead of counting them next to its name, default: false")
+	rootCmd.Flags().BoolVar(&boostRecent, "boost-recent", false, "Rank the repositories pushed to recently above the ones matching as well but abandoned for years, default: false")
+	rootCmd.Flags().BoolVar(&randomPick, "random", false, "Print one of the results picked at random, or a random starred repository without --find, default: false")
+	rootCmd.Flags().Int64Var(&seed, "seed", 0, "Seed of --random, the same seed picks the same repository among the same results, default: a new seed every run")
+	rootCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Pick a repository in a full-screen list filtered as you type, its URL is printed on Enter, default: false")
+	rootCmd.Flags().BoolVar(&gists, "gists", false, "Search the starred gists of the authenticated user by description and filenames instead of the repositories, default: false")
+	rootCmd.Flags().BoolVar(&explain, "explain", false, "Show how the rank of every result was computed, default: false")
+	rootCmd.Flags().IntVar(&minRank, "min-rank", 0, "Drop the results with a lower rank, applied before --limit, default: 0")
+	rootCmd.Flags().IntVar(&perOwner, "per-owner", 0, "Only keep this many of the best results of every owner, applied before --limit, default: 0, every result is kept")
+	rootCmd.Flags().BoolVar(&absoluteDist, "absolute-distance", false, "Use the absolute number of edits set by --fuzzy-distance instead of --fuzzy-ratio, default: false")
+	rootCmd.Flags().MarkDeprecated("absolute-distance", "it will be removed in the next release, use --fuzzy-ratio instead")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppresses the notices printed to stderr, default: false")
 	rootCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enables debug mode, default: false")
 	rootCmd.SetHelpTemplate(getRootHelp())
 }
@@ -475,25 +3005,99 @@ Fast fuzzy search for a keyword in your or any other user's GitHub starred repos
 Complete documentation is available at: https://github.com/Link-/gh-stars
 
 Synoposis:
-	gh stars -u <handle> -f <keyword> [flags]
+	gh stars [-u <handle>] [-f <keyword>] [flags]
 
 Usage:
 	gh stars -u <handle> -f <keyword>
 
-	You can search for a keyword in a user's starred repositories, these 2 flags are required.
+	You can search for a keyword in a user's starred repositories. Without a keyword, every
+	starred repository is listed, sorted by stars.
 
-Flags:
+	gh stars cache clear [-u <handle>] [--dry-run]
+	gh stars cache info [-u <handle>]
 
-	Required:
-	-u, --user <handle>          Any GitHub handle, e.g. Link-
-	-f, --find <keyword>         The keyword you want to search for, e.g. es6
+	You can delete the cache files or check what they hold, see gh stars cache --help.
+
+Flags:
 
 	Optional:
-	-c, --cache-file <file path> 	File you want to store the cache in. File should exist and be writable. If not provided, the tool will generate one in $TMPDIR
+	-u, --user <handle>          Any GitHub handle, e.g. Link-. Defaults to the user gh is logged in as
+	--input <file path>             Search the repositories of a JSON file instead of fetching the starred ones, nothing is fetched nor cached
+	                             The file holds the array of repositories of the API or a cache file of gh stars, e.g. --input stars.json
+	-f, --find <keyword>         The keyword you want to search for, e.g. es6. Prefix a term with - to exclude it, e.g. "http -client"
+	                             Prefix a term with a field to only search that field, e.g. "name:cli topic:golang parser"
+	                             Combine terms with AND, OR, NOT and parentheses, e.g. "rust AND (parser OR lexer) NOT bindings"
+	                             Use * and ? wildcards to match whole names and topics, e.g. "terraform-*-aws"
+	                             Repeat it to return the repositories matching any of the queries, e.g. -f "http client" -f "rest sdk"
+	                             Use - to read one query per line from stdin and get the results of each one, e.g. -f - < keywords.txt
+	-c, --cache-file <file path> 	File you want to store the cache in, written once the repositories are fetched. Its missing directories are created, ~ is the home directory. If not provided, the tool will generate one in
+	                             $GH_STARS_CACHE_DIR or the user cache directory, e.g. ~/.cache/gh-stars
+	--no-cache                      Fetch the repositories from the API without reading or writing any cache file
+	--cache-read-only               Read the cache files without ever writing, creating or deleting one
+	--refresh                       Fetch the repositories from the API again and overwrite the cache file with them
+	--cache-ttl <duration>          Fetch the repositories again when the cache file is older than this, e.g. 1h or 168h, 0 never does, default: 24h
+	--stale-ok                      Search the cache file older than --cache-ttl at once, refreshing it in the background before exiting
+	--cache-max-age <duration>      Delete the previous cache files of the user when they are older than this, 0 never does, default: 720h
+	--cache-max-size <size>         Delete the least recently used cache files when the cache directory takes more than this, e.g. 50MB,
+	                             0 never does, default: $GH_STARS_CACHE_MAX_SIZE or 200MB
 	-l, --limit <number>         	Limit the search results to the specified number, e.g. 10
 	-w, --table-max-width <number>  The maximum width of the table that displays results if in table mode, default: 350
 	-j, --json                      Outputs the results in JSON format
+	--raw                           Prints the repositories as they were fetched or cached, every field GitHub returns, e.g. --raw | jq
+	--match-all                     Only return repositories that match all the search terms
+	--in <fields>                   Comma separated list of fields to search in: name, owner, description, topics, homepage, readme, default: all
+	--prefix                        Match the words starting with the search terms instead of fuzzy matching
+	--fuzzy-ratio <number>          Maximum number of edits relative to the word length for a word to match a search term, e.g. 0.3
+	                                default: 1 edit for search terms of 4 to 6 characters, 2 up to 10 and 3 above
+	--fuzzy-distance <number>       Maximum number of edits for a word to match a search term, between 0 (exact) and 5. Implies --absolute-distance, default: 2
+	--weights <field=weight,...>    Weight of the matches in each field, 0 skips the field, default: name=40,owner=21,description=10,topics=1,homepage=1,readme=1
+	--aliases <file path>           File of alias=expansion pairs searched along with the built-in aliases like k8s=kubernetes
+	--homepage                      Show the homepage of the repositories in a Homepage column
+	--include-readme                Also search the READMEs, fetched once and cached next to the cache file
+	--readme-limit <number>         Fetch every README up to this many starred repos, above it only those with a short description, default: 300
+	--source <stars|watching|owned> The repositories to search: the starred ones, the watched ones or the user's own, default: stars
+	--list <name>                   Only search the repositories of one of the user's star lists, e.g. tools
+	--ignore-file <file path>       File of owner/name or glob patterns of repositories never returned, default: ~/.config/gh-stars/ignore
+	--exclude <patterns>            Comma separated list of owner/name or glob patterns of repositories left out of this search, e.g. 'google/*'
+	--only-owner <logins>           Comma separated list of owners, only their repositories are returned, e.g. hashicorp,grafana
+	--owner <logins>                Same as --only-owner, e.g. rust-lang
+	--exclude-owner <logins>        Comma separated list of owners whose repositories are never returned
+	--language <languages>          Comma separated list of languages, only the repositories written in one of them are returned, e.g. go,rust
+	--min-stars <number>            Only return the repositories with this many stars or more
+	--max-stars <number>            Only return the repositories with this many stars or less
+	--min-open-issues <number>      Only return the repositories with this many open issues or more
+	--max-open-issues <number>      Only return the repositories with this many open issues or less
+	--no-archived                   Never return the archived repositories
+	--archived-only                 Only return the archived repositories, can't be combined with --no-archived
+	--no-templates                  Never return the template repositories
+	--no-mirrors                    Never return the mirrors of repositories hosted elsewhere
+	--topic <topics>                Comma separated list of topics, only the repositories with every one of them are returned, e.g. kubernetes,operator
+	--topic-any <topics>            Comma separated list of topics, only the repositories with at least one of them are returned
+	--pushed-after <date>           Only return the repositories pushed to since the date, e.g. 2023-01-01 or 6mo for 6 months ago
+	--pushed-before <date>          Only return the repositories last pushed to before the date, e.g. 2023-01-01 or 1y for a year ago
+	--starred-after <date>          Only return the repositories starred since the date, e.g. 2024-01-01 or 30d for 30 days ago
+	--starred-before <date>         Only return the repositories starred before the date, e.g. 2024-01-01 or 1y for a year ago
+	--filter <expression>           Only return the repositories the expression is true for, see the README for the identifiers
+	--license <SPDX ids>            Comma separated list of SPDX license identifiers, only the repositories under one of them are returned, e.g. MIT,Apache-2.0
+	--show-license                  Show the SPDX identifier of the license of the repositories in a License column
+	--visibility <visibility>       Only return the public or the private repositories: public, private or all, default: all
+	--has-topics                    Only return the repositories with at least one topic
+	--has-description               Never return the repositories without a description
+	--no-forks                      Never return the forks
+	--forks-only                    Only return the forks, can't be combined with --no-forks
+	--no-result-cache               Search again instead of reading the results of the same search from the result cache
+	--show-forks                    List the forks found along with their upstream below it instead of counting them next to its name
+	--boost-recent                  Rank the repositories pushed to recently above the ones matching as well but abandoned for years
+	--random                        Print one of the results picked at random, or a random starred repository without --find
+	--seed <number>                 Seed of --random, the same seed picks the same repository among the same results
+	-i, --interactive               Pick a repository in a full-screen list filtered as you type, its URL is printed on Enter
+	                                Ctrl-O opens it in the browser instead, Esc leaves without picking
+	--gists                         Search the starred gists of the authenticated user by description and filenames instead
+	--explain                       Show how the rank of every result was computed
+	--min-rank <number>             Drop the results with a lower rank, applied before --limit, default: 0
+	--per-owner <number>            Only keep this many of the best results of every owner, applied before --limit, e.g. 2
 	-v, --version                	Outputs release version
+	-q, --quiet                     Suppresses the notices printed to stderr
 	-d, --debug                  	Outputs debugging log
 
 Examples:
@@ -501,9 +3105,15 @@ Examples:
 	# Search for es6 in Link-'s starred repositories
 	gh stars -u Link- -f es6
 
+	# Search for es6 in your own starred repositories, as the user gh is logged in as
+	gh stars -f es6
+
 	# Limit the results to 5
 	gh stars -u Link- -f es6 -l 5
 
+	# List the 20 most starred repositories Link- has starred
+	gh stars -u Link- -l 20
+
 	# Store the cache file in /tmp/.starscache
 	gh stars -u Link- -f es6 -c /tmp/.starscache
 
@@ -513,6 +3123,114 @@ Examples:
 	# Print the results in JSON format
 	gh stars -u Link- -f es6 -j
 
+	# Return the repositories matching either query, each one ranked by the query it matches best
+	gh stars -u Link- -f "http client" -f "rest sdk" --match-all
+
+	# Search for every keyword of a file, one per line, and print the results of each one
+	cat keywords.txt | gh stars -u Link- -f - -l 3
+
+	# Only return repositories matching both rust and parser
+	gh stars -u Link- -f "rust parser" --match-all
+
+	# Search for http but exclude repositories mentioning client
+	gh stars -u Link- -f "http -client"
+
+	# Only search the repository names and topics
+	gh stars -u Link- -f cli --in name,topics
+
+	# Search for cli in the names, golang in the topics and parser everywhere
+	gh stars -u Link- -f 'name:cli topic:golang parser'
+
+	# Search for the AWS modules of terraform
+	gh stars -u Link- -f 'terraform-*-aws'
+
+	# Search for rust parsers or lexers that are not bindings
+	gh stars -u Link- -f 'rust AND (parser OR lexer) NOT bindings'
+
+	# Only return exact matches
+	gh stars -u Link- -f cli --fuzzy-distance 0
+
+	# Rank matches in the topics higher than in the descriptions, and ignore the owners
+	gh stars -u Link- -f cli --weights topics=8,description=2,owner=0
+
+	# Show why a repository outranks another
+	gh stars -u Link- -f "static site generator" --explain
+
+	# Search for static site generators, the maintained ones first
+	gh stars -u Link- -f "static site generator" --boost-recent
+
+	# Pick something to read tonight among Link-'s starred repositories
+	gh stars -u Link- --random
+
+	# Only return the 5 best results ranked 500 or more
+	gh stars -u Link- -f cli --min-rank 500 -l 5
+
+	# Return the 10 best results, with at most 2 repositories of the same owner
+	gh stars -u Link- -f "language server" --per-owner 2
+
+	# Also search the READMEs, and rank the matches in the READMEs as high as in the topics
+	gh stars -u Link- -f "vector database" --include-readme --weights readme=2
+
+	# Pick a repository as you type, starting with the ones matching cli
+	gh stars -u Link- -f cli -i
+
+	# Search for cli in the repositories of Link-'s "tools" star list
+	gh stars -u Link- -f cli --list tools
+
+	# Search for terraform in the repositories of hashicorp and grafana only
+	gh stars -u Link- -f terraform --only-owner hashicorp,grafana
+
+	# Search for parser in the repositories of rust-lang
+	gh stars -u Link- -f parser --owner rust-lang
+
+	# Search for parser in the repositories written in Go or in Rust
+	gh stars -u Link- -f parser --language go,rust
+
+	# Surface the hidden gems: the CLI tools with 200 stars or less
+	gh stars -u Link- -f cli --max-stars 200
+
+	# Search for the yaml libraries that aren't drowning in issues
+	gh stars -u Link- -f yaml --max-open-issues 50
+
+	# Search for terraform modules that are still maintained
+	gh stars -u Link- -f terraform --no-archived
+
+	# List every repository with both the kubernetes and the operator topics
+	gh stars -u Link- --topic kubernetes --topic operator
+
+	# Search for the static site generators still pushed to in the last 6 months
+	gh stars -u Link- -f "static site generator" --pushed-after 6mo
+
+	# Search for that cli tool you starred last month
+	gh stars -u Link- -f cli --starred-after 30d
+
+	# Search for the popular http servers written in Go, leaving out the forks
+	gh stars -u Link- -f "http server" --filter 'stars > 1000 && language == "Go" && !fork'
+
+	# Search for the yaml parsers you can vendor at work, with their license
+	gh stars -u Link- -f "yaml parser" --license MIT,Apache-2.0 --show-license
+
+	# List the 20 most starred repositories with both topics and a description
+	gh stars -u Link- -l 20 --has-topics --has-description
+
+	# Find which forks of a project you starred
+	gh stars -u Link- -f "shell linter" --forks-only
+
+	# List every starred repository that has since been archived
+	gh stars -u Link- --archived-only
+
+	# Search the repositories Link- is watching
+	gh stars -u Link- -f cli --source watching
+
+	# Find which of Link-'s own repositories was about actions
+	gh stars -u Link- -f actions --source owned
+
+	# Search the gists Link- has starred, Link- being the authenticated user
+	gh stars -u Link- -f dotfiles --gists
+
+	# Find kubectl, kubernetes, kubeadm...
+	gh stars -u Link- -f kube --prefix
+
 	# Print current version
 	gh stars -v
 `
@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+const STAR_MEDIA_TYPE = "application/vnd.github.star+json" // Media type listing the starred repos with the date they were starred
+
+// unwrapStars turns the starred repos listed with STAR_MEDIA_TYPE, where every repo is
+// wrapped in an object along with its starred_at date, into a list of repos like the other
+// sources. The date is kept in the starred_at field of every repo.
+//
+// Example: [{"starred_at": "2024-01-01T00:00:00Z", "repo": {"name": "cobra", ...}}] becomes
+// [{"name": "cobra", ..., "starred_at": "2024-01-01T00:00:00Z"}]
+func unwrapStars(data []byte) ([]byte, error) {
+	var stars []struct {
+		Starred_at string          `json:"starred_at"`
+		Repo       json.RawMessage `json:"repo"`
+	}
+	if err := json.Unmarshal(data, &stars); err != nil {
+		return nil, err
+	}
+
+	repos := make([]map[string]json.RawMessage, 0, len(stars))
+	for _, star := range stars {
+		var repo map[string]json.RawMessage
+		if err := json.Unmarshal(star.Repo, &repo); err != nil {
+			return nil, err
+		}
+		starredAt, err := json.Marshal(star.Starred_at)
+		if err != nil {
+			return nil, err
+		}
+		repo["starred_at"] = starredAt
+		repos = append(repos, repo)
+	}
+	return json.Marshal(repos)
+}
+
+// hasStarDates reports whether the repos were fetched with the date they were starred, a
+// user without any starred repo has nothing to fetch again
+func hasStarDates(repos []Repo) bool {
+	for _, repo := range repos {
+		if repo.Starred_at != "" {
+			return true
+		}
+	}
+	return len(repos) == 0
+}
+
+// RefetchStarredRepos fetches the starred repos again whatever the cache file holds, and
+// overwrites it with them, see --refresh. The cache file is kept as is when they can't be
+// fetched. Without a cache file, they are fetched like every time.
+func RefetchStarredRepos(user string, cacheKey [32]byte) (bytes.Buffer, error) {
+	path, err := GetCachePath(cacheKey)
+	if errors.Is(err, errCacheBypassed) {
+		return GetStarredRepos(user, cacheKey)
+	}
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	unlock, err := lockCacheFile(path)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	defer unlock()
+	InfoLogger.Printf("Fetching the repos in the %s of %s again, rewriting the cache file: %s", source, user, path)
+	return refetchCache(user, path, cacheValidator{})
+}
+
+// refetchCache fetches the repos with the validators of the cache file, see fetchRepoPages,
+// and overwrites the cache file with them and their validators. The caller holds its lock.
+func refetchCache(user string, path string, cached cacheValidator) (bytes.Buffer, error) {
+	starred, validator, err := fetchRepoPages(user, cached)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	if err := writeCache(path, user, starred, validator); err != nil {
+		return bytes.Buffer{}, err
+	}
+	return starred, nil
+}
+
+// revalidateCache asks GitHub whether the repos of the cache file changed since they were
+// cached, requesting their first page on the conditions of the validator, see setConditions.
+// The cache file is updated when they did, see updateCache. When GitHub answers they didn't,
+// with the ETag or the date alike, the cached repos are returned: the cache file was checked
+// now, and the validator is stored next to it when it wasn't, see fetchedValidator.
+func revalidateCache(user string, path string, cached []byte, validator cacheValidator) (bytes.Buffer, error) {
+	starred, err := updateCache(user, path, cached, validator)
+	if !errors.Is(err, errNotModified) {
+		if err == nil {
+			InfoLogger.Println("The repos changed, the cache file was rewritten")
+		}
+		return starred, err
+	}
+	InfoLogger.Println("The repos didn't change, reading from the cache file:", path)
+	if !skipCacheWrite(path) {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		if stored, err := readCacheValidator(path); err != nil || stored != validator {
+			if err := writeCacheValidator(path, validator); err != nil {
+				WarnLogger.Printf("Not able to write the validators of the cache file: %v", err)
+			}
+		} else {
+			// The cache file expires --cache-ttl after it was checked, see cacheEnvelope.age
+			os.Chtimes(validatorPath(path), now, now)
+		}
+	}
+	return *bytes.NewBuffer(cached), nil
+}
+
+// backgroundRefresh tracks the refresh of the expired cache file whose repos are searched
+// meanwhile, see refreshInBackground
+var backgroundRefresh sync.WaitGroup
+
+// refreshInBackground revalidates the expired cache file in the background, see
+// revalidateCache, while its stale repos are searched, see --stale-ok. The cache file is
+// replaced at once once the repos are fetched, see writeCacheFile. The search waits for it
+// before exiting, see waitForBackgroundRefresh, even on an error, see fatalAfterRefresh.
+func refreshInBackground(user string, path string, cached []byte, validator cacheValidator, age time.Duration) {
+	WarnLogger.Printf("The results may be up to %s old, the cache file is refreshed in the background", age.Round(time.Minute))
+	backgroundRefresh.Add(1)
+	go func() {
+		defer backgroundRefresh.Done()
+		if _, err := revalidateCache(user, path, cached, validator); err != nil {
+			WarnLogger.Printf("Not able to refresh the cache file in the background, it is refreshed on the next search: %v", err)
+		}
+	}()
+}
+
+// waitForBackgroundRefresh waits for the cache file refreshed in the background to be
+// written, see refreshInBackground. An interrupt abandons the refresh and exits: the cache
+// file is left as it was, it is refreshed on the next search.
+func waitForBackgroundRefresh() {
+	done := make(chan struct{})
+	go func() {
+		backgroundRefresh.Wait()
+		close(done)
+	}()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	select {
+	case <-done:
+	case <-interrupt:
+		WarnLogger.Println("Interrupted, the cache file is refreshed on the next search")
+		os.Exit(130)
+	}
+}
+
+// fatalAfterRefresh logs the error like ErrorLogger.Fatal and exits once the cache file
+// refreshed in the background is written, see waitForBackgroundRefresh, rather than leaving
+// it and its validators half written
+func fatalAfterRefresh(v ...any) {
+	ErrorLogger.Output(2, fmt.Sprint(v...))
+	waitForBackgroundRefresh()
+	os.Exit(1)
+}
+
+// updateCache fetches the repos starred since the newest repo of the cache file and writes
+// them to the cache file ahead of the cached ones. The starred repos are listed newest
+// first, only the first pages are fetched, the first one with the validators of the cache
+// file. The repos unstarred since can't be told that way: every repo is fetched again when
+// the cached and the new repos don't add up to the number of starred repos. The repos of
+// the other sources, and the caches written before the star dates were fetched, are always
+// fetched again.
+func updateCache(user string, path string, cached []byte, validator cacheValidator) (bytes.Buffer, error) {
+	unlock, err := lockCacheFile(path)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	defer unlock()
+
+	var repos []Repo
+	var newest time.Time
+	if err := json.Unmarshal(cached, &repos); err == nil && sources[source].accept == STAR_MEDIA_TYPE {
+		for _, repo := range repos {
+			if repo.StarredAt().After(newest) {
+				newest = repo.StarredAt()
+			}
+		}
+	}
+	if newest.IsZero() {
+		InfoLogger.Println("The cache file has no star dates, fetching every repo again")
+		return refetchCache(user, path, validator)
+	}
+
+	added, pageValidator, complete, err := fetchStarredSince(user, newest, validator)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	starredSince := len(added)
+	if !complete {
+		total, err := countRepos(user)
+		if err != nil {
+			return bytes.Buffer{}, err
+		}
+		if len(added)+len(repos) != total {
+			InfoLogger.Printf("%d repos were starred since the %d cached ones, but %d are starred: fetching every repo again", len(added), len(repos), total)
+			return refetchCache(user, path, cacheValidator{})
+		}
+		var kept []json.RawMessage
+		if err := json.Unmarshal(cached, &kept); err != nil {
+			return bytes.Buffer{}, err
+		}
+		added = append(added, kept...)
+	}
+
+	InfoLogger.Printf("Writing the %d repos starred since %s to the cache file: %s", starredSince, newest.Format(time.RFC3339), path)
+	data, err := json.Marshal(added)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	starred := *bytes.NewBuffer(data)
+	if err := writeCache(path, user, starred, pageValidator); err != nil {
+		return bytes.Buffer{}, err
+	}
+	return starred, nil
+}
+
+// fetchStarredSince fetches the pages of the starred repos until the first repo starred at
+// or before the given date, and returns the repos starred after it, newest first, along with
+// the validators of the first page. It is complete when every repo was starred after it.
+func fetchStarredSince(user string, since time.Time, cached cacheValidator) ([]json.RawMessage, cacheValidator, bool, error) {
+	stars := []json.RawMessage{}
+	var validator cacheValidator
+	complete := true
+	next := firstPage(user, REPOS_PER_PAGE)
+	for page := 1; next != "" && complete; page++ {
+		conditions := cacheValidator{}
+		if page == 1 {
+			conditions = cached
+		}
+		items, link, pageValidator, err := fetchRepoPage(next, conditions)
+		if err != nil {
+			return nil, cacheValidator{}, false, err
+		}
+		if page == 1 {
+			validator = pageValidator
+		}
+		for _, item := range items {
+			var star struct {
+				Starred_at string `json:"starred_at"`
+			}
+			if err := json.Unmarshal(item, &star); err != nil {
+				return nil, cacheValidator{}, false, err
+			}
+			starredAt, err := time.Parse(time.RFC3339, star.Starred_at)
+			if err != nil {
+				return nil, cacheValidator{}, false, fmt.Errorf("not able to read the star dates: %w", err)
+			}
+			if !starredAt.After(since) {
+				complete = false
+				break
+			}
+			stars = append(stars, item)
+		}
+		next = pageLink(link, "next")
+	}
+
+	// The repos are listed along with their star date, see unwrapStars
+	wrapped, err := json.Marshal(stars)
+	if err != nil {
+		return nil, cacheValidator{}, false, err
+	}
+	unwrapped, err := unwrapStars(wrapped)
+	if err != nil {
+		return nil, cacheValidator{}, false, fmt.Errorf("not able to read the star dates: %w", err)
+	}
+	var repos []json.RawMessage
+	if err := json.Unmarshal(unwrapped, &repos); err != nil {
+		return nil, cacheValidator{}, false, err
+	}
+	return repos, validator, complete, nil
+}
+
+// A cacheValidator holds the validators GitHub returned along with the first page of the
+// repos of a cache file, stored next to it. The first page is requested with them, GitHub
+// answers that it didn't change without counting the request against the rate limit.
+type cacheValidator struct {
+	Etag          string `json:"etag,omitempty"`
+	Last_modified string `json:"last_modified,omitempty"`
+}
+
+// fetchedValidator returns the validator of the repos of an envelope written without any,
+// like when a proxy stripped the ETag and the Last-Modified headers: the date they were
+// fetched, sent in If-Modified-Since. It is empty when the envelope doesn't tell.
+func (c cacheEnvelope) fetchedValidator() cacheValidator {
+	fetchedAt, err := time.Parse(time.RFC3339, c.Fetched_at)
+	if err != nil {
+		return cacheValidator{}
+	}
+	return cacheValidator{Last_modified: fetchedAt.UTC().Format(http.TimeFormat)}
+}
+
+// setConditions makes the request conditional on the validators, the ETag is preferred
+func (v cacheValidator) setConditions(req *http.Request) {
+	if v.Etag != "" {
+		req.Header.Set("If-None-Match", v.Etag)
+	} else if v.Last_modified != "" {
+		req.Header.Set("If-Modified-Since", v.Last_modified)
+	}
+}
+
+// validatorPath returns the path of the validators of the cache file, next to it
+//
+// Example: ~/.cache/gh-stars/stars_link-_2d06a89b2687.etag.json for ~/.cache/gh-stars/stars_link-_2d06a89b2687.json.gz
+func validatorPath(path string) string {
+	return trimCacheExt(path) + ".etag.json"
+}
+
+// readCacheValidator returns the validators of the cache file, an error when it has none
+func readCacheValidator(path string) (cacheValidator, error) {
+	data, err := os.ReadFile(validatorPath(path))
+	if err != nil {
+		return cacheValidator{}, err
+	}
+	var validator cacheValidator
+	if err := json.Unmarshal(data, &validator); err != nil {
+		return cacheValidator{}, err
+	}
+	if validator == (cacheValidator{}) {
+		return cacheValidator{}, errors.New("the cache file has no validator")
+	}
+	return validator, nil
+}
+
+// writeCache writes the repos of the user to the cache file in an envelope, see
+// writeCacheFile, and their validators next to it. The validators of the previous repos
+// are removed when there are none. The stale cache files of the user are deleted then, see
+// evictStaleCaches, and the least recently used ones above the size budget, see trimCacheDir.
+func writeCache(path string, user string, repos bytes.Buffer, validator cacheValidator) error {
+	if skipCacheWrite(path) {
+		return nil
+	}
+	cache, err := newCacheEnvelope(user, repos.Bytes())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := writeCacheFile(path, data); err != nil {
+		return err
+	}
+	if err := writeCacheValidator(path, validator); err != nil {
+		return err
+	}
+	if err := evictStaleCaches(path, user, time.Now()); err != nil {
+		WarnLogger.Printf("Not able to delete the stale cache files: %v", err)
+	}
+	if err := trimCacheDir(path); err != nil {
+		WarnLogger.Printf("Not able to trim the cache directory: %v", err)
+	}
+	return nil
+}
+
+// writeCacheValidator writes the validators next to the cache file, removing them when
+// there are none
+func writeCacheValidator(path string, validator cacheValidator) error {
+	if skipCacheWrite(validatorPath(path)) {
+		return nil
+	}
+	if validator == (cacheValidator{}) {
+		if err := os.Remove(validatorPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(validator)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(validatorPath(path), data, 0644)
+}
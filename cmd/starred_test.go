@@ -0,0 +1,662 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockStarsAPI lists the starred repos with their star date, pageSize at most on every page,
+// answering that they didn't change when the first page is requested with its ETag, or fails
+type mockStarsAPI struct {
+	mu          sync.Mutex
+	stars       []string      // Starred repos as listed with STAR_MEDIA_TYPE, newest first, see mockStar
+	pageSize    int           // Number of repos listed at most on every page
+	urls        []string      // URL of every request
+	fetched     int           // Number of first pages listed
+	revalidated int           // Number of first pages that didn't change
+	counted     int           // Number of times the repos were counted, see countRepos
+	status      int           // Status of every response when set
+	delay       time.Duration // Delay of every response
+	// Date the repos last changed, returned in Last-Modified and compared to If-Modified-Since
+	lastModified      string
+	stripEtag         bool // Whether the ETag is stripped from the responses, like some proxies do
+	stripLastModified bool // Whether Last-Modified is stripped from the responses
+}
+
+// mockStar returns a repo starred at the given date as listed with STAR_MEDIA_TYPE
+func mockStar(name string, starredAt string) string {
+	return fmt.Sprintf(`{"starred_at":%q,"repo":{"name":%q}}`, starredAt, name)
+}
+
+func (m *mockStarsAPI) RoundTrip(req *http.Request) *http.Response {
+	time.Sleep(m.delay)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.urls = append(m.urls, req.URL.String())
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+	if m.status != 0 {
+		resp.StatusCode = m.status
+		return resp
+	}
+	perPage := req.URL.Query().Get("per_page")
+	size, _ := strconv.Atoi(perPage)
+	if size > m.pageSize {
+		size = m.pageSize
+	}
+	page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	from, to := (page-1)*size, page*size
+	if from > len(m.stars) {
+		from = len(m.stars)
+	}
+	if to > len(m.stars) {
+		to = len(m.stars)
+	}
+	body := "[" + strings.Join(m.stars[from:to], ",") + "]"
+
+	etag := fmt.Sprintf(`W/"%x"`, sha256.Sum256([]byte(body)))
+	switch {
+	case perPage == "1":
+		m.counted++
+	case page == 1 && req.Header.Get("If-None-Match") == etag && !m.stripEtag:
+		m.revalidated++
+		resp.StatusCode = http.StatusNotModified
+		return resp
+	case page == 1 && req.Header.Get("If-None-Match") == "" && m.notModifiedSince(req.Header.Get("If-Modified-Since")):
+		m.revalidated++
+		resp.StatusCode = http.StatusNotModified
+		return resp
+	case page == 1:
+		m.fetched++
+		if !m.stripEtag {
+			resp.Header.Set("ETag", etag)
+		}
+		if m.lastModified != "" && !m.stripLastModified {
+			resp.Header.Set("Last-Modified", m.lastModified)
+		}
+	}
+	if last := (len(m.stars) + size - 1) / size; page < last {
+		pageURL := "<https://api.github.com/user/1/starred?per_page=" + perPage + "&page=%d>; rel=%q"
+		resp.Header.Set("Link", fmt.Sprintf(pageURL+", "+pageURL, page+1, "next", last, "last"))
+	}
+	resp.Body = io.NopCloser(strings.NewReader(body))
+	return resp
+}
+
+// notModifiedSince reports whether the repos didn't change since the date of If-Modified-Since
+func (m *mockStarsAPI) notModifiedSince(since string) bool {
+	sinceTime, err := http.ParseTime(since)
+	if err != nil || m.lastModified == "" {
+		return false
+	}
+	modified, err := http.ParseTime(m.lastModified)
+	return err == nil && !modified.After(sinceTime)
+}
+
+// useStarsAPI makes the HTTP client list two starred repos, one per page, with a mockStarsAPI
+func useStarsAPI(t *testing.T) *mockStarsAPI {
+	api := &mockStarsAPI{
+		stars:    []string{mockStar("cobra", "2024-01-01T10:00:00Z"), mockStar("clap", "2023-06-01T10:00:00Z")},
+		pageSize: 1,
+	}
+	client = NewTestClient(api.RoundTrip)
+	t.Cleanup(func() { client = &http.Client{} })
+	return api
+}
+
+func TestUnwrapStars(t *testing.T) {
+	got, err := unwrapStars([]byte(`[{"starred_at":"2024-01-01T10:00:00Z","repo":{"name":"cobra","stargazers_count":3}}]`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"name":"cobra","stargazers_count":3,"starred_at":"2024-01-01T10:00:00Z"}]`, string(got))
+
+	got, err = unwrapStars([]byte(`[]`))
+	assert.NoError(t, err)
+	assert.Equal(t, `[]`, string(got))
+
+	_, err = unwrapStars([]byte(`[{"name":"cobra","repo":"not a repo"}]`))
+	assert.Error(t, err)
+}
+
+func TestStarredFilters(t *testing.T) {
+	setup([]string{})
+	testData := loadTestData(t, "testdata/starred_repos.json")
+	defer func() { starredAfter, starredBefore = dateFlag{}, dateFlag{} }()
+
+	tests := []struct {
+		name          string
+		starredAfter  string
+		starredBefore string
+		wantRepos     []string
+	}{
+		{name: "NoFilter", wantRepos: []string{"spf13/cobra", "clap-rs/clap", "oclif/oclif", "agarrharr/awesome-cli-apps"}},
+		// The repos without a star date are dropped
+		{name: "AfterIncludesTheDate", starredAfter: "2024-01-01", wantRepos: []string{"spf13/cobra"}},
+		{name: "BeforeExcludesTheDate", starredBefore: "2024-01-01", wantRepos: []string{"clap-rs/clap", "oclif/oclif"}},
+		{name: "Between", starredAfter: "2023-01-01", starredBefore: "2023-12-31", wantRepos: []string{"clap-rs/clap"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starredAfter, starredBefore = dateFlag{}, dateFlag{}
+			if tt.starredAfter != "" {
+				assert.NoError(t, starredAfter.Set(tt.starredAfter))
+			}
+			if tt.starredBefore != "" {
+				assert.NoError(t, starredBefore.Set(tt.starredBefore))
+			}
+			assert.NoError(t, validateSearchOptions())
+			found, err := Search(testData, "cli")
+			assert.NoError(t, err)
+			kept, _ := ApplyFilters(found, activeFilters())
+			assert.ElementsMatch(t, tt.wantRepos, uniqueRepos(kept))
+		})
+	}
+
+	assert.NoError(t, starredAfter.Set("2024-01-01"))
+	assert.NoError(t, starredBefore.Set("2023-01-01"))
+	assert.ErrorContains(t, validateSearchOptions(), "--starred-after 2024-01-01 is not before --starred-before 2023-01-01")
+
+	starredBefore = dateFlag{}
+	source = "watching"
+	defer func() { source = "stars" }()
+	assert.ErrorContains(t, validateSearchOptions(), "--starred-after and --starred-before only work with --source stars")
+}
+
+func TestHasStarDates(t *testing.T) {
+	assert.True(t, hasStarDates(nil), "nothing to fetch again without starred repos")
+	assert.True(t, hasStarDates(loadRepos(t, "testdata/starred_repos.json")))
+	assert.False(t, hasStarDates(loadRepos(t, "testdata/language_repos.json")), "caches written without the star dates")
+}
+
+func TestRefetchStarredRepos(t *testing.T) {
+	setup([]string{})
+	api := useStarsAPI(t)
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile = "" }()
+
+	// A cache written before the star dates were fetched
+	assert.NoError(t, os.WriteFile(cacheFile, []byte(`[{"name":"cobra"},{"name":"clap"}]`), 0644))
+	starred, err := RefetchStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	repos, err := DecodeRepos(starred)
+	assert.NoError(t, err)
+	assert.True(t, hasStarDates(repos))
+	assert.Equal(t, 1, api.fetched)
+	cached, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, starred.String(), string(cached.Repos))
+
+	// The repos are fetched again even though the cache isn't empty, see --refresh
+	assert.NoError(t, os.WriteFile(cacheFile, []byte(`[{"name":"cobra","description":"stale"}]`), 0644))
+	starred, err = RefetchStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, api.fetched, "without revalidating the cache")
+	cached, err = readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, starred.String(), string(cached.Repos))
+	assert.NotContains(t, string(cached.Repos), "stale")
+
+	// The cache is kept when the repos can't be fetched
+	api.status = http.StatusBadGateway
+	_, err = RefetchStarredRepos("Link-", [32]byte{})
+	assert.Error(t, err)
+	kept, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, cached, kept)
+}
+
+func TestCacheTTL(t *testing.T) {
+	setup([]string{})
+	api := useStarsAPI(t)
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile, cacheTTL = "", DEFAULT_CACHE_TTL }()
+
+	// A cache written without validators, see TestRevalidateCache
+	stale := `[{"name":"cobra","description":"stale","starred_at":"2023-01-01T10:00:00Z"}]`
+	writeStale := func(age time.Duration) {
+		assert.NoError(t, os.WriteFile(cacheFile, []byte(stale), 0644))
+		assert.NoError(t, os.RemoveAll(validatorPath(cacheFile)))
+		written := time.Now().Add(-age)
+		assert.NoError(t, os.Chtimes(cacheFile, written, written))
+	}
+
+	// A cache younger than the TTL is read
+	writeStale(time.Hour)
+	starred, err := GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, stale, starred.String())
+	assert.Empty(t, api.urls)
+
+	// An expired cache is fetched again and rewritten
+	writeStale(25 * time.Hour)
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Contains(t, starred.String(), "starred_at")
+	assert.Equal(t, 1, api.fetched)
+	cached, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, starred.String(), string(cached.Repos))
+
+	// The expired cache is read when the repos can't be fetched
+	writeStale(25 * time.Hour)
+	api.status = http.StatusBadGateway
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, stale, starred.String())
+	assert.Len(t, api.urls, 3)
+
+	// A TTL of 0 never expires the cache
+	cacheTTL = 0
+	writeStale(24 * 365 * time.Hour)
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, stale, starred.String())
+	assert.Len(t, api.urls, 3)
+
+	cacheTTL = -time.Hour
+	assert.ErrorContains(t, validateSearchOptions(), "--cache-ttl must be positive")
+}
+
+func TestStaleOK(t *testing.T) {
+	setup([]string{})
+	api := useStarsAPI(t)
+	api.delay = 50 * time.Millisecond
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	staleOK = true
+	defer func() { cacheFile, staleOK = "", false }()
+	want := `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`
+	stale := `[{"name":"cobra","description":"stale","starred_at":"2024-01-01T10:00:00Z"}]`
+	cache, err := newCacheEnvelope("Link-", []byte(stale))
+	assert.NoError(t, err)
+	cache.Fetched_at = time.Now().Add(-25 * time.Hour).UTC().Format(time.RFC3339)
+	data, err := json.Marshal(cache)
+	assert.NoError(t, err)
+	assert.NoError(t, writeCacheFile(cacheFile, data))
+
+	// The expired cache is read at once, and rewritten in the background
+	starred, err := GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, stale, starred.String())
+	waitForBackgroundRefresh()
+	fetched := len(api.urls)
+	assert.NotZero(t, fetched)
+	cached, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, want, string(cached.Repos))
+
+	// Once expired again, the cache written along with its ETag is read at once too, and
+	// checked with GitHub in the background with the ETag
+	_, err = os.Stat(validatorPath(cacheFile))
+	assert.NoError(t, err)
+	cached.Fetched_at = time.Now().Add(-25 * time.Hour).UTC().Format(time.RFC3339)
+	data, err = json.Marshal(cached)
+	assert.NoError(t, err)
+	assert.NoError(t, writeCacheFile(cacheFile, data))
+	expired := time.Now().Add(-25 * time.Hour)
+	assert.NoError(t, os.Chtimes(cacheFile, expired, expired))
+	assert.NoError(t, os.Chtimes(validatorPath(cacheFile), expired, expired))
+	started := time.Now()
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(started), api.delay, "the search doesn't wait for GitHub")
+	assert.Equal(t, want, starred.String())
+	waitForBackgroundRefresh()
+	assert.Len(t, api.urls, fetched+1)
+	assert.Equal(t, 1, api.revalidated)
+}
+
+// An error exits once the cache file refreshed in the background is written. The exit is
+// run in a child process of the test binary.
+func TestFatalAfterRefresh(t *testing.T) {
+	if path := os.Getenv("GH_STARS_TEST_FATAL"); path != "" {
+		setup([]string{})
+		api := useStarsAPI(t)
+		api.delay = 100 * time.Millisecond
+		cacheFile = path
+		cache, err := readCache(cacheFile)
+		assert.NoError(t, err)
+		refreshInBackground("Link-", cacheFile, cache.Repos, cacheValidator{}, 25*time.Hour)
+		fatalAfterRefresh("Not able to read the ignore file")
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "stars.json")
+	cache, err := newCacheEnvelope("Link-", []byte(`[{"name":"cobra","description":"stale","starred_at":"2024-01-01T10:00:00Z"}]`))
+	assert.NoError(t, err)
+	data, err := json.Marshal(cache)
+	assert.NoError(t, err)
+	assert.NoError(t, writeCacheFile(path, data))
+
+	child := exec.Command(os.Args[0], "-test.run=^TestFatalAfterRefresh$")
+	child.Env = append(os.Environ(), "GH_STARS_TEST_FATAL="+path, CACHE_DIR_ENV+"="+t.TempDir())
+	output, err := child.CombinedOutput()
+	var exitErr *exec.ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+	assert.Contains(t, string(output), "Not able to read the ignore file")
+	cached, err := readCache(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`, string(cached.Repos))
+	// Along with its validators, and no temporary file is left
+	assert.ElementsMatch(t, []string{"stars.json", "stars.etag.json", "stars.json.lock"}, cacheFileNames(t, filepath.Dir(path)))
+}
+
+func TestRevalidateCache(t *testing.T) {
+	setup([]string{})
+	api := useStarsAPI(t)
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	defer func() { cacheFile, cacheTTL = "", DEFAULT_CACHE_TTL }()
+	want := `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`
+
+	// The validators of the first page are written along with the repos
+	assert.NoError(t, os.WriteFile(cacheFile, nil, 0644))
+	starred, err := GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, starred.String())
+	assert.Equal(t, []string{
+		"https://api.github.com/users/Link-/starred?per_page=100",
+		"https://api.github.com/user/1/starred?per_page=100&page=2",
+	}, api.urls)
+	validator, err := readCacheValidator(cacheFile)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, validator.Etag)
+	assert.Empty(t, validator.Last_modified)
+	etag := validator.Etag
+
+	// The cache is read without asking GitHub until it expires, see TestCacheTTL
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, starred.String())
+	assert.Len(t, api.urls, 2)
+
+	// Once expired, the cache is read when the repos didn't change, and expires
+	// --cache-ttl after that
+	written := time.Now().Add(-25 * time.Hour)
+	assert.NoError(t, os.Chtimes(cacheFile, written, written))
+	cacheTTL = time.Nanosecond
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, starred.String())
+	assert.Equal(t, 1, api.fetched)
+	assert.Equal(t, 1, api.revalidated)
+	age, err := fileAge(cacheFile)
+	assert.NoError(t, err)
+	assert.Less(t, age, time.Hour, "the cache was revalidated")
+	cached, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	age, err = cached.age(cacheFile)
+	assert.NoError(t, err)
+	assert.Less(t, age, time.Hour, "the validators were touched")
+
+	// The cache is rewritten when they changed
+	assert.NoError(t, writeCache(cacheFile, "Link-", *bytes.NewBufferString(`[{"name":"stale"}]`), cacheValidator{Etag: `W/"changed"`}))
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, starred.String())
+	assert.Equal(t, 2, api.fetched)
+	cached, err = readCache(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, want, string(cached.Repos))
+	validator, err = readCacheValidator(cacheFile)
+	assert.NoError(t, err)
+	assert.Equal(t, etag, validator.Etag)
+
+	// The cache is read when the repos can't be fetched
+	api.status = http.StatusBadGateway
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, starred.String())
+
+	// Without an ETag, the first page is requested with its date
+	api.status = http.StatusNotModified
+	lastModified := "Mon, 01 Jan 2024 10:00:00 GMT"
+	assert.NoError(t, writeCache(cacheFile, "Link-", *bytes.NewBufferString(want), cacheValidator{Last_modified: lastModified}))
+	var conditions http.Header
+	client = NewTestClient(func(req *http.Request) *http.Response {
+		conditions = req.Header
+		return api.RoundTrip(req)
+	})
+	starred, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, starred.String())
+	assert.Equal(t, lastModified, conditions.Get("If-Modified-Since"))
+	assert.Empty(t, conditions.Get("If-None-Match"))
+
+	// The validators are removed along with the repos they were returned with
+	assert.NoError(t, writeCache(cacheFile, "Link-", *bytes.NewBufferString(want), cacheValidator{}))
+	assert.NoFileExists(t, validatorPath(cacheFile))
+}
+
+func TestRevalidateCacheLastModified(t *testing.T) {
+	want := `[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"},{"name":"clap","starred_at":"2023-06-01T10:00:00Z"}]`
+	fetched := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		lastModified      string
+		stripLastModified bool
+		stored            cacheValidator
+		wantSince         string
+		wantFetched       int
+		wantValidator     cacheValidator
+	}{
+		{
+			name:          "NotModified",
+			lastModified:  "Mon, 01 Jan 2024 10:00:00 GMT",
+			stored:        cacheValidator{Last_modified: "Mon, 01 Jan 2024 10:00:00 GMT"},
+			wantSince:     "Mon, 01 Jan 2024 10:00:00 GMT",
+			wantValidator: cacheValidator{Last_modified: "Mon, 01 Jan 2024 10:00:00 GMT"},
+		},
+		{
+			name:          "Modified",
+			lastModified:  "Mon, 01 Apr 2024 10:00:00 GMT",
+			stored:        cacheValidator{Last_modified: "Mon, 01 Jan 2024 10:00:00 GMT"},
+			wantSince:     "Mon, 01 Jan 2024 10:00:00 GMT",
+			wantFetched:   1,
+			wantValidator: cacheValidator{Last_modified: "Mon, 01 Apr 2024 10:00:00 GMT"},
+		},
+		{
+			name:              "NoValidatorNotModified",
+			lastModified:      "Mon, 01 Jan 2024 10:00:00 GMT",
+			stripLastModified: true,
+			wantSince:         "Thu, 01 Feb 2024 10:00:00 GMT",
+			wantValidator:     cacheValidator{Last_modified: "Thu, 01 Feb 2024 10:00:00 GMT"},
+		},
+		{
+			name:              "NoValidatorModified",
+			lastModified:      "Mon, 01 Apr 2024 10:00:00 GMT",
+			stripLastModified: true,
+			wantSince:         "Thu, 01 Feb 2024 10:00:00 GMT",
+			wantFetched:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup([]string{})
+			api := useStarsAPI(t)
+			api.stripEtag, api.lastModified, api.stripLastModified = true, tt.lastModified, tt.stripLastModified
+			cacheFile = filepath.Join(t.TempDir(), "stars.json")
+			defer func() { cacheFile = "" }()
+			var conditions http.Header
+			client = NewTestClient(func(req *http.Request) *http.Response {
+				if conditions == nil {
+					conditions = req.Header
+				}
+				return api.RoundTrip(req)
+			})
+
+			// The repos were fetched longer ago than --cache-ttl
+			cache, err := newCacheEnvelope("Link-", []byte(want))
+			assert.NoError(t, err)
+			cache.Fetched_at = fetched.Format(time.RFC3339)
+			data, err := json.Marshal(cache)
+			assert.NoError(t, err)
+			assert.NoError(t, writeCacheFile(cacheFile, data))
+			assert.NoError(t, writeCacheValidator(cacheFile, tt.stored))
+			if tt.stored != (cacheValidator{}) {
+				assert.NoError(t, os.Chtimes(validatorPath(cacheFile), fetched, fetched))
+			}
+
+			starred, err := GetStarredRepos("Link-", [32]byte{})
+			assert.NoError(t, err)
+			assert.Equal(t, want, starred.String())
+			assert.Equal(t, tt.wantSince, conditions.Get("If-Modified-Since"))
+			assert.Empty(t, conditions.Get("If-None-Match"))
+			assert.Equal(t, tt.wantFetched, api.fetched)
+			assert.Equal(t, 1-tt.wantFetched, api.revalidated)
+			validator, _ := readCacheValidator(cacheFile)
+			assert.Equal(t, tt.wantValidator, validator)
+		})
+	}
+}
+
+func TestPageLink(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		rel  string
+		want string
+	}{
+		{name: "FirstPage", link: `<https://api.github.com/user/1/starred?page=2>; rel="next", <https://api.github.com/user/1/starred?page=5>; rel="last"`, rel: "next", want: "https://api.github.com/user/1/starred?page=2"},
+		{name: "MiddlePage", link: `<https://api.github.com/user/1/starred?page=1>; rel="prev", <https://api.github.com/user/1/starred?page=3>; rel="next"`, rel: "next", want: "https://api.github.com/user/1/starred?page=3"},
+		{name: "LastPage", link: `<https://api.github.com/user/1/starred?page=4>; rel="prev", <https://api.github.com/user/1/starred?page=1>; rel="first"`, rel: "next"},
+		{name: "SinglePage", rel: "next"},
+		{name: "Last", link: `<https://api.github.com/user/1/starred?page=2>; rel="next", <https://api.github.com/user/1/starred?page=5>; rel="last"`, rel: "last", want: "https://api.github.com/user/1/starred?page=5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pageLink(tt.link, tt.rel))
+		})
+	}
+}
+
+func TestCountRepos(t *testing.T) {
+	setup([]string{})
+	api := useStarsAPI(t)
+
+	for _, count := range []int{0, 1, 3} {
+		api.stars = nil
+		for i := 0; i < count; i++ {
+			api.stars = append(api.stars, mockStar(fmt.Sprint("repo", i), "2024-01-01T10:00:00Z"))
+		}
+		got, err := countRepos("Link-")
+		assert.NoError(t, err)
+		assert.Equal(t, count, got)
+	}
+	assert.Equal(t, 3, api.counted)
+	assert.Equal(t, "https://api.github.com/users/Link-/starred?per_page=1", api.urls[0])
+}
+
+func TestUpdateCache(t *testing.T) {
+	setup([]string{})
+	api := useStarsAPI(t)
+	api.pageSize = 2
+	cacheFile = filepath.Join(t.TempDir(), "stars.json")
+	// Every cache is checked with GitHub, see TestCacheTTL
+	cacheTTL = time.Nanosecond
+	defer func() { cacheFile, cacheTTL = "", DEFAULT_CACHE_TTL }()
+
+	cobra, clap := mockStar("cobra", "2024-01-01T10:00:00Z"), mockStar("clap", "2023-06-01T10:00:00Z")
+	viper, bubbletea := mockStar("viper", "2024-05-01T10:00:00Z"), mockStar("bubbletea", "2024-06-01T10:00:00Z")
+	ratatui, urfave := mockStar("ratatui", "2024-07-01T10:00:00Z"), mockStar("urfave", "2024-08-01T10:00:00Z")
+	names := func(starred bytes.Buffer) []string {
+		repos, err := DecodeRepos(starred)
+		assert.NoError(t, err)
+		got := []string{}
+		for _, repo := range repos {
+			got = append(got, repo.Name)
+		}
+		return got
+	}
+	update := func() bytes.Buffer {
+		api.urls, api.fetched, api.counted = nil, 0, 0
+		// The ETag of the cache file is never the one of the first page, see TestRevalidateCache
+		cached, err := readCache(cacheFile)
+		assert.NoError(t, err)
+		assert.NoError(t, writeCache(cacheFile, "Link-", *bytes.NewBuffer(cached.Repos), cacheValidator{Etag: `W/"outdated"`}))
+		starred, err := GetStarredRepos("Link-", [32]byte{})
+		assert.NoError(t, err)
+		cached, err = readCache(cacheFile)
+		assert.NoError(t, err)
+		assert.Equal(t, starred.String(), string(cached.Repos))
+		return starred
+	}
+
+	// Only the first pages are fetched, up to the newest cached repo
+	assert.NoError(t, os.WriteFile(cacheFile, nil, 0644))
+	api.stars = []string{cobra, clap}
+	_, err := GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	api.stars = []string{bubbletea, viper, cobra, clap}
+	assert.Equal(t, []string{"bubbletea", "viper", "cobra", "clap"}, names(update()))
+	assert.Equal(t, []string{
+		"https://api.github.com/users/Link-/starred?per_page=100",
+		"https://api.github.com/user/1/starred?per_page=100&page=2",
+		"https://api.github.com/users/Link-/starred?per_page=1",
+	}, api.urls)
+	validator, err := readCacheValidator(cacheFile)
+	assert.NoError(t, err)
+	assert.NotEqual(t, `W/"outdated"`, validator.Etag)
+
+	// Nothing was starred since, only the first page is fetched
+	assert.Equal(t, []string{"bubbletea", "viper", "cobra", "clap"}, names(update()))
+	assert.Len(t, api.urls, 2)
+	assert.Equal(t, 1, api.counted)
+
+	// A repo was unstarred, every repo is fetched again
+	api.stars = []string{ratatui, bubbletea, viper, clap}
+	assert.Equal(t, []string{"ratatui", "bubbletea", "viper", "clap"}, names(update()))
+	assert.Equal(t, 2, api.fetched)
+	assert.Len(t, api.urls, 4)
+
+	// Every cached repo was unstarred, the repos starred since are every repo
+	api.stars = []string{urfave}
+	assert.Equal(t, []string{"urfave"}, names(update()))
+	assert.Equal(t, 1, api.fetched)
+	assert.Zero(t, api.counted)
+
+	// The expired cache files are updated the same way
+	cacheTTL = time.Hour
+	api.stars = []string{mockStar("gum", "2024-09-01T10:00:00Z"), urfave}
+	assert.NoError(t, os.Remove(validatorPath(cacheFile)))
+	cache, err := readCache(cacheFile)
+	assert.NoError(t, err)
+	cache.Fetched_at = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	expired, err := json.Marshal(cache)
+	assert.NoError(t, err)
+	assert.NoError(t, writeCacheFile(cacheFile, expired))
+	api.urls = nil
+	starred, err := GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gum", "urfave"}, names(starred))
+	assert.Len(t, api.urls, 2)
+
+	// The repos of the other sources are fetched again
+	cacheTTL = time.Nanosecond
+	source = "watching"
+	defer func() { source = "stars" }()
+	assert.NoError(t, writeCache(cacheFile, "Link-", *bytes.NewBufferString(`[{"name":"cobra","starred_at":"2024-01-01T10:00:00Z"}]`), cacheValidator{Etag: `W/"outdated"`}))
+	api.urls = nil
+	_, err = GetStarredRepos("Link-", [32]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://api.github.com/users/Link-/subscriptions?per_page=100"}, api.urls)
+}
@@ -4,9 +4,11 @@ go 1.19
 
 require (
 	github.com/cli/go-gh v1.2.1
-	github.com/lithammer/fuzzysearch v1.1.5
 	github.com/spf13/cobra v1.6.1
 	github.com/stretchr/testify v1.7.5
+	golang.org/x/sys v0.6.0
+	golang.org/x/term v0.6.0
+	golang.org/x/text v0.8.0
 )
 
 require (
@@ -26,9 +28,6 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/thlib/go-timezone-local v0.0.0-20210907160436-ef149e42d28e // indirect
 	golang.org/x/net v0.8.0 // indirect
-	golang.org/x/sys v0.6.0 // indirect
-	golang.org/x/term v0.6.0 // indirect
-	golang.org/x/text v0.8.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
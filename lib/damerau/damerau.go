@@ -0,0 +1,68 @@
+// Package damerau implements the Damerau-Levenshtein distance between two strings.
+package damerau
+
+// stackLength is the length of the longest word compared without allocating the rows of
+// the matrix
+const stackLength = 32
+
+// Distance returns the optimal string alignment distance between s and t: the number
+// of insertions, deletions, substitutions and transpositions of two adjacent characters
+// needed to turn s into t. Every edit costs 1 and the strings are compared rune by rune.
+func Distance(s, t string) int {
+	a, b := []rune(s), []rune(t)
+	if len(a) == 0 {
+		return len(b)
+	}
+	if len(b) == 0 {
+		return len(a)
+	}
+
+	// Only three rows of the matrix are kept, a transposition looks two rows back. They
+	// share a buffer on the stack for the short words a search compares.
+	var buffer [3 * stackLength]int
+	var rows []int
+	if len(b) < stackLength {
+		rows = buffer[:3*(len(b)+1)]
+	} else {
+		rows = make([]int, 3*(len(b)+1))
+	}
+	beforePrevious, previous, current := rows[:len(b)+1], rows[len(b)+1:2*(len(b)+1)], rows[2*(len(b)+1):]
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(
+				previous[j]+1,      // deletion
+				current[j-1]+1,     // insertion
+				previous[j-1]+cost, // substitution
+			)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				if transposition := beforePrevious[j-2] + 1; transposition < current[j] {
+					current[j] = transposition
+				}
+			}
+		}
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+	return previous[len(b)]
+}
+
+func min(a, b, c int) int {
+	if a < b {
+		if a < c {
+			return a
+		}
+		return c
+	}
+	if b < c {
+		return b
+	}
+	return c
+}
@@ -0,0 +1,57 @@
+package damerau
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		t    string
+		want int
+	}{
+		{name: "Identical", s: "gatekeeper", t: "gatekeeper", want: 0},
+		{name: "BothEmpty", s: "", t: "", want: 0},
+		{name: "FirstEmpty", s: "", t: "go", want: 2},
+		{name: "SecondEmpty", s: "go", t: "", want: 2},
+		{name: "Substitution", s: "gateleeper", t: "gatekeeper", want: 1},
+		{name: "Insertion", s: "gatekeper", t: "gatekeeper", want: 1},
+		{name: "Deletion", s: "gatekeeperr", t: "gatekeeper", want: 1},
+		{name: "Transposition", s: "gatekeepre", t: "gatekeeper", want: 1},
+		{name: "TranspositionAtStart", s: "agtekeeper", t: "gatekeeper", want: 1},
+		{name: "TwoTranspositions", s: "agtekeepre", t: "gatekeeper", want: 2},
+		{name: "TranspositionAndSubstitution", s: "hashicrop", t: "hashicorb", want: 2},
+		{name: "CaseSensitive", s: "Amethyst", t: "amethyst", want: 1},
+		{name: "Runes", s: "résumé", t: "résmué", want: 1},
+		{name: "Unrelated", s: "abc", t: "xyz", want: 3},
+		// The optimal string alignment distance doesn't edit a substring twice
+		{name: "NoEditAfterTransposition", s: "ca", t: "abc", want: 3},
+		// The rows of the matrix don't fit on the stack above 32 characters
+		{name: "LongWords", s: strings.Repeat("a", 40) + "bc", t: strings.Repeat("a", 40) + "cb", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Distance(tt.s, tt.t))
+			assert.Equal(t, tt.want, Distance(tt.t, tt.s))
+		})
+	}
+}
+
+// A search over a few thousand repos runs tens of thousands of comparisons
+// per needle, each of them has to stay well under a microsecond
+func BenchmarkDistance(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Distance("kubernetes", "kubernetse")
+	}
+}
+
+func BenchmarkDistanceShortWords(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Distance("cli", "go")
+	}
+}
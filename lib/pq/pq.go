@@ -36,12 +36,24 @@ type Item struct {
 	index int // The index of the item in the heap.
 }
 
+// A TieBreaker is a Value that can order itself against the Value of another Item
+// with the same Priority. It makes the order of the queue deterministic.
+type TieBreaker interface {
+	// Before reports whether the value should be popped before the other value.
+	Before(other any) bool
+}
+
 // A PriorityQueue implements heap.Interface and holds Items.
 type PriorityQueue []*Item
 
 func (pq PriorityQueue) Len() int { return len(pq) }
 
 func (pq PriorityQueue) Less(i, j int) bool {
+	if pq[i].Priority == pq[j].Priority {
+		if value, ok := pq[i].Value.(TieBreaker); ok {
+			return value.Before(pq[j].Value)
+		}
+	}
 	// We want Pop to give us the highest, not lowest, Priority so we use greater than here.
 	return pq[i].Priority > pq[j].Priority
 }